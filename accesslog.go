@@ -0,0 +1,169 @@
+package gclog
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//AccessLogFormat 访问日志的格式
+type AccessLogFormat int
+
+const (
+	//AccessLogCombined Apache Combined Log Format，在Common之后增加Referer与User-Agent
+	//exp: 127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /a.gif HTTP/1.0" 200 2326 "http://example.com/" "Mozilla/4.08"
+	AccessLogCombined AccessLogFormat = iota
+	//AccessLogCommon Common Log Format
+	//exp: 127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /a.gif HTTP/1.0" 200 2326
+	AccessLogCommon
+)
+
+//accessTimeLayout 访问日志的时间格式
+const accessTimeLayout = "[02/Jan/2006:15:04:05 -0700]"
+
+var errHijackUnsupported = errors.New("gclog: response writer does not support hijacking")
+
+//AccessLog 返回记录访问日志的http中间件，每个请求完成后按format写入filename一行，供GoAccess、awstats等工具分析
+//访问日志不经过编码器与sink，文件与RouteLevels的文件一样随主日志切分与清理，同一文件重复调用时替换之前的文件流
+//exp:
+//
+//	access, err := gclog.AccessLog("access.log", gclog.AccessLogCombined)
+//	http.ListenAndServe(":8080", access(mux))
+func AccessLog(filename string, format AccessLogFormat) (func(http.Handler) http.Handler, error) {
+	//级别范围为空，普通日志不会写入访问日志文件
+	s, err := NewFileSink(filename, FatalLevel+1, FatalLevel+1)
+	if err != nil {
+		return nil, err
+	}
+	if old := addSink("access:"+filename, s); old != nil {
+		closeSink(old)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			aw := &accessWriter{ResponseWriter: w}
+			next.ServeHTTP(aw, r)
+			buf := getBuffer()
+			buf.b = appendAccessLine(buf.b, format, r, start, aw.status, aw.size)
+			s.writeLine(buf.b, InfoLevel)
+			putBuffer(buf)
+		})
+	}, nil
+}
+
+//appendAccessLine 追加一行访问日志
+func appendAccessLine(buf []byte, format AccessLogFormat, r *http.Request, start time.Time, status int, size int64) []byte {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	buf = appendAccessField(buf, host)
+	buf = append(buf, " - "...)
+	user := ""
+	if r.URL.User != nil {
+		user = r.URL.User.Username()
+	} else if name, _, ok := r.BasicAuth(); ok {
+		user = name
+	}
+	buf = appendAccessField(buf, user)
+	buf = append(buf, ' ')
+	buf = start.AppendFormat(buf, accessTimeLayout)
+	buf = append(buf, " \""...)
+	buf = appendAccessEscaped(buf, r.Method+" "+r.RequestURI+" "+r.Proto)
+	buf = append(buf, "\" "...)
+	if status == 0 {
+		status = http.StatusOK
+	}
+	buf = strconv.AppendInt(buf, int64(status), 10)
+	buf = append(buf, ' ')
+	if size > 0 {
+		buf = strconv.AppendInt(buf, size, 10)
+	} else {
+		buf = append(buf, '-')
+	}
+	if format == AccessLogCombined {
+		buf = append(buf, " \""...)
+		buf = appendAccessField(buf, r.Referer())
+		buf = append(buf, "\" \""...)
+		buf = appendAccessField(buf, r.UserAgent())
+		buf = append(buf, '"')
+	}
+	return append(buf, '\n')
+}
+
+//appendAccessField 追加一个字段，为空时为“-”
+func appendAccessField(buf []byte, s string) []byte {
+	if s == "" {
+		return append(buf, '-')
+	}
+	return appendAccessEscaped(buf, s)
+}
+
+//appendAccessEscaped 与Apache相同，引号与反斜杠前加反斜杠，控制字符与非ASCII字符转为\xhh
+func appendAccessEscaped(buf []byte, s string) []byte {
+	const hexDigits = "0123456789abcdef"
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			buf = append(buf, '\\', c)
+		case c < 0x20 || c >= 0x7f:
+			buf = append(buf, '\\', 'x', hexDigits[c>>4], hexDigits[c&0xf])
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return buf
+}
+
+//accessWriter 记录响应状态码与长度的ResponseWriter
+type accessWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+//WriteHeader 实现http.ResponseWriter
+func (w *accessWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+//Write 实现http.ResponseWriter
+func (w *accessWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+//Flush 实现http.Flusher，EventStreamHandler等流式响应需要
+func (w *accessWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+//Hijack 实现http.Hijacker，TailHandler的WebSocket连接等需要
+func (w *accessWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errHijackUnsupported
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+//Unwrap 供http.ResponseController取得原始的ResponseWriter
+func (w *accessWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,630 @@
+package gclog
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//amqpMaxBatch 一次发送的最多消息数
+const amqpMaxBatch = 256
+
+//AMQP帧类型
+const (
+	amqpFrameMethod    = 1
+	amqpFrameHeader    = 2
+	amqpFrameBody      = 3
+	amqpFrameHeartbeat = 8
+	amqpFrameEnd       = 0xce
+)
+
+var errAMQPProtocol = errors.New("gclog: unexpected amqp frame")
+
+//AMQPOptions AMQP sink的设置
+type AMQPOptions struct {
+	TLS      *TLSOptions //不为nil时使用TLS连接
+	User     string      //为空时为guest
+	Password string      //为空时为guest
+	VHost    string      //为空时为“/”
+	//Confirm 为true时使用confirm模式发布，等待broker确认消息，未确认的批次重连后重发一次
+	Confirm bool
+	//Persistent 为true时消息的delivery-mode为2，持久化队列中的消息在broker重启后保留
+	Persistent bool
+	//RoutingKey 返回日志的routing key，为nil时为小写的级别名加“.”加logger名，exp: error.payment，没有logger名时为error
+	RoutingKey   func(e *Entry) string
+	Heartbeat    time.Duration //心跳间隔，<=0时默认30s
+	QueueSize    int           //等待发送的最多日志数，超出时丢弃日志，<=0时默认4096
+	DialTimeout  time.Duration //连接超时，<=0时默认10s
+	WriteTimeout time.Duration //写入超时，<=0时默认10s
+	//ConfirmTimeout 等待broker确认的超时，<=0时默认10s
+	ConfirmTimeout time.Duration
+}
+
+//AMQPSink 将日志发布到AMQP exchange（RabbitMQ等）的sink，消息内容为编码后的一条日志
+//使用AMQP 0-9-1协议，不依赖第三方库，exchange需要事先声明，日志先进入有界的等待队列，由后台goroutine发送，断线后自动重连
+//exp: topic exchange绑定“error.#”收集所有错误日志，绑定“*.payment”收集payment模块的日志
+type AMQPSink struct {
+	addr      string
+	exchange  string
+	opts      AMQPOptions
+	tlsConfig *tls.Config
+	queue     chan *amqpMessage
+	done      chan struct{}
+	closeOnce sync.Once
+	conn      *amqpConn //只在发送goroutine中使用
+	redial    redial    //重连间隔，只在发送goroutine中使用
+	lastErr   string    //上一次提示的错误，只在发送goroutine中使用
+	dropped   uint64    //丢弃的日志数，原子读写
+	sent      uint64    //发送成功的日志数，原子读写
+}
+
+//amqpMessage 一条待发送的日志
+type amqpMessage struct {
+	key  string
+	time time.Time
+	body *buffer
+}
+
+//amqpConfirm broker的确认，ok为false时为nack
+type amqpConfirm struct {
+	tag      uint64
+	multiple bool
+	ok       bool
+}
+
+//amqpConn 一个AMQP连接，只使用通道1，读goroutine接收确认并处理broker关闭连接
+type amqpConn struct {
+	conn      net.Conn
+	r         *bufio.Reader
+	wlock     *sync.Mutex      //写入时加锁，读goroutine也会写入
+	frameMax  int              //协商后的最大帧长度
+	heartbeat time.Duration    //协商后的心跳间隔，为0时不发送
+	confirms  chan amqpConfirm //confirm模式的确认
+	closed    chan struct{}    //读goroutine退出时关闭
+	reason    string           //broker关闭连接或通道的原因，closed关闭后读取
+	nextTag   uint64           //下一条消息的delivery tag，只在发送goroutine中使用
+	lastSend  time.Time        //上一次发送的时间，只在发送goroutine中使用
+}
+
+//NewAMQPSink 创建发布到exchange的AMQP sink，addr为host:port
+//exp: s, err := gclog.NewAMQPSink("rabbitmq.local:5672", "logs", gclog.AMQPOptions{User: "app", Password: "secret", Confirm: true})
+func NewAMQPSink(addr, exchange string, opts AMQPOptions) (*AMQPSink, error) {
+	if len(exchange) > 255 {
+		return nil, fmt.Errorf("gclog: invalid amqp exchange %q", exchange)
+	}
+	if opts.User == "" {
+		opts.User = "guest"
+	}
+	if opts.Password == "" {
+		opts.Password = "guest"
+	}
+	if opts.VHost == "" {
+		opts.VHost = "/"
+	}
+	if opts.RoutingKey == nil {
+		opts.RoutingKey = defaultAMQPRoutingKey
+	}
+	if opts.Heartbeat <= 0 {
+		opts.Heartbeat = 30 * time.Second
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 4096
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 10 * time.Second
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = 10 * time.Second
+	}
+	if opts.ConfirmTimeout <= 0 {
+		opts.ConfirmTimeout = 10 * time.Second
+	}
+	s := &AMQPSink{
+		addr:     addr,
+		exchange: exchange,
+		opts:     opts,
+		queue:    make(chan *amqpMessage, opts.QueueSize),
+		done:     make(chan struct{}),
+	}
+	if opts.TLS != nil {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		if s.tlsConfig, err = opts.TLS.config(host); err != nil {
+			return nil, err
+		}
+	}
+	go s.run()
+	return s, nil
+}
+
+//SendToAMQP 创建AMQP sink并注册，同一地址与exchange重复调用时替换之前的sink
+//exp: gclog.SendToAMQP("127.0.0.1:5672", "logs", gclog.AMQPOptions{})
+func SendToAMQP(addr, exchange string, opts AMQPOptions) (*AMQPSink, error) {
+	s, err := NewAMQPSink(addr, exchange, opts)
+	if err != nil {
+		return nil, err
+	}
+	if old := addSink("amqp:"+addr+"/"+exchange, s); old != nil {
+		closeSink(old)
+	}
+	return s, nil
+}
+
+//defaultAMQPRoutingKey 默认的routing key，小写的级别名加logger名
+func defaultAMQPRoutingKey(e *Entry) string {
+	key := strings.ToLower(LevelName(e.Level))
+	if e.Logger != "" {
+		key += "." + e.Logger
+	}
+	return key
+}
+
+//Write 实现Sink，复制日志后放入等待队列，队列满时丢弃并返回错误
+func (s *AMQPSink) Write(e *Entry, line []byte) error {
+	buf := getBuffer()
+	buf.b = append(buf.b, line...)
+	key := s.opts.RoutingKey(e)
+	if len(key) > 255 {
+		key = key[:255]
+	}
+	select {
+	case s.queue <- &amqpMessage{key: key, time: e.Time, body: buf}:
+		return nil
+	default:
+		putBuffer(buf)
+		atomic.AddUint64(&s.dropped, 1)
+		return errSinkFull
+	}
+}
+
+//Close 实现Sink，发送队列中剩余的日志后关闭连接
+func (s *AMQPSink) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.queue)
+	})
+	<-s.done
+	return nil
+}
+
+//Dropped 返回因队列满、发送失败或broker拒绝丢弃的日志数
+func (s *AMQPSink) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+//Sent 返回发送成功（confirm模式时为已确认）的日志数
+func (s *AMQPSink) Sent() uint64 {
+	return atomic.LoadUint64(&s.sent)
+}
+
+//run 发送goroutine，阻塞等待第一条日志后合并队列中已有的日志一起发送，空闲时发送心跳
+func (s *AMQPSink) run() {
+	defer close(s.done)
+	batch := make([]*amqpMessage, 0, amqpMaxBatch)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		var msg *amqpMessage
+		var ok bool
+		select {
+		case msg, ok = <-s.queue:
+		case <-ticker.C:
+			s.ping()
+			continue
+		}
+		if !ok {
+			break
+		}
+		batch = append(batch[:0], msg)
+	drain:
+		for len(batch) < amqpMaxBatch {
+			select {
+			case msg, ok := <-s.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, msg)
+			default:
+				break drain
+			}
+		}
+		sent := s.publish(batch)
+		atomic.AddUint64(&s.sent, uint64(sent))
+		atomic.AddUint64(&s.dropped, uint64(len(batch)-sent))
+		for _, msg := range batch {
+			putBuffer(msg.body)
+		}
+	}
+	if s.conn != nil {
+		//Connection.Close，reply-code 200
+		args := []byte{0, 200}
+		args = appendAMQPShortString(args, "bye")
+		args = append(args, 0, 0, 0, 0)
+		s.conn.write(appendAMQPMethod(nil, 0, 10, 50, args), time.Second)
+		s.conn.close()
+	}
+}
+
+//ping 距上次发送超过心跳间隔的一半时发送心跳帧
+func (s *AMQPSink) ping() {
+	c := s.conn
+	if c == nil || c.heartbeat == 0 || time.Since(c.lastSend) < c.heartbeat/2 {
+		return
+	}
+	if err := c.write([]byte{amqpFrameHeartbeat, 0, 0, 0, 0, 0, 0, amqpFrameEnd}, s.opts.WriteTimeout); err != nil {
+		s.drop()
+		return
+	}
+	c.lastSend = time.Now()
+}
+
+//publish 发送一批日志，返回发送成功的条数
+//confirm模式时等待全部确认，连接断开或确认超时时重连后重发一次，broker拒绝（nack）的日志不重发
+func (s *AMQPSink) publish(batch []*amqpMessage) int {
+	var out []byte
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil && !s.dial() {
+			return 0
+		}
+		c := s.conn
+		out = out[:0]
+		for _, msg := range batch {
+			out = s.appendPublish(out, c, msg)
+		}
+		first := c.nextTag
+		c.nextTag += uint64(len(batch))
+		if err := c.write(out, s.opts.WriteTimeout); err != nil {
+			s.drop()
+			continue
+		}
+		c.lastSend = time.Now()
+		if !s.opts.Confirm {
+			return len(batch)
+		}
+		if acked, ok := c.waitConfirms(first, len(batch), s.opts.ConfirmTimeout); ok {
+			return acked
+		}
+		s.drop()
+	}
+	return 0
+}
+
+//appendPublish 追加一条消息的Basic.Publish、内容头与内容体帧
+func (s *AMQPSink) appendPublish(dst []byte, c *amqpConn, msg *amqpMessage) []byte {
+	args := []byte{0, 0}
+	args = appendAMQPShortString(args, s.exchange)
+	args = appendAMQPShortString(args, msg.key)
+	args = append(args, 0)
+	dst = appendAMQPMethod(dst, 1, 60, 40, args)
+	//内容头：class 60、weight 0、body size，属性为delivery-mode（bit 12）与timestamp（bit 6）
+	header := make([]byte, 14, 25)
+	binary.BigEndian.PutUint16(header, 60)
+	binary.BigEndian.PutUint64(header[4:], uint64(len(msg.body.b)))
+	flags := uint16(1 << 6)
+	if s.opts.Persistent {
+		flags |= 1 << 12
+		header = append(header, 2)
+	}
+	binary.BigEndian.PutUint16(header[12:], flags)
+	header = append(header, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(header[len(header)-8:], uint64(msg.time.Unix()))
+	dst = appendAMQPFrame(dst, amqpFrameHeader, 1, header)
+	body := msg.body.b
+	max := c.frameMax - 8
+	for len(body) > 0 {
+		n := len(body)
+		if n > max {
+			n = max
+		}
+		dst = appendAMQPFrame(dst, amqpFrameBody, 1, body[:n])
+		body = body[n:]
+	}
+	return dst
+}
+
+//drop 关闭出错的连接，broker关闭连接时提示原因，下次发送时重连
+func (s *AMQPSink) drop() {
+	s.conn.close()
+	if reason := s.conn.reason; reason != "" && reason != s.lastErr {
+		s.lastErr = reason
+		Warning("amqp sink %s closed by broker, because %s", s.addr, reason)
+	}
+	s.conn = nil
+}
+
+//dial 建立连接并打开通道，连接失败后在重连间隔内直接返回失败
+func (s *AMQPSink) dial() bool {
+	if !s.redial.allow() {
+		return false
+	}
+	c, err := s.connect()
+	if err != nil {
+		s.redial.failed()
+		if err.Error() != s.lastErr {
+			s.lastErr = err.Error()
+			Warning("amqp sink %s connect failed, because %s", s.addr, s.lastErr)
+		}
+		return false
+	}
+	s.conn = c
+	s.lastErr = ""
+	s.redial.reset()
+	go c.readLoop()
+	return true
+}
+
+//connect 连接broker，完成Connection的握手，打开通道1，需要时开启confirm模式
+func (s *AMQPSink) connect() (*amqpConn, error) {
+	dialer := &net.Dialer{Timeout: s.opts.DialTimeout}
+	var conn net.Conn
+	var err error
+	if s.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.addr, s.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", s.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(s.opts.DialTimeout))
+	c := &amqpConn{
+		conn:     conn,
+		r:        bufio.NewReader(conn),
+		wlock:    new(sync.Mutex),
+		frameMax: 131072,
+		confirms: make(chan amqpConfirm, amqpMaxBatch),
+		closed:   make(chan struct{}),
+		nextTag:  1,
+	}
+	if err := c.handshake(s); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	c.lastSend = time.Now()
+	return c, nil
+}
+
+//handshake 协议头、Connection.Start/Tune/Open、Channel.Open与Confirm.Select
+func (c *amqpConn) handshake(s *AMQPSink) error {
+	if _, err := c.conn.Write([]byte("AMQP\x00\x00\x09\x01")); err != nil {
+		return err
+	}
+	if _, err := c.expect(10, 10); err != nil {
+		return err
+	}
+	//Connection.StartOk：client-properties、PLAIN认证、locale
+	props := appendAMQPShortString(nil, "product")
+	props = append(props, 'S')
+	props = appendAMQPLongString(props, "gclog")
+	caps := appendAMQPShortString(nil, "publisher_confirms")
+	caps = append(caps, 't', 1)
+	props = appendAMQPShortString(props, "capabilities")
+	props = append(props, 'F')
+	props = appendAMQPLongString(props, string(caps))
+	args := appendAMQPLongString(nil, string(props))
+	args = appendAMQPShortString(args, "PLAIN")
+	args = appendAMQPLongString(args, "\x00"+s.opts.User+"\x00"+s.opts.Password)
+	args = appendAMQPShortString(args, "en_US")
+	if err := c.write(appendAMQPMethod(nil, 0, 10, 11, args), s.opts.WriteTimeout); err != nil {
+		return err
+	}
+	tune, err := c.expect(10, 30)
+	if err != nil {
+		return err
+	}
+	if len(tune) < 8 {
+		return errAMQPProtocol
+	}
+	if frameMax := int(binary.BigEndian.Uint32(tune[2:])); frameMax > 0 && frameMax < c.frameMax {
+		c.frameMax = frameMax
+	}
+	//心跳取双方的较小值，broker为0时不发送
+	heartbeat := s.opts.Heartbeat / time.Second
+	if server := time.Duration(binary.BigEndian.Uint16(tune[6:])); server < heartbeat {
+		heartbeat = server
+	}
+	c.heartbeat = heartbeat * time.Second
+	args = make([]byte, 8)
+	binary.BigEndian.PutUint16(args, 1)
+	binary.BigEndian.PutUint32(args[2:], uint32(c.frameMax))
+	binary.BigEndian.PutUint16(args[6:], uint16(heartbeat))
+	out := appendAMQPMethod(nil, 0, 10, 31, args)
+	args = appendAMQPShortString(nil, s.opts.VHost)
+	args = append(args, 0, 0)
+	out = appendAMQPMethod(out, 0, 10, 40, args)
+	if err := c.write(out, s.opts.WriteTimeout); err != nil {
+		return err
+	}
+	if _, err := c.expect(10, 41); err != nil {
+		return err
+	}
+	if err := c.write(appendAMQPMethod(nil, 1, 20, 10, []byte{0}), s.opts.WriteTimeout); err != nil {
+		return err
+	}
+	if _, err := c.expect(20, 11); err != nil {
+		return err
+	}
+	if s.opts.Confirm {
+		if err := c.write(appendAMQPMethod(nil, 1, 85, 10, []byte{0}), s.opts.WriteTimeout); err != nil {
+			return err
+		}
+		if _, err := c.expect(85, 11); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//expect 读取方法帧直到收到class、method，broker关闭连接时返回原因，返回方法参数
+func (c *amqpConn) expect(class, method uint16) ([]byte, error) {
+	for {
+		typ, _, payload, err := readAMQPFrame(c.r)
+		if err != nil {
+			return nil, err
+		}
+		if typ != amqpFrameMethod {
+			continue
+		}
+		if len(payload) < 4 {
+			return nil, errAMQPProtocol
+		}
+		gotClass, gotMethod := binary.BigEndian.Uint16(payload), binary.BigEndian.Uint16(payload[2:])
+		if gotClass == class && gotMethod == method {
+			return payload[4:], nil
+		}
+		if gotMethod == 40 && gotClass == 20 || gotMethod == 50 && gotClass == 10 {
+			return nil, errors.New("gclog: amqp " + amqpCloseReason(payload[4:]))
+		}
+	}
+}
+
+//readLoop 读goroutine，接收确认，回复broker的关闭，连接出错时退出
+func (c *amqpConn) readLoop() {
+	defer close(c.closed)
+	for {
+		typ, _, payload, err := readAMQPFrame(c.r)
+		if err != nil {
+			return
+		}
+		if typ != amqpFrameMethod || len(payload) < 4 {
+			continue
+		}
+		class, method, args := binary.BigEndian.Uint16(payload), binary.BigEndian.Uint16(payload[2:]), payload[4:]
+		switch {
+		case class == 60 && (method == 80 || method == 120) && len(args) >= 9:
+			//Basic.Ack与Basic.Nack：delivery-tag、multiple
+			select {
+			case c.confirms <- amqpConfirm{tag: binary.BigEndian.Uint64(args), multiple: args[8]&1 != 0, ok: method == 80}:
+			default:
+			}
+		case class == 20 && method == 40, class == 10 && method == 50:
+			//通道关闭后不能继续发布，断开连接重连
+			c.reason = amqpCloseReason(args)
+			if class == 10 {
+				c.write(appendAMQPMethod(nil, 0, 10, 51, nil), time.Second)
+			}
+			c.conn.Close()
+			return
+		}
+	}
+}
+
+//write 写入帧，与读goroutine互斥
+func (c *amqpConn) write(p []byte, timeout time.Duration) error {
+	c.wlock.Lock()
+	defer c.wlock.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(timeout))
+	_, err := c.conn.Write(p)
+	return err
+}
+
+//waitConfirms 等待delivery tag从first开始的n条消息全部确认，返回ack的条数；超时或连接断开时第二个返回值为false
+func (c *amqpConn) waitConfirms(first uint64, n int, timeout time.Duration) (int, bool) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	done := make([]bool, n)
+	acked, confirmed := 0, 0
+	mark := func(tag uint64, ok bool) {
+		if tag < first || tag >= first+uint64(n) || done[tag-first] {
+			return
+		}
+		done[tag-first] = true
+		confirmed++
+		if ok {
+			acked++
+		}
+	}
+	for confirmed < n {
+		select {
+		case conf := <-c.confirms:
+			if conf.multiple {
+				for tag := first; tag <= conf.tag; tag++ {
+					mark(tag, conf.ok)
+				}
+			} else {
+				mark(conf.tag, conf.ok)
+			}
+		case <-c.closed:
+			return 0, false
+		case <-timer.C:
+			return 0, false
+		}
+	}
+	return acked, true
+}
+
+//close 关闭连接并等待读goroutine退出
+func (c *amqpConn) close() {
+	c.conn.Close()
+	<-c.closed
+}
+
+//amqpCloseReason 返回Connection.Close、Channel.Close参数中的reply-code与reply-text
+func amqpCloseReason(args []byte) string {
+	if len(args) < 3 || len(args) < 3+int(args[2]) {
+		return "connection closed"
+	}
+	return fmt.Sprintf("%d %s", binary.BigEndian.Uint16(args), args[3:3+int(args[2])])
+}
+
+//appendAMQPMethod 追加一个方法帧
+func appendAMQPMethod(dst []byte, channel, class, method uint16, args []byte) []byte {
+	payload := make([]byte, 4, 4+len(args))
+	binary.BigEndian.PutUint16(payload, class)
+	binary.BigEndian.PutUint16(payload[2:], method)
+	return appendAMQPFrame(dst, amqpFrameMethod, channel, append(payload, args...))
+}
+
+//appendAMQPFrame 追加一个帧
+func appendAMQPFrame(dst []byte, typ byte, channel uint16, payload []byte) []byte {
+	dst = append(dst, typ, byte(channel>>8), byte(channel), 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(dst[len(dst)-4:], uint32(len(payload)))
+	dst = append(dst, payload...)
+	return append(dst, amqpFrameEnd)
+}
+
+//appendAMQPShortString 追加短字符串，超过255字节时截断
+func appendAMQPShortString(dst []byte, s string) []byte {
+	if len(s) > 255 {
+		s = s[:255]
+	}
+	dst = append(dst, byte(len(s)))
+	return append(dst, s...)
+}
+
+//appendAMQPLongString 追加长字符串
+func appendAMQPLongString(dst []byte, s string) []byte {
+	dst = append(dst, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(dst[len(dst)-4:], uint32(len(s)))
+	return append(dst, s...)
+}
+
+//readAMQPFrame 读取一个帧
+func readAMQPFrame(r *bufio.Reader) (byte, uint16, []byte, error) {
+	var head [7]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(head[3:])
+	if size > 1<<24 {
+		return 0, 0, nil, errAMQPProtocol
+	}
+	payload := make([]byte, size+1)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, 0, nil, err
+	}
+	if payload[size] != amqpFrameEnd {
+		return 0, 0, nil, errAMQPProtocol
+	}
+	return head[0], binary.BigEndian.Uint16(head[1:]), payload[:size], nil
+}
@@ -0,0 +1,273 @@
+package gclog
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//archiveSettle 切分出的文件在这段时间内没有修改才上传，切分期间及多进程时其他进程仍可能短暂写入改名后的文件
+const archiveSettle = 10 * time.Second
+
+var errArchiveQueueFull = errors.New("gclog: archive queue full")
+
+var (
+	archiverLock    = new(sync.Mutex) //修改归档设置时加锁
+	currentArchiver *Archiver         //当前的归档器，未设置时为nil，archiverLock保护
+)
+
+//ArchiveTarget 归档目标，S3Target等实现该接口，也可以自己实现上传到其他存储
+type ArchiveTarget interface {
+	//Upload 将file上传为名称为key的对象，size为文件大小，失败时归档器按ArchiveOptions.MaxRetries重试
+	Upload(key string, file *os.File, size int64) error
+}
+
+//ArchiveOptions 归档的设置
+type ArchiveOptions struct {
+	//Prefix 对象名前缀，对象名为前缀加文件名，支持{hostname}与{date}（上传时的日期，2006-01-02）占位符
+	//exp: logs/{hostname}/{date}/
+	Prefix string
+	//Gzip 为true时上传gzip压缩后的文件，对象名加.gz，本地文件不变
+	Gzip bool
+	//DeleteAfterUpload 为true时上传成功后删除本地文件，本地磁盘只保留未上传的文件，SetDeleteDryRun时只报告不删除
+	DeleteAfterUpload bool
+	MaxRetries        int //上传失败时的重试次数，间隔1s、2s、4s…，<0时不重试，为0时默认3
+	QueueSize         int //等待上传的最多文件数，<=0时默认64
+}
+
+//Archiver 将切分出的日志文件上传到归档目标的归档器，主日志文件与RouteLevels的文件切分后自动加入上传队列
+//由后台goroutine逐个上传，失败的文件保留在本地，仍按SetLogStorageTime清理
+type Archiver struct {
+	target    ArchiveTarget
+	opts      ArchiveOptions
+	hostname  string
+	queue     chan string
+	stop      chan struct{} //Close时关闭，不再等待文件稳定
+	done      chan struct{}
+	closeOnce sync.Once
+	uploaded  uint64 //上传成功的文件数，原子读写
+	failed    uint64 //上传失败的文件数，原子读写
+}
+
+//SetArchiver 设置切分后上传日志文件的归档目标，替换之前的归档器（等待其队列中的文件上传完成），target为nil时关闭归档
+//exp:
+//
+//	s3, err := gclog.NewS3Target(gclog.S3Options{Endpoint: "http://127.0.0.1:9000", Bucket: "logs", PathStyle: true, AccessKeyID: "minio", SecretAccessKey: "secret"})
+//	gclog.SetArchiver(s3, gclog.ArchiveOptions{Prefix: "app/{hostname}/", Gzip: true, DeleteAfterUpload: true})
+func SetArchiver(target ArchiveTarget, opts ArchiveOptions) *Archiver {
+	var a *Archiver
+	if target != nil {
+		if opts.MaxRetries == 0 {
+			opts.MaxRetries = 3
+		}
+		if opts.QueueSize <= 0 {
+			opts.QueueSize = 64
+		}
+		host, err := os.Hostname()
+		if err != nil {
+			host = "unknown"
+		}
+		a = &Archiver{
+			target:   target,
+			opts:     opts,
+			hostname: host,
+			queue:    make(chan string, opts.QueueSize),
+			stop:     make(chan struct{}),
+			done:     make(chan struct{}),
+		}
+		go a.run()
+	}
+	archiverLock.Lock()
+	old := currentArchiver
+	currentArchiver = a
+	archiverLock.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	return a
+}
+
+//archiveRotated 切分出文件后加入当前归档器的上传队列
+func archiveRotated(path string) {
+	archiverLock.Lock()
+	a := currentArchiver
+	archiverLock.Unlock()
+	//设置了保留策略时由归档阶段上传
+	if a == nil || retentionActive() {
+		return
+	}
+	if err := a.Archive(path); err != nil {
+		Warning("archive file %s failed, because %s", path, err.Error())
+	}
+}
+
+//Archive 将path加入上传队列，可以用于上传设置归档前切分出的文件，队列满时返回错误
+func (a *Archiver) Archive(path string) (err error) {
+	defer func() {
+		//已关闭
+		if recover() != nil {
+			err = errSinkClosed
+		}
+	}()
+	select {
+	case a.queue <- path:
+		return nil
+	default:
+		return errArchiveQueueFull
+	}
+}
+
+//Close 上传队列中剩余的文件后返回，不再等待文件稳定
+func (a *Archiver) Close() error {
+	a.closeOnce.Do(func() {
+		close(a.stop)
+		close(a.queue)
+	})
+	<-a.done
+	return nil
+}
+
+//Uploaded 返回上传成功的文件数
+func (a *Archiver) Uploaded() uint64 {
+	return atomic.LoadUint64(&a.uploaded)
+}
+
+//Failed 返回重试后仍上传失败的文件数
+func (a *Archiver) Failed() uint64 {
+	return atomic.LoadUint64(&a.failed)
+}
+
+//run 上传goroutine
+func (a *Archiver) run() {
+	defer close(a.done)
+	for path := range a.queue {
+		if _, err := a.upload(path); err != nil {
+			atomic.AddUint64(&a.failed, 1)
+			Warning("archive file %s failed, because %s", path, err.Error())
+			continue
+		}
+		atomic.AddUint64(&a.uploaded, 1)
+		if a.opts.DeleteAfterUpload {
+			deleteUploaded(path)
+		}
+	}
+}
+
+//deleteUploaded 上传成功后删除本地文件，与清理过期日志相同，SetDeleteDryRun时只报告，实际删除时输出审计日志
+func deleteUploaded(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	f := rotatedFile{path: path, info: info}
+	if isDeleteDryRun() {
+		reportDryRun(f, DeleteArchived)
+		return
+	}
+	if err := removeRotated(path); err != nil {
+		Warning("delete archived file %s failed, because %s", path, err.Error())
+		return
+	}
+	recordDeletion(f, DeleteArchived)
+}
+
+//upload 等待文件稳定后上传，需要时先压缩到临时文件，返回对象名
+func (a *Archiver) upload(path string) (string, error) {
+	a.waitSettled(path)
+	src, key := path, a.key(filepath.Base(path))
+	//保留策略压缩过的文件不再压缩
+	if a.opts.Gzip && !strings.HasSuffix(path, ".gz") {
+		tmp, err := gzipFile(path)
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(tmp)
+		src, key = tmp, key+".gz"
+	}
+	wait := time.Second
+	for attempt := 0; ; attempt++ {
+		err := a.uploadFile(key, src)
+		if err == nil || os.IsNotExist(err) || attempt >= a.opts.MaxRetries {
+			return key, err
+		}
+		select {
+		case <-time.After(wait):
+		case <-a.stop:
+			//关闭时不再等待重试间隔
+		}
+		wait *= 2
+	}
+}
+
+//uploadFile 打开文件并上传一次
+func (a *Archiver) uploadFile(key, src string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return a.target.Upload(key, file, info.Size())
+}
+
+//waitSettled 等待文件archiveSettle内没有修改，Close时直接返回
+func (a *Archiver) waitSettled(path string) {
+	for {
+		info, err := os.Stat(path)
+		if err != nil {
+			return
+		}
+		quiet := time.Since(info.ModTime())
+		if quiet >= archiveSettle {
+			return
+		}
+		select {
+		case <-time.After(archiveSettle - quiet):
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+//key 返回文件的对象名
+func (a *Archiver) key(name string) string {
+	prefix := strings.Replace(a.opts.Prefix, "{hostname}", a.hostname, -1)
+	prefix = strings.Replace(prefix, "{date}", time.Now().Format("2006-01-02"), -1)
+	return prefix + name
+}
+
+//gzipFile 将文件压缩到同目录的临时文件，返回临时文件名
+func gzipFile(path string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	dst, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.gz")
+	if err != nil {
+		return "", err
+	}
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(path)
+	_, err = io.Copy(zw, src)
+	if errClose := zw.Close(); err == nil {
+		err = errClose
+	}
+	if errClose := dst.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		os.Remove(dst.Name())
+		return "", err
+	}
+	return dst.Name(), nil
+}
@@ -0,0 +1,314 @@
+package gclog
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+//asyncBatchSize 异步模式下单次写入的最大字节数，多条日志合并为一次Write
+const asyncBatchSize = 64 * 1024
+
+//AsyncQueueKind 异步队列的实现方式
+type AsyncQueueKind int
+
+const (
+	//AsyncQueueRing 无锁的多生产者单消费者环形队列，默认值，高并发下开销最小
+	AsyncQueueRing AsyncQueueKind = iota
+	//AsyncQueueChannel 基于channel的队列
+	AsyncQueueChannel
+)
+
+//asyncItem 异步队列中的一项，flushed不为nil时表示Flush请求
+type asyncItem struct {
+	buf     *buffer
+	flushed chan struct{}
+	stdout  bool //输出到标准输出，见SetConsoleSplitLevel
+}
+
+//asyncQueuer 异步队列，多个生产者，一个消费者（asyncWriter）
+type asyncQueuer interface {
+	push(item asyncItem)       //放入一项，队列满时阻塞
+	pop() (asyncItem, bool)    //取出一项，队列为空时阻塞，队列关闭且为空时返回false
+	tryPop() (asyncItem, bool) //非阻塞取出一项
+	close()                    //关闭队列，调用时不能再有生产者
+	length() int               //队列中的项数
+}
+
+var (
+	asyncLock      = new(sync.RWMutex) //保护asyncQueue的替换
+	asyncQueue     asyncQueuer         //异步队列，为nil时为同步写入
+	asyncDone      chan struct{}       //写入goroutine退出信号
+	asyncEnable    int32               //是否开启异步，原子读写，用于热路径快速判断
+	asyncWritten   uint64              //异步模式下写入次数（系统调用次数），原子读写
+	asyncQueueKind AsyncQueueKind      //下次SetAsync使用的队列实现，由asyncLock保护
+)
+
+//SetAsyncQueueKind 设置异步队列的实现方式，在下次调用SetAsync时生效，默认AsyncQueueRing
+func SetAsyncQueueKind(kind AsyncQueueKind) {
+	asyncLock.Lock()
+	defer asyncLock.Unlock()
+	asyncQueueKind = kind
+}
+
+//SetAsync 开启异步写入，日志编码后进入长度为queueSize的队列，由后台goroutine合并成最大64KB的批次写入
+//可以显著减少系统调用次数，提高机械盘、网络文件系统下的写入能力；队列满时写日志会阻塞等待
+//queueSize<=0时默认为4096，重复调用会先关闭之前的异步写入
+//进程退出前需要调用Flush（Fatal会自动调用），保证队列中的日志全部写入
+func SetAsync(queueSize int) {
+	DisableAsync()
+	if queueSize <= 0 {
+		queueSize = 4096
+	}
+	done := make(chan struct{})
+	asyncLock.Lock()
+	var q asyncQueuer
+	if asyncQueueKind == AsyncQueueChannel {
+		q = newChannelQueue(queueSize)
+	} else {
+		q = newRingQueue(queueSize)
+	}
+	asyncQueue, asyncDone = q, done
+	atomic.StoreInt32(&asyncEnable, 1)
+	asyncLock.Unlock()
+	go asyncWriter(q, done)
+}
+
+//DisableAsync 关闭异步写入，等待队列中的日志全部写入后返回
+func DisableAsync() {
+	asyncLock.Lock()
+	q, done := asyncQueue, asyncDone
+	asyncQueue, asyncDone = nil, nil
+	atomic.StoreInt32(&asyncEnable, 0)
+	asyncLock.Unlock()
+	if q != nil {
+		q.close()
+		<-done
+	}
+}
+
+//Flush 等待异步队列中已有的日志全部写入，同步模式下直接返回
+func Flush() {
+	asyncLock.RLock()
+	q := asyncQueue
+	if q == nil {
+		asyncLock.RUnlock()
+		return
+	}
+	flushed := make(chan struct{})
+	q.push(asyncItem{flushed: flushed})
+	asyncLock.RUnlock()
+	<-flushed
+}
+
+//enqueueAsync 将编码后的日志放入异步队列，stdout为true时输出到标准输出，未开启异步时返回false
+func enqueueAsync(buf *buffer, stdout bool) bool {
+	if atomic.LoadInt32(&asyncEnable) == 0 {
+		return false
+	}
+	asyncLock.RLock()
+	defer asyncLock.RUnlock()
+	if asyncQueue == nil {
+		return false
+	}
+	asyncQueue.push(asyncItem{buf: buf, stdout: stdout})
+	return true
+}
+
+//asyncWriter 异步写入goroutine，阻塞等待第一条日志后，非阻塞地取出队列中已有的日志合并写入
+func asyncWriter(q asyncQueuer, done chan struct{}) {
+	defer close(done)
+	b := &asyncBatch{out: make([]byte, 0, asyncBatchSize)}
+	var waiters []chan struct{}
+	for {
+		item, ok := q.pop()
+		if !ok {
+			return
+		}
+		waiters = b.add(waiters, item)
+		for len(b.out)+len(b.stdout) < asyncBatchSize {
+			next, ok := q.tryPop()
+			if !ok {
+				break
+			}
+			waiters = b.add(waiters, next)
+		}
+		if len(b.out) > 0 {
+			fileLock.RLock()
+			output.Write(b.out)
+			countLogLines(b.out)
+			fileLock.RUnlock()
+			atomic.AddUint64(&asyncWritten, 1)
+			b.out = b.out[:0]
+		}
+		if len(b.stdout) > 0 {
+			os.Stdout.Write(b.stdout)
+			atomic.AddUint64(&asyncWritten, 1)
+			b.stdout = b.stdout[:0]
+		}
+		//批次写入后再通知Flush，保证Flush之前的日志都已写入
+		for _, w := range waiters {
+			close(w)
+		}
+		waiters = waiters[:0]
+	}
+}
+
+//asyncBatch 一次合并写入的批次，按输出目标分开
+type asyncBatch struct {
+	out    []byte //主输出
+	stdout []byte //标准输出
+}
+
+//add 将一项合并进批次，Flush请求记录到waiters
+func (b *asyncBatch) add(waiters []chan struct{}, item asyncItem) []chan struct{} {
+	if item.flushed != nil {
+		return append(waiters, item.flushed)
+	}
+	if item.stdout {
+		b.stdout = append(b.stdout, item.buf.b...)
+	} else {
+		b.out = append(b.out, item.buf.b...)
+	}
+	putBuffer(item.buf)
+	return waiters
+}
+
+//channelQueue 基于channel的异步队列
+type channelQueue struct {
+	ch chan asyncItem
+}
+
+//newChannelQueue 创建长度为size的channel队列
+func newChannelQueue(size int) *channelQueue {
+	return &channelQueue{ch: make(chan asyncItem, size)}
+}
+
+func (c *channelQueue) push(item asyncItem) {
+	c.ch <- item
+}
+
+func (c *channelQueue) pop() (asyncItem, bool) {
+	item, ok := <-c.ch
+	return item, ok
+}
+
+func (c *channelQueue) tryPop() (asyncItem, bool) {
+	select {
+	case item, ok := <-c.ch:
+		return item, ok
+	default:
+		return asyncItem{}, false
+	}
+}
+
+func (c *channelQueue) close() {
+	close(c.ch)
+}
+
+func (c *channelQueue) length() int {
+	return len(c.ch)
+}
+
+//cacheLinePad 填充到缓存行大小，避免不同goroutine频繁写入的变量落在同一缓存行（false sharing）
+type cacheLinePad [64]byte
+
+//ringSlot 环形队列的一个槽位
+//seq==pos时槽位空闲，可由生产者写入；seq==pos+1时已写入，可由消费者读取
+type ringSlot struct {
+	seq  uint64
+	item asyncItem
+	_    [64 - 8 - 24]byte
+}
+
+//ringQueue 无锁的多生产者单消费者有界环形队列
+//生产者通过CAS竞争tail，消费者独占head，槽位的seq标记该槽位可读/可写
+type ringQueue struct {
+	_      cacheLinePad
+	tail   uint64 //下一个写入位置，生产者CAS竞争
+	_      cacheLinePad
+	head   uint64 //下一个读取位置，只有消费者修改
+	_      cacheLinePad
+	mask   uint64
+	slots  []ringSlot
+	wake   chan struct{} //队列由空变为非空时唤醒消费者
+	closed int32
+}
+
+//newRingQueue 创建容量不小于size（向上取2的幂）的环形队列
+func newRingQueue(size int) *ringQueue {
+	n := uint64(1)
+	for n < uint64(size) {
+		n <<= 1
+	}
+	r := &ringQueue{mask: n - 1, slots: make([]ringSlot, n), wake: make(chan struct{}, 1)}
+	for i := range r.slots {
+		r.slots[i].seq = uint64(i)
+	}
+	return r
+}
+
+func (r *ringQueue) push(item asyncItem) {
+	for {
+		pos := atomic.LoadUint64(&r.tail)
+		slot := &r.slots[pos&r.mask]
+		seq := atomic.LoadUint64(&slot.seq)
+		switch {
+		case seq == pos:
+			//槽位空闲，竞争写入权
+			if atomic.CompareAndSwapUint64(&r.tail, pos, pos+1) {
+				slot.item = item
+				atomic.StoreUint64(&slot.seq, pos+1)
+				select {
+				case r.wake <- struct{}{}:
+				default:
+				}
+				return
+			}
+		case seq < pos:
+			//队列已满，让出cpu等待消费者
+			runtime.Gosched()
+		}
+		//seq > pos说明tail已被其他生产者推进，重试
+	}
+}
+
+func (r *ringQueue) tryPop() (asyncItem, bool) {
+	pos := r.head
+	slot := &r.slots[pos&r.mask]
+	if atomic.LoadUint64(&slot.seq) != pos+1 {
+		return asyncItem{}, false
+	}
+	item := slot.item
+	slot.item = asyncItem{}
+	//标记为下一轮可写
+	atomic.StoreUint64(&slot.seq, pos+r.mask+1)
+	atomic.StoreUint64(&r.head, pos+1)
+	return item, true
+}
+
+func (r *ringQueue) pop() (asyncItem, bool) {
+	for {
+		if item, ok := r.tryPop(); ok {
+			return item, true
+		}
+		if atomic.LoadInt32(&r.closed) == 1 {
+			//关闭后再检查一次，保证关闭前写入的项都被取出
+			return r.tryPop()
+		}
+		<-r.wake
+	}
+}
+
+func (r *ringQueue) close() {
+	atomic.StoreInt32(&r.closed, 1)
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *ringQueue) length() int {
+	return int(atomic.LoadUint64(&r.tail) - atomic.LoadUint64(&r.head))
+}
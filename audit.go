@@ -0,0 +1,139 @@
+package gclog
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//AuditLogger 审计日志的logger名称
+const AuditLogger = "audit"
+
+var (
+	auditLock = new(sync.RWMutex) //保护auditSink的替换
+	auditSink *FileSink           //审计日志文件，为nil时审计日志写入主输出
+)
+
+//InitAuditFile 初始化审计日志文件，审计日志只追加写入，有自己的切分间隔与保存时间（默认与主日志文件相同）
+//重复调用时关闭之前的审计日志文件
+func InitAuditFile(filename string) error {
+	s, err := NewFileSink(filename, VerbLevel, FatalLevel)
+	if err != nil {
+		return err
+	}
+	auditLock.Lock()
+	old := auditSink
+	auditSink = s
+	auditLock.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	if atomic.LoadInt32(&auditChainEnable) == 1 {
+		//接续文件中已有的哈希链，进程重启后链不断开
+		resumeAuditChain(filename)
+		auditAnchor()
+	}
+	return nil
+}
+
+//SetAuditSliceInterval 设置审计日志文件的切分间隔，为0时与主日志文件相同
+func SetAuditSliceInterval(interval time.Duration) {
+	auditLock.RLock()
+	defer auditLock.RUnlock()
+	if auditSink != nil {
+		auditSink.SetSliceInterval(interval)
+	}
+}
+
+//SetAuditStorageTime 设置审计日志文件的保存时间，为0时与主日志文件相同
+func SetAuditStorageTime(storageTime time.Duration) {
+	auditLock.RLock()
+	defer auditLock.RUnlock()
+	if auditSink != nil {
+		auditSink.SetStorageTime(storageTime)
+	}
+}
+
+//Audit 输出一条审计日志，用于登录、权限变更等合规事件
+//审计日志不受日志级别、主输出级别等任何过滤限制，也不投递给sink，每条写入后立即落盘
+//写入审计文件失败时输出到标准错误，保证不丢失；未调用InitAuditFile时写入主输出
+//exp: gclog.Audit("user.grant", gclog.F("user", "tom"), gclog.F("role", "admin"))
+func Audit(event string, fields ...Field) {
+	e := getEntry()
+	e.Level, e.Message, e.Fields, e.Logger = NoticeLevel, event, fields, AuditLogger
+	if callerEnabled(NoticeLevel) {
+		if file, line, ok := callerFileLine(1); ok {
+			e.File, e.Line = file, line
+		}
+	}
+	auditEntry(e)
+	putEntry(e)
+}
+
+//auditEntry 补全时间等信息后编码并写入审计日志，开启哈希链时附加链式哈希
+func auditEntry(e *Entry) {
+	e.Time = now()
+	e.Message = maskMessage(e.Message)
+	e.Fields = redactFields(appendGlobalFields(e.Fields))
+	if atomic.LoadInt32(&auditChainEnable) == 1 {
+		auditChained(e)
+		return
+	}
+	buf := getBuffer()
+	buf.b = encodeAudit(buf.b, e)
+	writeAudit(e, buf.b)
+	putBuffer(buf)
+}
+
+//encodeAudit 分配序号与id后使用当前编码器编码
+func encodeAudit(buf []byte, e *Entry) []byte {
+	fileLock.RLock()
+	defer fileLock.RUnlock()
+	if e.Seq == 0 {
+		e.Seq = nextSeq()
+	}
+	if e.ID == "" {
+		e.ID = nextEntryID(e.Time)
+	}
+	return encoder.Encode(buf, e)
+}
+
+//writeAudit 写入审计日志文件并落盘
+func writeAudit(e *Entry, line []byte) {
+	auditLock.RLock()
+	defer auditLock.RUnlock()
+	if auditSink == nil {
+		fileLock.RLock()
+		output.Write(line)
+		countLogLines(line)
+		fileLock.RUnlock()
+		return
+	}
+	if err := auditSink.Write(e, line); err != nil {
+		os.Stderr.Write(line)
+		return
+	}
+	auditSink.Sync()
+}
+
+//sliceAuditFile 到达切分时间时切分审计日志文件
+func sliceAuditFile() {
+	auditLock.RLock()
+	s := auditSink
+	auditLock.RUnlock()
+	if s == nil {
+		return
+	}
+	if atomic.LoadInt32(&auditChainEnable) == 0 {
+		s.sliceIfDue(false)
+		return
+	}
+	//新文件以锚点开头，单独校验新文件时从锚点记录的哈希开始
+	//切分与锚点之间不能插入其他审计日志，持有auditChainLock完成
+	auditChainLock.Lock()
+	defer auditChainLock.Unlock()
+	if s.sliceIfDue(false) {
+		writeAnchor()
+	}
+}
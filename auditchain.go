@@ -0,0 +1,174 @@
+package gclog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	//AuditChainField 审计日志链式哈希的字段名，值为sha256(上一条的哈希+本条去掉该字段后的内容)
+	AuditChainField = "chain"
+	//AuditPrevField 锚点中记录上一条哈希的字段名
+	AuditPrevField = "prev"
+	//AuditAnchorEvent 锚点事件名
+	AuditAnchorEvent = "audit.anchor"
+)
+
+var (
+	auditChainEnable int32             //是否开启审计日志哈希链，原子读写
+	auditChainLock   = new(sync.Mutex) //保证链的顺序与写入顺序一致
+	auditChainHash   [sha256.Size]byte //上一条审计日志的哈希，由auditChainLock保护
+	auditChainCount  uint64            //开启后写入的审计日志数，由auditChainLock保护
+	auditAnchorEvery uint64            //每多少条审计日志输出一个锚点，由auditChainLock保护
+	errAuditChain    = errors.New("gclog: audit chain broken")
+)
+
+//EnableAuditChain 开启审计日志的哈希链，每条审计日志附加chain字段，值为上一条哈希与本条内容的sha256
+//每anchorEvery条（<=0时为1000）以及每个新文件开头输出一个锚点事件，锚点同时写入主输出，作为链的外部副本
+//事后对审计文件的任何修改、删除、插入都会使VerifyAuditChain失败，需要在InitAuditFile之前调用
+//哈希链支持TextEncoder与JSONEncoder
+func EnableAuditChain(anchorEvery int) {
+	if anchorEvery <= 0 {
+		anchorEvery = 1000
+	}
+	auditChainLock.Lock()
+	auditAnchorEvery = uint64(anchorEvery)
+	auditChainLock.Unlock()
+	atomic.StoreInt32(&auditChainEnable, 1)
+}
+
+//DisableAuditChain 关闭审计日志的哈希链
+func DisableAuditChain() {
+	atomic.StoreInt32(&auditChainEnable, 0)
+}
+
+//auditChained 计算链式哈希后写入审计日志，到达锚点间隔时输出锚点
+func auditChained(e *Entry) {
+	auditChainLock.Lock()
+	defer auditChainLock.Unlock()
+	writeChained(e)
+	if auditChainCount%auditAnchorEvery == 0 {
+		writeAnchor()
+	}
+}
+
+//writeChained 编码两次，第一次不带chain字段用于计算哈希，需要在持有auditChainLock时调用
+func writeChained(e *Entry) {
+	buf := getBuffer()
+	buf.b = encodeAudit(buf.b, e)
+	sum := chainHash(auditChainHash, bytes.TrimSuffix(buf.b, []byte("\n")))
+	//使用完整切片表达式，避免覆盖调用方字段切片的底层数组
+	e.Fields = append(e.Fields[:len(e.Fields):len(e.Fields)], F(AuditChainField, hex.EncodeToString(sum[:])))
+	buf.b = encodeAudit(buf.b[:0], e)
+	writeAudit(e, buf.b)
+	putBuffer(buf)
+	auditChainHash = sum
+	auditChainCount++
+}
+
+//auditAnchor 输出锚点
+func auditAnchor() {
+	if atomic.LoadInt32(&auditChainEnable) == 0 {
+		return
+	}
+	auditChainLock.Lock()
+	defer auditChainLock.Unlock()
+	writeAnchor()
+}
+
+//writeAnchor 输出锚点并写入主输出，需要在持有auditChainLock时调用
+func writeAnchor() {
+	prev := hex.EncodeToString(auditChainHash[:])
+	e := getEntry()
+	e.Level, e.Message, e.Logger = NoticeLevel, AuditAnchorEvent, AuditLogger
+	e.Fields = []Field{F(AuditPrevField, prev), F("count", auditChainCount)}
+	e.Time = now()
+	writeChained(e)
+	putEntry(e)
+	//锚点写入主输出，不受日志级别限制，审计文件被整体替换时可以对照
+	writeLogForced(NoticeLevel, AuditAnchorEvent, F(AuditPrevField, prev), F(AuditChainField, hex.EncodeToString(auditChainHash[:])))
+}
+
+//chainHash 计算sha256(prev+line)
+func chainHash(prev [sha256.Size]byte, line []byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write(prev[:])
+	h.Write(line)
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+//resumeAuditChain 从审计文件的最后一行恢复链式哈希
+func resumeAuditChain(filename string) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	var r io.Reader = f
+	//加密的审计文件需要先解密
+	if conf := loadEncryptConfig(); conf.enabled() && isEncryptedFile(filename) {
+		if r, err = newDecryptReader(f, conf); err != nil {
+			return
+		}
+	}
+	var last []byte
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			last = append(last[:0], scanner.Bytes()...)
+		}
+	}
+	value, ok := lineFieldValue(last, AuditChainField)
+	if !ok {
+		return
+	}
+	auditChainLock.Lock()
+	defer auditChainLock.Unlock()
+	hex.Decode(auditChainHash[:], []byte(value))
+}
+
+//VerifyAuditChain 校验审计日志文件的哈希链，返回校验通过的行数
+//第一行为锚点时从锚点记录的哈希开始校验，因此切分出的文件可以单独校验
+//exp:
+//
+//	f, _ := os.Open("audit.log")
+//	n, err := gclog.VerifyAuditChain(f)
+func VerifyAuditChain(r io.Reader) (int, error) {
+	var prev [sha256.Size]byte
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	n := 0
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		value, ok := lineFieldValue(line, AuditChainField)
+		if !ok {
+			return n, fmt.Errorf("%s: line %d has no %s field", errAuditChain, n+1, AuditChainField)
+		}
+		if n == 0 {
+			if p, ok := lineFieldValue(line, AuditPrevField); ok {
+				hex.Decode(prev[:], []byte(p))
+			}
+		}
+		sum := chainHash(prev, removeLineField(line, AuditChainField))
+		if hex.EncodeToString(sum[:]) != value {
+			return n, fmt.Errorf("%s: line %d hash mismatch", errAuditChain, n+1)
+		}
+		prev = sum
+		n++
+	}
+	return n, scanner.Err()
+}
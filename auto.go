@@ -0,0 +1,42 @@
+package gclog
+
+import (
+	"os"
+	"sync"
+)
+
+var (
+	containerOnce sync.Once
+	inContainer   bool //是否运行在容器中
+)
+
+//AutoEncoder 自动选择编码格式：输出到终端时使用ConsoleEncoder，重定向到文件/管道或运行在容器中时使用JSONEncoder
+type AutoEncoder struct {
+	Console ConsoleEncoder //终端下使用的编码器配置
+	JSON    JSONEncoder    //非终端下使用的编码器配置
+}
+
+//Encode 实现Encoder
+func (a *AutoEncoder) Encode(buf []byte, e *Entry) []byte {
+	if consoleIsTerminal() && !runningInContainer() {
+		return a.Console.Encode(buf, e)
+	}
+	return a.JSON.Encode(buf, e)
+}
+
+//runningInContainer 判断是否运行在docker、podman或kubernetes中
+func runningInContainer() bool {
+	containerOnce.Do(func() {
+		if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+			inContainer = true
+			return
+		}
+		for _, f := range []string{"/.dockerenv", "/run/.containerenv"} {
+			if _, err := os.Stat(f); err == nil {
+				inContainer = true
+				return
+			}
+		}
+	})
+	return inContainer
+}
@@ -0,0 +1,205 @@
+package gclog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//azureAPIVersion Blob服务的API版本，支持OAuth认证与5000MiB的单次上传
+const azureAPIVersion = "2021-08-06"
+
+//azureStorageResource 托管标识申请token时的资源
+const azureStorageResource = "https://storage.azure.com/"
+
+//AzureBlobOptions Azure Blob Storage的设置，SAS与ManagedIdentity二选一
+type AzureBlobOptions struct {
+	Account   string //存储账户名
+	Container string
+	//Endpoint 服务地址，为空时为https://<Account>.blob.core.windows.net，Azurite等模拟器时需要设置
+	Endpoint string
+	//SAS 共享访问签名，exp: sv=2021-08-06&ss=b&srt=o&sp=cw&se=...&sig=...，需要create与write权限
+	SAS string
+	//ManagedIdentity 为true时使用托管标识（VM、AKS的IMDS或App Service的IDENTITY_ENDPOINT）申请token
+	ManagedIdentity bool
+	ClientID        string        //用户分配的托管标识的client id，为空时使用系统分配的标识
+	AccessTier      string        //访问层，exp: Cool，为空时使用账户的默认值
+	TLS             *TLSOptions   //证书设置，为nil时使用系统证书
+	Timeout         time.Duration //一次上传的超时，<=0时默认10min
+}
+
+//AzureBlobTarget 上传到Azure Blob Storage的归档目标，以Put Blob上传为块blob，单个文件最大5000MiB
+type AzureBlobTarget struct {
+	opts      AzureBlobOptions
+	base      string
+	client    *http.Client
+	tokenLock sync.Mutex
+	token     string    //托管标识的access token，tokenLock保护
+	expires   time.Time //token的过期时间，tokenLock保护
+}
+
+//NewAzureBlobTarget 创建Azure Blob归档目标
+//exp: az, err := gclog.NewAzureBlobTarget(gclog.AzureBlobOptions{Account: "applogs", Container: "archive", ManagedIdentity: true})
+func NewAzureBlobTarget(opts AzureBlobOptions) (*AzureBlobTarget, error) {
+	if opts.Container == "" {
+		return nil, errors.New("gclog: empty azure blob container")
+	}
+	if opts.SAS == "" && !opts.ManagedIdentity {
+		return nil, errors.New("gclog: azure blob target requires a SAS token or managed identity")
+	}
+	if opts.Endpoint == "" {
+		if opts.Account == "" {
+			return nil, errors.New("gclog: empty azure storage account")
+		}
+		opts.Endpoint = "https://" + opts.Account + ".blob.core.windows.net"
+	}
+	base, err := url.Parse(opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" || base.Host == "" {
+		return nil, fmt.Errorf("gclog: invalid azure blob endpoint %q", opts.Endpoint)
+	}
+	opts.SAS = strings.TrimPrefix(opts.SAS, "?")
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Minute
+	}
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if opts.TLS != nil {
+		if transport.TLSClientConfig, err = opts.TLS.config(base.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+	return &AzureBlobTarget{
+		opts:   opts,
+		base:   strings.TrimRight(opts.Endpoint, "/") + "/" + s3Escape(opts.Container) + "/",
+		client: &http.Client{Transport: transport, Timeout: opts.Timeout},
+	}, nil
+}
+
+//Upload 实现ArchiveTarget，以Put Blob上传
+func (t *AzureBlobTarget) Upload(key string, file *os.File, size int64) error {
+	req, err := http.NewRequest("PUT", t.url(key), io.NopCloser(file))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if t.opts.AccessTier != "" {
+		req.Header.Set("x-ms-access-tier", t.opts.AccessTier)
+	}
+	return t.do(req, "put", key)
+}
+
+//Delete 实现ArchiveDeleter，blob不存在时返回nil
+func (t *AzureBlobTarget) Delete(key string) error {
+	req, err := http.NewRequest("DELETE", t.url(key), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	return t.do(req, "delete", key)
+}
+
+//url 返回blob的地址，使用SAS时带上签名
+func (t *AzureBlobTarget) url(key string) string {
+	target := t.base + s3Escape(key)
+	if t.opts.SAS != "" {
+		target += "?" + t.opts.SAS
+	}
+	return target
+}
+
+//do 需要时加上托管标识的token后发送请求，非2xx时返回错误，删除时404视为成功
+func (t *AzureBlobTarget) do(req *http.Request, op, key string) error {
+	if t.opts.SAS == "" {
+		token, err := t.accessToken()
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 || req.Method == "DELETE" && resp.StatusCode == http.StatusNotFound {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("gclog: azure %s blob %s returned %d: %s", op, key, resp.StatusCode, strings.TrimSpace(string(msg)))
+}
+
+//accessToken 返回托管标识的access token，过期前5分钟重新申请
+func (t *AzureBlobTarget) accessToken() (string, error) {
+	t.tokenLock.Lock()
+	defer t.tokenLock.Unlock()
+	if t.token != "" && time.Now().Before(t.expires.Add(-5*time.Minute)) {
+		return t.token, nil
+	}
+	query := url.Values{}
+	query.Set("resource", azureStorageResource)
+	var endpoint string
+	header := http.Header{}
+	if identity := os.Getenv("IDENTITY_ENDPOINT"); identity != "" {
+		//App Service、Functions
+		endpoint = identity
+		query.Set("api-version", "2019-08-01")
+		header.Set("X-IDENTITY-HEADER", os.Getenv("IDENTITY_HEADER"))
+	} else {
+		//VM、VMSS、AKS的实例元数据服务
+		endpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+		query.Set("api-version", "2018-02-01")
+		header.Set("Metadata", "true")
+	}
+	if t.opts.ClientID != "" {
+		query.Set("client_id", t.opts.ClientID)
+	}
+	req, err := http.NewRequest("GET", endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header = header
+	//元数据服务不经过代理
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("gclog: azure managed identity returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var result struct {
+		AccessToken string      `json:"access_token"`
+		ExpiresOn   json.Number `json:"expires_on"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", err
+	}
+	if result.AccessToken == "" {
+		return "", errors.New("gclog: azure managed identity returned an empty token")
+	}
+	t.token = result.AccessToken
+	t.expires = time.Now().Add(time.Hour)
+	if sec, err := strconv.ParseInt(result.ExpiresOn.String(), 10, 64); err == nil {
+		t.expires = time.Unix(sec, 0)
+	}
+	return t.token, nil
+}
@@ -0,0 +1,125 @@
+package gclog
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+//BacktraceField 回溯缓冲转储输出的日志附带的字段名，值为true，用于和正常输出的日志区分
+const BacktraceField = "backtrace"
+
+//backtraceItem 回溯缓冲中的一条日志
+type backtraceItem struct {
+	entry   Entry
+	written bool //是否已按日志级别正常输出
+}
+
+//backtraceBuffer 最近日志的环形缓冲
+type backtraceBuffer struct {
+	lock      *sync.Mutex
+	entries   []backtraceItem //环形数组
+	next      int             //下一条写入的位置
+	count     int             //已缓存的条数
+	dumpLevel int             //触发转储的级别，-1表示只手动转储
+}
+
+var (
+	backtraceOn  int32        //是否开启回溯缓冲，原子读写，开启后所有级别的日志都会进入logEntry
+	backtraceBuf atomic.Value //当前的回溯缓冲，*backtraceBuffer，未开启时为nil
+)
+
+//EnableBacktrace 开启回溯缓冲，在内存中保留最近size条日志（包括低于日志级别、没有输出的日志）
+//出现dumpLevel及以上级别的日志时，先将缓冲中因级别不足没有输出的日志写入日志文件再清空，用于查看错误发生前的上下文，而不必一直输出debug日志
+//dumpLevel为-1时只在调用DumpBacktrace时转储，重复调用时替换之前的缓冲
+//开启后低级别日志也要取调用者位置并复制，有一定开销
+//exp: gclog.EnableBacktrace(200, gclog.ErrorLevel)
+func EnableBacktrace(size, dumpLevel int) {
+	if size <= 0 {
+		DisableBacktrace()
+		return
+	}
+	backtraceBuf.Store(&backtraceBuffer{lock: new(sync.Mutex), entries: make([]backtraceItem, size), dumpLevel: dumpLevel})
+	atomic.StoreInt32(&backtraceOn, 1)
+}
+
+//DisableBacktrace 关闭回溯缓冲，缓冲中的日志被丢弃
+func DisableBacktrace() {
+	atomic.StoreInt32(&backtraceOn, 0)
+	backtraceBuf.Store((*backtraceBuffer)(nil))
+}
+
+//loadBacktrace 取当前的回溯缓冲，未开启时返回nil
+func loadBacktrace() *backtraceBuffer {
+	b, _ := backtraceBuf.Load().(*backtraceBuffer)
+	return b
+}
+
+//recordBacktrace 将日志复制到回溯缓冲，达到转储级别时先转储缓冲中已有的日志
+func recordBacktrace(e *Entry) {
+	if atomic.LoadInt32(&backtraceOn) == 0 {
+		return
+	}
+	b := loadBacktrace()
+	if b == nil {
+		return
+	}
+	if b.dumpLevel >= VerbLevel && e.Level >= b.dumpLevel {
+		writeBacktrace(b.take(false))
+	}
+	item := backtraceItem{entry: copyEntry(e), written: logLevelEnabled(e.Level)}
+	b.lock.Lock()
+	b.entries[b.next] = item
+	b.next = (b.next + 1) % len(b.entries)
+	if b.count < len(b.entries) {
+		b.count++
+	}
+	b.lock.Unlock()
+}
+
+//take 按时间顺序返回缓冲中的日志并清空缓冲，all为false时只返回没有正常输出的日志
+func (b *backtraceBuffer) take(all bool) []Entry {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	entries := b.entriesLocked(all)
+	for i := range b.entries {
+		b.entries[i] = backtraceItem{}
+	}
+	b.next, b.count = 0, 0
+	return entries
+}
+
+//entriesLocked 按时间顺序复制缓冲中的日志，需要在持有b.lock时调用
+func (b *backtraceBuffer) entriesLocked(all bool) []Entry {
+	entries := make([]Entry, 0, b.count)
+	start := b.next - b.count
+	if start < 0 {
+		start += len(b.entries)
+	}
+	for i := 0; i < b.count; i++ {
+		item := &b.entries[(start+i)%len(b.entries)]
+		if all || !item.written {
+			entries = append(entries, item.entry)
+		}
+	}
+	return entries
+}
+
+//DumpBacktrace 将回溯缓冲中没有正常输出的日志写入日志文件并清空缓冲，返回写入的条数，未开启时返回0
+func DumpBacktrace() int {
+	b := loadBacktrace()
+	if b == nil {
+		return 0
+	}
+	entries := b.take(false)
+	writeBacktrace(entries)
+	return len(entries)
+}
+
+//writeBacktrace 按原来的时间、级别与调用者位置输出缓存的日志，附加backtrace=true字段
+func writeBacktrace(entries []Entry) {
+	for i := range entries {
+		e := &entries[i]
+		e.Fields = append(e.Fields, F(BacktraceField, true))
+		writeEntry(e)
+	}
+}
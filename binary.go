@@ -0,0 +1,183 @@
+package gclog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+//BinaryMarker 二进制格式每条日志的首字节，不会出现在文本与json格式日志的行首
+const BinaryMarker = 0xc1
+
+//二进制格式中日志成员的键，未设置的成员不写入
+const (
+	BinaryKeyTime   = iota //unix纳秒时间
+	BinaryKeyLevel         //日志级别
+	BinaryKeyMsg           //日志内容
+	BinaryKeyFile          //调用者文件，按SetCallerFormat格式化
+	BinaryKeyLine          //调用者行号
+	BinaryKeyFields        //附加字段，键为字符串的map
+	BinaryKeyStack         //调用栈
+	BinaryKeySeq           //序号
+	BinaryKeyID            //ULID
+	BinaryKeyLogger        //logger名称
+)
+
+//CBOR的主类型
+const (
+	cborUint   = 0 << 5
+	cborNegInt = 1 << 5
+	cborBytes  = 2 << 5
+	cborText   = 3 << 5
+	cborMap    = 5 << 5
+	cborTag    = 6 << 5
+	cborSimple = 7 << 5
+)
+
+//BinaryEncoder 紧凑的二进制编码器，字段按原类型保存，读取时不需要解析文本，体积小于文本格式与JSONEncoder
+//每条日志为：BinaryMarker + uvarint长度 + CBOR(RFC 8949)编码的map + 换行，map的键为BinaryKeyXxx
+//字段值支持整数、浮点数、布尔、字符串、[]byte、time.Time（CBOR tag 0）与nil，其他类型转为字符串
+//使用reader包或gclogcat读取，exp: gclogcat -o text app.log
+type BinaryEncoder struct{}
+
+//Encode 实现Encoder
+func (b *BinaryEncoder) Encode(buf []byte, e *Entry) []byte {
+	buf = append(buf, BinaryMarker)
+	payload := len(buf)
+
+	n := uint64(2)
+	for _, set := range []bool{e.Message != "", e.File != "", e.File != "", len(e.Fields) > 0, e.Stack != "", e.Seq != 0, e.ID != "", e.Logger != ""} {
+		if set {
+			n++
+		}
+	}
+	buf = appendCBORHead(buf, cborMap, n)
+	buf = appendCBORHead(buf, cborUint, BinaryKeyTime)
+	buf = appendCBORInt(buf, e.Time.UnixNano())
+	buf = appendCBORHead(buf, cborUint, BinaryKeyLevel)
+	buf = appendCBORInt(buf, int64(e.Level))
+	if e.Message != "" {
+		buf = appendCBORHead(buf, cborUint, BinaryKeyMsg)
+		buf = appendCBORText(buf, e.Message)
+	}
+	if e.File != "" {
+		buf = appendCBORHead(buf, cborUint, BinaryKeyFile)
+		buf = appendCBORText(buf, formatCaller(e.File))
+		buf = appendCBORHead(buf, cborUint, BinaryKeyLine)
+		buf = appendCBORInt(buf, int64(e.Line))
+	}
+	if len(e.Fields) > 0 {
+		buf = appendCBORHead(buf, cborUint, BinaryKeyFields)
+		buf = appendCBORHead(buf, cborMap, uint64(len(e.Fields)))
+		for _, f := range e.Fields {
+			buf = appendCBORText(buf, f.Key)
+			buf = appendCBORValue(buf, f.Value)
+		}
+	}
+	if e.Stack != "" {
+		buf = appendCBORHead(buf, cborUint, BinaryKeyStack)
+		buf = appendCBORText(buf, e.Stack)
+	}
+	if e.Seq != 0 {
+		buf = appendCBORHead(buf, cborUint, BinaryKeySeq)
+		buf = appendCBORHead(buf, cborUint, e.Seq)
+	}
+	if e.ID != "" {
+		buf = appendCBORHead(buf, cborUint, BinaryKeyID)
+		buf = appendCBORText(buf, e.ID)
+	}
+	if e.Logger != "" {
+		buf = appendCBORHead(buf, cborUint, BinaryKeyLogger)
+		buf = appendCBORText(buf, e.Logger)
+	}
+
+	//在内容之前插入长度
+	var size [binary.MaxVarintLen64]byte
+	k := binary.PutUvarint(size[:], uint64(len(buf)-payload))
+	buf = append(buf, size[:k]...)
+	copy(buf[payload+k:], buf[payload:len(buf)-k])
+	copy(buf[payload:], size[:k])
+	return append(buf, '\n')
+}
+
+//appendCBORHead 追加CBOR数据项的头部，major为主类型，n为值或长度
+func appendCBORHead(buf []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(buf, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, major|26), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(buf, major|27), n)
+}
+
+//appendCBORInt 追加有符号整数
+func appendCBORInt(buf []byte, n int64) []byte {
+	if n < 0 {
+		return appendCBORHead(buf, cborNegInt, uint64(-1-n))
+	}
+	return appendCBORHead(buf, cborUint, uint64(n))
+}
+
+//appendCBORText 追加字符串
+func appendCBORText(buf []byte, s string) []byte {
+	buf = appendCBORHead(buf, cborText, uint64(len(s)))
+	return append(buf, s...)
+}
+
+//appendCBORValue 追加字段值，常用类型按原类型编码
+func appendCBORValue(buf []byte, value interface{}) []byte {
+	switch v := value.(type) {
+	case nil:
+		return append(buf, cborSimple|22)
+	case bool:
+		if v {
+			return append(buf, cborSimple|21)
+		}
+		return append(buf, cborSimple|20)
+	case string:
+		return appendCBORText(buf, v)
+	case []byte:
+		buf = appendCBORHead(buf, cborBytes, uint64(len(v)))
+		return append(buf, v...)
+	case int:
+		return appendCBORInt(buf, int64(v))
+	case int8:
+		return appendCBORInt(buf, int64(v))
+	case int16:
+		return appendCBORInt(buf, int64(v))
+	case int32:
+		return appendCBORInt(buf, int64(v))
+	case int64:
+		return appendCBORInt(buf, v)
+	case uint:
+		return appendCBORHead(buf, cborUint, uint64(v))
+	case uint8:
+		return appendCBORHead(buf, cborUint, uint64(v))
+	case uint16:
+		return appendCBORHead(buf, cborUint, uint64(v))
+	case uint32:
+		return appendCBORHead(buf, cborUint, uint64(v))
+	case uint64:
+		return appendCBORHead(buf, cborUint, v)
+	case float32:
+		return binary.BigEndian.AppendUint32(append(buf, cborSimple|26), math.Float32bits(v))
+	case float64:
+		return binary.BigEndian.AppendUint64(append(buf, cborSimple|27), math.Float64bits(v))
+	case time.Time:
+		buf = appendCBORHead(buf, cborTag, 0)
+		return appendCBORText(buf, v.Format(time.RFC3339Nano))
+	case time.Duration:
+		return appendCBORText(buf, v.String())
+	case error:
+		return appendCBORText(buf, v.Error())
+	case fmt.Stringer:
+		return appendCBORText(buf, v.String())
+	}
+	return appendCBORText(buf, fmt.Sprint(value))
+}
@@ -0,0 +1,79 @@
+package gclog
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+const (
+	//VersionField 版本号字段名
+	VersionField = "version"
+	//CommitField 代码提交字段名
+	CommitField = "commit"
+	//GoVersionField go版本字段名
+	GoVersionField = "goversion"
+)
+
+//BuildInfo 当前二进制的构建信息
+type BuildInfo struct {
+	Path      string //主模块路径
+	Version   string //主模块版本，go build本地构建时为(devel)
+	Commit    string //vcs提交id
+	Modified  bool   //构建时工作区是否有未提交修改
+	GoVersion string //go版本
+}
+
+//ReadBuildInfo 读取当前二进制的构建信息
+func ReadBuildInfo() BuildInfo {
+	info := BuildInfo{Version: "unknown", GoVersion: runtime.Version()}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.Path = bi.Main.Path
+	if bi.Main.Version != "" {
+		info.Version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Commit = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
+
+//fields 构建信息对应的日志字段
+func (b BuildInfo) fields() []Field {
+	commit := b.Commit
+	if commit == "" {
+		commit = "unknown"
+	} else if b.Modified {
+		commit += "-dirty"
+	}
+	return []Field{F(VersionField, b.Version), F(CommitField, commit), F(GoVersionField, b.GoVersion)}
+}
+
+//EnableBuildInfoFields 开启版本号、提交id、go版本字段，附加到每条日志
+func EnableBuildInfoFields() {
+	fields := ReadBuildInfo().fields()
+	globalFieldsLock.Lock()
+	defer globalFieldsLock.Unlock()
+	buildFields = fields
+}
+
+//DisableBuildInfoFields 关闭构建信息字段
+func DisableBuildInfoFields() {
+	globalFieldsLock.Lock()
+	defer globalFieldsLock.Unlock()
+	buildFields = nil
+}
+
+//LogBuildInfo 输出一条带构建信息的启动日志，使每个日志文件都能确认是由哪个版本产生的
+//启动日志不受日志级别限制，总是输出
+func LogBuildInfo() {
+	info := ReadBuildInfo()
+	writeLogForced(NoticeLevel, "build info path="+info.Path, info.fields()...)
+}
@@ -0,0 +1,123 @@
+package gclog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+//CallerFormat 调用者文件路径的输出格式
+type CallerFormat int
+
+const (
+	//CallerShort 只输出文件名，exp: conn.go:42
+	CallerShort CallerFormat = iota
+	//CallerPackage 输出所在目录与文件名，exp: db/conn.go:42
+	CallerPackage
+	//CallerModule 输出相对模块根目录（go.mod所在目录）的路径，exp: internal/db/conn.go:42
+	CallerModule
+	//CallerFull 输出完整路径
+	CallerFull
+)
+
+var (
+	callerFormat    CallerFormat //调用者输出格式，由fileLock保护
+	moduleRootCache sync.Map     //目录对应的模块根目录，写日志时并发读写
+)
+
+//callerDisabled 不取调用者位置的日志级别，按位表示，原子读写
+var callerDisabled uint32
+
+//SetCallerEnabled 开启或关闭所有级别的调用者位置（文件:行号），默认开启
+//日志量很大时runtime.Caller的开销不可忽略，不需要位置信息时可以关闭
+func SetCallerEnabled(enabled bool) {
+	if enabled {
+		atomic.StoreUint32(&callerDisabled, 0)
+	} else {
+		atomic.StoreUint32(&callerDisabled, ^uint32(0))
+	}
+}
+
+//SetLevelCallerEnabled 开启或关闭某一级别的调用者位置
+//exp: gclog.SetLevelCallerEnabled(gclog.DebugLevel, false)
+func SetLevelCallerEnabled(level int, enabled bool) {
+	if level < VerbLevel || level > FatalLevel {
+		return
+	}
+	for {
+		old := atomic.LoadUint32(&callerDisabled)
+		val := old | 1<<uint(level)
+		if enabled {
+			val = old &^ (1 << uint(level))
+		}
+		if atomic.CompareAndSwapUint32(&callerDisabled, old, val) {
+			return
+		}
+	}
+}
+
+//callerEnabled 判断对应级别是否需要取调用者位置
+func callerEnabled(level int) bool {
+	return atomic.LoadUint32(&callerDisabled)&(1<<uint(level)) == 0
+}
+
+//SetCallerFormat 设置调用者文件路径的输出格式，默认CallerShort
+//不同包中存在同名文件时，可以使用CallerPackage或CallerModule区分
+func SetCallerFormat(format CallerFormat) {
+	fileLock.Lock()
+	defer fileLock.Unlock()
+	callerFormat = format
+}
+
+//formatCaller 按当前格式输出调用者文件路径，未取调用者位置时返回-，需要在持有fileLock时调用
+func formatCaller(file string) string {
+	if file == "" {
+		return "-"
+	}
+	switch callerFormat {
+	case CallerPackage:
+		dir, name := filepath.Split(file)
+		dir = filepath.Base(filepath.Clean(dir))
+		if dir == "." || dir == string(filepath.Separator) {
+			return name
+		}
+		return dir + "/" + name
+	case CallerModule:
+		root := moduleRoot(filepath.Dir(file))
+		if root == "" {
+			return file
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return file
+		}
+		return filepath.ToSlash(rel)
+	case CallerFull:
+		return file
+	}
+	return filepath.Base(file)
+}
+
+//moduleRoot 从dir开始向上查找go.mod所在目录，找不到（如使用-trimpath编译）时返回空字符串
+//结果按目录缓存
+func moduleRoot(dir string) string {
+	if root, ok := moduleRootCache.Load(dir); ok {
+		return root.(string)
+	}
+	root := ""
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			root = d
+			break
+		}
+		parent := filepath.Dir(d)
+		if parent == d || !strings.Contains(parent, string(filepath.Separator)) {
+			break
+		}
+		d = parent
+	}
+	moduleRootCache.Store(dir, root)
+	return root
+}
@@ -0,0 +1,161 @@
+package gclog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//cefSeverity 日志级别对应的CEF严重程度（0~10）
+var cefSeverity = []int{
+	VerbLevel:    0,
+	DebugLevel:   1,
+	InfoLevel:    3,
+	NoticeLevel:  4,
+	WarningLevel: 6,
+	ErrorLevel:   8,
+	FatalLevel:   10,
+}
+
+//cefNameMax CEF头部Name的最大长度
+const cefNameMax = 512
+
+//CEFEncoder Common Event Format编码器，用于将安全相关的日志直接发送到ArcSight、QRadar等SIEM
+//头部为设备厂商、产品、版本、事件类型ID、名称（消息的首行）与严重程度，扩展部分为rt（unix毫秒时间）、msg与各字段
+//exp: CEF:0|Acme|Gateway|1.2|login_failed|login failed|8|rt=1523174400123 msg=login failed caller=main.go:12 user=42
+type CEFEncoder struct {
+	Vendor  string //设备厂商
+	Product string //产品名称
+	Version string //产品版本
+	//SignatureField 作为事件类型ID的字段名，日志没有该字段时使用级别名称，exp: "event"
+	SignatureField string
+}
+
+//Encode 实现Encoder
+func (c *CEFEncoder) Encode(buf []byte, e *Entry) []byte {
+	signature := LevelName(e.Level)
+	if c.SignatureField != "" {
+		for _, f := range e.Fields {
+			if f.Key == c.SignatureField {
+				signature = formatCEFValue(f.Value)
+				break
+			}
+		}
+	}
+	name := e.Message
+	if i := strings.IndexAny(name, "\r\n"); i >= 0 {
+		name = name[:i]
+	}
+	if len(name) > cefNameMax {
+		name = name[:cefNameMax]
+	}
+	severity := 0
+	if e.Level >= VerbLevel && e.Level < len(cefSeverity) {
+		severity = cefSeverity[e.Level]
+	}
+
+	buf = append(buf, "CEF:0|"...)
+	for _, s := range []string{c.Vendor, c.Product, c.Version, signature, name} {
+		buf = appendCEFHeader(buf, s)
+		buf = append(buf, '|')
+	}
+	buf = strconv.AppendInt(buf, int64(severity), 10)
+	buf = append(buf, "|rt="...)
+	buf = strconv.AppendInt(buf, e.Time.UnixNano()/1e6, 10)
+	buf = append(buf, " msg="...)
+	buf = appendCEFValue(buf, e.Message)
+	if e.File != "" {
+		buf = append(buf, " caller="...)
+		buf = appendCEFValue(buf, formatCaller(e.File)+":"+strconv.Itoa(e.Line))
+	}
+	if e.Logger != "" {
+		buf = append(buf, " cat="...)
+		buf = appendCEFValue(buf, e.Logger)
+	}
+	if e.ID != "" {
+		buf = append(buf, " externalId="...)
+		buf = append(buf, e.ID...)
+	}
+	if e.Seq != 0 {
+		buf = append(buf, " seq="...)
+		buf = strconv.AppendUint(buf, e.Seq, 10)
+	}
+	for _, f := range e.Fields {
+		key := cefKey(f.Key)
+		if key == "" {
+			continue
+		}
+		buf = append(buf, ' ')
+		buf = append(buf, key...)
+		buf = append(buf, '=')
+		buf = appendCEFValue(buf, formatCEFValue(f.Value))
+	}
+	if e.Stack != "" {
+		buf = append(buf, " stack="...)
+		buf = appendCEFValue(buf, e.Stack)
+	}
+	return append(buf, '\n')
+}
+
+//appendCEFHeader 追加头部字段，转义竖线与反斜杠，换行替换为空格
+func appendCEFHeader(buf []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '|', '\\':
+			buf = append(buf, '\\', c)
+		case '\r', '\n':
+			buf = append(buf, ' ')
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return buf
+}
+
+//appendCEFValue 追加扩展字段的值，转义等号、反斜杠与换行
+func appendCEFValue(buf []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '=', '\\':
+			buf = append(buf, '\\', c)
+		case '\n':
+			buf = append(buf, '\\', 'n')
+		case '\r':
+			buf = append(buf, '\\', 'r')
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return buf
+}
+
+//cefKey CEF扩展字段的键只能包含字母与数字，去掉其他字符
+func cefKey(key string) string {
+	valid := true
+	for i := 0; i < len(key) && valid; i++ {
+		valid = isAlnum(key[i])
+	}
+	if valid {
+		return key
+	}
+	b := make([]byte, 0, len(key))
+	for i := 0; i < len(key); i++ {
+		if isAlnum(key[i]) {
+			b = append(b, key[i])
+		}
+	}
+	return string(b)
+}
+
+//isAlnum 判断是否为ASCII字母或数字
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+//formatCEFValue 格式化字段值，值不加引号，特殊字符由appendCEFValue转义
+func formatCEFValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
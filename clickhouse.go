@@ -0,0 +1,296 @@
+package gclog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//clickHouseTimeLayout time列的格式，UTC
+const clickHouseTimeLayout = "2006-01-02 15:04:05.000000"
+
+//ClickHouseOptions ClickHouse sink的设置
+type ClickHouseOptions struct {
+	TLS      *TLSOptions //https地址的证书设置，为nil时使用系统证书
+	Database string      //数据库，为空时使用用户的默认数据库
+	User     string
+	Password string
+	//AsyncInsert 为true时使用服务端的异步插入（async_insert），服务端合并多个客户端的小批次，适合大量进程同时写入
+	AsyncInsert bool
+	//WaitAsyncInsert 异步插入时等待数据写入表后才返回，为false时服务端收到数据即返回，服务端异常时可能丢失
+	WaitAsyncInsert bool
+	BatchSize       int           //一次插入的最多日志数，<=0时默认1000
+	FlushInterval   time.Duration //未满一批时的插入间隔，<=0时默认1s
+	MaxRetries      int           //插入失败时的重试次数，<0时不重试，为0时默认3
+	QueueSize       int           //等待插入的最多日志数，超出时丢弃日志，<=0时默认8192
+	Timeout         time.Duration //一次插入请求的超时，<=0时默认10s
+}
+
+//ClickHouseSink 通过HTTP接口将日志批量插入ClickHouse表的sink，以JSONEachRow格式写入
+//行的列为time、level、logger、caller、msg、fields（字段值转为字符串）、stack、id、seq，表中没有的列会被忽略
+//插入失败（网络错误或5xx）时间隔1s、2s、4s…重试，4xx错误（表不存在、列类型不符等）不重试
+//exp:
+//
+//	CREATE TABLE logs (
+//		time DateTime64(6, 'UTC'), level LowCardinality(String), logger LowCardinality(String),
+//		caller String, msg String, fields Map(String, String), stack String, id String, seq UInt64
+//	) ENGINE = MergeTree ORDER BY (level, time) TTL toDateTime(time) + INTERVAL 30 DAY
+type ClickHouseSink struct {
+	endpoint  string
+	opts      ClickHouseOptions
+	client    *http.Client
+	queue     chan *buffer
+	done      chan struct{}
+	closeOnce sync.Once
+	dropped   uint64 //丢弃的日志数，原子读写
+	sent      uint64 //插入成功的日志数，原子读写
+}
+
+//clickHouseError 服务端返回的错误，4xx不重试
+type clickHouseError struct {
+	status int
+	msg    string
+}
+
+func (e *clickHouseError) Error() string {
+	return fmt.Sprintf("gclog: clickhouse returned %d: %s", e.status, e.msg)
+}
+
+//NewClickHouseSink 创建插入table的ClickHouse sink，addr为HTTP接口地址，exp: http://127.0.0.1:8123
+//exp: s, err := gclog.NewClickHouseSink("http://127.0.0.1:8123", "logs", gclog.ClickHouseOptions{AsyncInsert: true})
+func NewClickHouseSink(addr, table string, opts ClickHouseOptions) (*ClickHouseSink, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("gclog: invalid clickhouse address %q", addr)
+	}
+	for _, c := range []byte(table) {
+		if !isAlnum(c) && c != '_' && c != '.' {
+			return nil, fmt.Errorf("gclog: invalid clickhouse table name %q", table)
+		}
+	}
+	if table == "" {
+		return nil, fmt.Errorf("gclog: empty clickhouse table name")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 3
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 8192
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if opts.TLS != nil {
+		if transport.TLSClientConfig, err = opts.TLS.config(u.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+	query := url.Values{}
+	query.Set("query", "INSERT INTO "+table+" FORMAT JSONEachRow")
+	query.Set("input_format_skip_unknown_fields", "1")
+	if opts.Database != "" {
+		query.Set("database", opts.Database)
+	}
+	if opts.AsyncInsert {
+		query.Set("async_insert", "1")
+		if opts.WaitAsyncInsert {
+			query.Set("wait_for_async_insert", "1")
+		} else {
+			query.Set("wait_for_async_insert", "0")
+		}
+	}
+	u.RawQuery = query.Encode()
+	s := &ClickHouseSink{
+		endpoint: u.String(),
+		opts:     opts,
+		client:   &http.Client{Transport: transport, Timeout: opts.Timeout},
+		queue:    make(chan *buffer, opts.QueueSize),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+//SendToClickHouse 创建ClickHouse sink并注册，同一地址与表重复调用时替换之前的sink
+//exp: gclog.SendToClickHouse("http://127.0.0.1:8123", "logs", gclog.ClickHouseOptions{})
+func SendToClickHouse(addr, table string, opts ClickHouseOptions) (*ClickHouseSink, error) {
+	s, err := NewClickHouseSink(addr, table, opts)
+	if err != nil {
+		return nil, err
+	}
+	if old := addSink("clickhouse:"+addr+"/"+table, s); old != nil {
+		closeSink(old)
+	}
+	return s, nil
+}
+
+//Write 实现Sink，将日志编码为一行JSON放入等待队列，队列满时丢弃并返回错误
+func (s *ClickHouseSink) Write(e *Entry, line []byte) error {
+	buf := getBuffer()
+	buf.b = appendClickHouseRow(buf.b, e)
+	select {
+	case s.queue <- buf:
+		return nil
+	default:
+		putBuffer(buf)
+		atomic.AddUint64(&s.dropped, 1)
+		return errSinkFull
+	}
+}
+
+//Close 实现Sink，插入队列中剩余的日志后返回
+func (s *ClickHouseSink) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.queue)
+	})
+	<-s.done
+	return nil
+}
+
+//Dropped 返回因队列满或插入失败丢弃的日志数
+func (s *ClickHouseSink) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+//Sent 返回插入成功的日志数
+func (s *ClickHouseSink) Sent() uint64 {
+	return atomic.LoadUint64(&s.sent)
+}
+
+//appendClickHouseRow 追加JSONEachRow格式的一行
+func appendClickHouseRow(buf []byte, e *Entry) []byte {
+	buf = append(buf, `{"time":"`...)
+	buf = e.Time.UTC().AppendFormat(buf, clickHouseTimeLayout)
+	buf = append(buf, `","level":`...)
+	buf = appendJSONString(buf, LevelName(e.Level))
+	buf = append(buf, `,"logger":`...)
+	buf = appendJSONString(buf, e.Logger)
+	buf = append(buf, `,"caller":`...)
+	if e.File != "" {
+		buf = appendJSONString(buf, formatCaller(e.File)+":"+strconv.Itoa(e.Line))
+	} else {
+		buf = append(buf, `""`...)
+	}
+	buf = append(buf, `,"msg":`...)
+	buf = appendJSONString(buf, e.Message)
+	buf = append(buf, `,"fields":{`...)
+	for i, f := range e.Fields {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = appendJSONString(buf, f.Key)
+		buf = append(buf, ':')
+		buf = appendJSONString(buf, formatCEFValue(f.Value))
+	}
+	buf = append(buf, `},"stack":`...)
+	buf = appendJSONString(buf, e.Stack)
+	buf = append(buf, `,"id":`...)
+	buf = appendJSONString(buf, e.ID)
+	buf = append(buf, `,"seq":`...)
+	buf = strconv.AppendUint(buf, e.Seq, 10)
+	return append(buf, "}\n"...)
+}
+
+//run 插入goroutine，满一批或到达FlushInterval时插入
+func (s *ClickHouseSink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.opts.FlushInterval)
+	defer ticker.Stop()
+	var body []byte
+	n := 0
+	var lastErr string
+	flush := func() {
+		if n == 0 {
+			return
+		}
+		err := s.insert(body)
+		if err == nil {
+			atomic.AddUint64(&s.sent, uint64(n))
+			lastErr = ""
+		} else {
+			atomic.AddUint64(&s.dropped, uint64(n))
+			//相同的错误只提示一次，避免服务端不可用时刷屏
+			if err.Error() != lastErr {
+				lastErr = err.Error()
+				Warning("clickhouse sink dropped %d entries, because %s", n, lastErr)
+			}
+		}
+		body, n = body[:0], 0
+	}
+	for {
+		select {
+		case buf, ok := <-s.queue:
+			if !ok {
+				flush()
+				return
+			}
+			body = append(body, buf.b...)
+			putBuffer(buf)
+			n++
+			if n >= s.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+//insert 插入一批日志，失败时按MaxRetries重试，4xx错误不重试
+func (s *ClickHouseSink) insert(body []byte) error {
+	wait := time.Second
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = s.post(body); err == nil {
+			return nil
+		}
+		if e, ok := err.(*clickHouseError); ok && e.status < 500 {
+			return err
+		}
+		if attempt >= s.opts.MaxRetries {
+			return err
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+//post 发送一次插入请求
+func (s *ClickHouseSink) post(body []byte) error {
+	req, err := http.NewRequest("POST", s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if s.opts.User != "" {
+		req.Header.Set("X-ClickHouse-User", s.opts.User)
+		req.Header.Set("X-ClickHouse-Key", s.opts.Password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &clickHouseError{status: resp.StatusCode, msg: string(bytes.TrimSpace(msg))}
+}
@@ -0,0 +1,117 @@
+//gclogbench 运行gclog写日志热路径的基准测试，输出每条日志的耗时与堆分配次数
+//exp: go run ./cmd/gclogbench -out /dev/null
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/bailiyang/gclog"
+)
+
+//benchmark 一项基准测试
+type benchmark struct {
+	name  string
+	setup func() //在默认配置的基础上修改配置，可以为nil
+	run   func(b *testing.B)
+}
+
+var benchmarks = []benchmark{
+	{"text/no-args", nil, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			gclog.Notice("user login")
+		}
+	}},
+	{"text/args", nil, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			gclog.Notice("user %d login from %s", i, "10.0.0.1")
+		}
+	}},
+	{"text/logger-fields", nil, func(b *testing.B) {
+		l := gclog.NewLogger("bench").With(gclog.F("shard", 3), gclog.F("region", "cn-north"))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			l.Notice("user login")
+		}
+	}},
+	{"text/no-caller", func() { gclog.SetCallerEnabled(false) }, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			gclog.Notice("user login")
+		}
+	}},
+	{"json/no-args", func() { gclog.SetEncoder(&gclog.JSONEncoder{}) }, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			gclog.Notice("user login")
+		}
+	}},
+	{"console/no-args", func() { gclog.SetEncoder(&gclog.ConsoleEncoder{Color: gclog.ColorNever}) }, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			gclog.Notice("user login")
+		}
+	}},
+	{"text/async", func() { gclog.SetAsync(0) }, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			gclog.Notice("user login")
+		}
+		gclog.Flush()
+	}},
+	{"text/async-parallel", func() { gclog.SetAsync(0) }, func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				gclog.Notice("user login")
+			}
+		})
+		gclog.Flush()
+	}},
+	{"text/async-channel-parallel", func() {
+		gclog.SetAsyncQueueKind(gclog.AsyncQueueChannel)
+		gclog.SetAsync(0)
+	}, func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				gclog.Notice("user login")
+			}
+		})
+		gclog.Flush()
+	}},
+	{"filtered/below-level", nil, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			gclog.Debug("user %d login", i)
+		}
+	}},
+}
+
+//reset 恢复默认的同步写入、异步队列、文本编码与调用者设置
+func reset() {
+	gclog.DisableAsync()
+	gclog.SetAsyncQueueKind(gclog.AsyncQueueRing)
+	gclog.SetEncoder(nil)
+	gclog.SetCallerEnabled(true)
+}
+
+func main() {
+	out := flag.String("out", os.DevNull, "log file written by the benchmarks")
+	flag.Parse()
+
+	if err := gclog.InitLogFile(*out); err != nil {
+		fmt.Fprintf(os.Stderr, "init log file %s failed: %s\n", *out, err)
+		os.Exit(1)
+	}
+	defer gclog.CloseFile()
+
+	fmt.Printf("%-28s %12s %14s %12s %14s\n", "benchmark", "iterations", "ns/op", "B/op", "allocs/op")
+	for _, bm := range benchmarks {
+		reset()
+		if bm.setup != nil {
+			bm.setup()
+		}
+		run := bm.run
+		r := testing.Benchmark(func(b *testing.B) {
+			b.ReportAllocs()
+			run(b)
+		})
+		fmt.Printf("%-28s %12d %14d %12d %14d\n", bm.name, r.N, r.NsPerOp(), r.AllocedBytesPerOp(), r.AllocsPerOp())
+	}
+}
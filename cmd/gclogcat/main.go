@@ -0,0 +1,268 @@
+//gclogcat 按时间范围、级别、正则与字段筛选gclog的日志文件（包括切分后的文件、gzip压缩的文件与二进制格式的文件），输出到标准输出
+//-since时使用日志文件的时间索引（见gclog.SetTimeIndex）跳过之前的内容
+//-merge时将所有文件（多个切分文件或多个实例的文件）按时间合并输出，否则按参数顺序依次输出
+//exp: go run ./cmd/gclogcat -since 2018-04-08T14:02:00+08:00 -until 2018-04-08T14:05:00+08:00 -level warning -field user=42 app_*.log.gz app.log
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bailiyang/gclog"
+	"github.com/bailiyang/gclog/reader"
+)
+
+//fieldFlags 可以重复指定的-field参数
+type fieldFlags []string
+
+func (f *fieldFlags) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *fieldFlags) Set(v string) error {
+	if !strings.Contains(v, "=") {
+		return fmt.Errorf("field filter must be key=value, got %q", v)
+	}
+	*f = append(*f, v)
+	return nil
+}
+
+//filter 筛选条件
+type filter struct {
+	since  time.Time
+	until  time.Time
+	level  int
+	re     *regexp.Regexp
+	fields map[string]string
+}
+
+func main() {
+	var (
+		since  = flag.String("since", "", "only entries at or after this time: RFC3339, \"2006-01-02 15:04:05\", \"2006-01-02\" or a duration ago such as 30m")
+		until  = flag.String("until", "", "only entries before this time, same formats as -since")
+		level  = flag.String("level", "", "minimum level, such as warning")
+		grep   = flag.String("grep", "", "regular expression matched against the whole entry")
+		output = flag.String("o", "raw", "output format: raw, text, json, csv or color")
+		header = flag.String("header", "", "text header template used when writing, see gclog.SetHeaderTemplate")
+		layout = flag.String("time-layout", "", "text time layout used when writing, see gclog.SetTimeLayout")
+		merge  = flag.Bool("merge", false, "merge all files into one chronologically ordered stream")
+		cols   = flag.String("columns", "", "comma separated columns of -o csv: time, level, caller, msg, seq, id, logger, stack or a field name")
+		fields fieldFlags
+	)
+	flag.Var(&fields, "field", "key=value field filter, can be repeated")
+	flag.Parse()
+
+	f := filter{level: gclog.VerbLevel, fields: make(map[string]string)}
+	var err error
+	if f.since, err = parseTime(*since, time.Local); err != nil {
+		fatal(2, "invalid -since: %s", err)
+	}
+	if f.until, err = parseTime(*until, time.Local); err != nil {
+		fatal(2, "invalid -until: %s", err)
+	}
+	if *level != "" {
+		var ok bool
+		if f.level, ok = gclog.ParseLevel(*level); !ok {
+			fatal(2, "unknown level %q", *level)
+		}
+	}
+	if *grep != "" {
+		if f.re, err = regexp.Compile(*grep); err != nil {
+			fatal(2, "invalid -grep: %s", err)
+		}
+	}
+	for _, kv := range fields {
+		i := strings.IndexByte(kv, '=')
+		f.fields[kv[:i]] = kv[i+1:]
+	}
+	var enc gclog.Encoder
+	switch *output {
+	case "raw":
+	case "text":
+		enc = &gclog.TextEncoder{}
+	case "json":
+		enc = &gclog.JSONEncoder{}
+	case "csv":
+		csv := &gclog.CSVEncoder{}
+		if *cols != "" {
+			csv.Columns = strings.Split(*cols, ",")
+		}
+		enc = csv
+	case "color":
+		enc = &gclog.ConsoleEncoder{Color: gclog.ColorAlways}
+	default:
+		fatal(2, "unknown output format %q", *output)
+	}
+
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	out := bufio.NewWriter(os.Stdout)
+	if csv, ok := enc.(*gclog.CSVEncoder); ok {
+		out.Write(csv.Header(nil))
+	}
+	status := 0
+	var readers []*reader.Reader
+	var closers []func()
+	for _, name := range files {
+		in, closer, err := open(name, f.since)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "open %s failed: %s\n", name, err)
+			status = 1
+			continue
+		}
+		closers = append(closers, closer)
+		r := reader.NewReader(in)
+		if err := r.SetHeaderTemplate(*header); err != nil {
+			fatal(2, "invalid -header: %s", err)
+		}
+		r.SetTimeLayout(*layout)
+		if *merge {
+			readers = append(readers, r)
+		} else if err := cat(out, r, f, enc); err != nil {
+			fmt.Fprintf(os.Stderr, "read %s failed: %s\n", name, err)
+			status = 1
+		}
+	}
+	if *merge {
+		if err := cat(out, reader.NewMerger(readers...), f, enc); err != nil {
+			fmt.Fprintf(os.Stderr, "merge failed: %s\n", err)
+			status = 1
+		}
+	}
+	out.Flush()
+	for _, closer := range closers {
+		closer()
+	}
+	os.Exit(status)
+}
+
+//source 日志流，Reader或Merger
+type source interface {
+	Next() (*gclog.Entry, error)
+	Raw() []byte
+}
+
+//cat 输出日志流中符合条件的日志
+func cat(out io.Writer, r source, f filter, enc gclog.Encoder) error {
+	var buf []byte
+	for {
+		e, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !f.match(e, r.Raw()) {
+			continue
+		}
+		if enc == nil {
+			out.Write(r.Raw())
+			continue
+		}
+		buf = enc.Encode(buf[:0], e)
+		out.Write(buf)
+	}
+}
+
+//open 打开文件，gzip压缩的文件自动解压，指定了since且有时间索引时跳过之前的内容
+func open(name string, since time.Time) (io.Reader, func(), error) {
+	var file io.ReadCloser = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !since.IsZero() {
+			if _, err := reader.SeekTime(f, since); err != nil {
+				f.Close()
+				return nil, nil, err
+			}
+		}
+		file = f
+	}
+	br := bufio.NewReader(file)
+	magic, _ := br.Peek(2)
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return zr, func() { zr.Close(); file.Close() }, nil
+	}
+	return br, func() { file.Close() }, nil
+}
+
+//match 判断日志是否符合筛选条件
+func (f filter) match(e *gclog.Entry, raw []byte) bool {
+	if e.Level < f.level {
+		return false
+	}
+	if !f.since.IsZero() && e.Time.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && !e.Time.Before(f.until) {
+		return false
+	}
+	if f.re != nil && !f.re.Match(raw) {
+		return false
+	}
+	for key, value := range f.fields {
+		if !hasField(e, key, value) {
+			return false
+		}
+	}
+	return true
+}
+
+//hasField 判断日志是否有值为value的字段
+func hasField(e *gclog.Entry, key, value string) bool {
+	switch key {
+	case gclog.LoggerField:
+		return e.Logger == value
+	case gclog.IDField:
+		return e.ID == value
+	}
+	for _, field := range e.Fields {
+		if field.Key == key && fmt.Sprint(field.Value) == value {
+			return true
+		}
+	}
+	return false
+}
+
+//parseTime 解析时间参数，支持RFC3339、本地时间与“多久之前”的时长
+func parseTime(s string, loc *time.Location) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02", "2006/01/02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", s)
+}
+
+//fatal 输出错误后退出
+func fatal(code int, format string, v ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", v...)
+	os.Exit(code)
+}
@@ -0,0 +1,66 @@
+//gclogdecrypt 解密gclog加密的日志文件（包括信封加密），输出到标准输出，信封加密时key为主密钥
+//exp: go run ./cmd/gclogdecrypt -key-file /etc/app/log.key app.log app_2018_04_08_16.log
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bailiyang/gclog"
+)
+
+func main() {
+	keyHex := flag.String("key", os.Getenv("GCLOG_ENCRYPTION_KEY"), "hex encoded key, defaults to $GCLOG_ENCRYPTION_KEY")
+	keyFile := flag.String("key-file", "", "file containing the hex encoded key")
+	flag.Parse()
+
+	if *keyFile != "" {
+		data, err := os.ReadFile(*keyFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "read key file %s failed: %s\n", *keyFile, err)
+			os.Exit(1)
+		}
+		*keyHex = string(bytes.TrimSpace(data))
+	}
+	key, err := hex.DecodeString(*keyHex)
+	if err != nil || len(key) == 0 {
+		fmt.Fprintln(os.Stderr, "a hex encoded key is required, use -key, -key-file or $GCLOG_ENCRYPTION_KEY")
+		os.Exit(2)
+	}
+
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	status := 0
+	for _, name := range files {
+		if err := decrypt(name, key); err != nil {
+			fmt.Fprintf(os.Stderr, "decrypt %s failed: %s\n", name, err)
+			status = 1
+		}
+	}
+	os.Exit(status)
+}
+
+//decrypt 解密一个文件，-表示标准输入
+func decrypt(name string, key []byte) error {
+	var in io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	r, err := gclog.NewDecryptReader(in, key)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(os.Stdout, r)
+	return err
+}
@@ -0,0 +1,28 @@
+//gclogrecover 读取gclog飞行记录文件，将其中的日志按时间顺序输出到标准输出
+//exp: go run ./cmd/gclogrecover /var/log/app.flight.prev
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bailiyang/gclog"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: gclogrecover file...")
+		os.Exit(2)
+	}
+	status := 0
+	for _, name := range os.Args[1:] {
+		data, err := gclog.ReadFlightRecorder(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "read %s failed: %s\n", name, err)
+			status = 1
+			continue
+		}
+		os.Stdout.Write(data)
+	}
+	os.Exit(status)
+}
@@ -0,0 +1,64 @@
+//gclogship 跟踪gclog的日志文件（处理切分），将新增的日志通过TCP（可选TLS）转发到远程，进度记录在-checkpoint文件中
+//写日志的服务不需要修改，收到SIGINT、SIGTERM时记录进度并发送完队列中的日志后退出
+//exp: go run ./cmd/gclogship -addr log.example.com:6514 -ca ca.pem -checkpoint /var/lib/app.ckpt /var/log/app.log
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/bailiyang/gclog"
+	"github.com/bailiyang/gclog/shipper"
+)
+
+func main() {
+	var (
+		network    = flag.String("network", "tcp", "network of -addr: tcp, tcp4, tcp6 or unix")
+		addr       = flag.String("addr", "", "address to forward entries to")
+		ca         = flag.String("ca", "", "CA file, enables TLS")
+		compress   = flag.Bool("gzip", false, "send gzip compressed batches, see gclog.NewBatchReader")
+		checkpoint = flag.String("checkpoint", "", "file recording the shipping progress, empty to always ship from the beginning")
+		poll       = flag.Duration("poll", time.Second, "interval to check the file for new entries")
+		header     = flag.String("header", "", "text header template used when writing, see gclog.SetHeaderTemplate")
+		layout     = flag.String("time-layout", "", "text time layout used when writing, see gclog.SetTimeLayout")
+	)
+	flag.Parse()
+	if *addr == "" || flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: gclogship -addr host:port [flags] file")
+		os.Exit(2)
+	}
+
+	opts := gclog.NetOptions{}
+	if *ca != "" {
+		opts.TLS = &gclog.TLSOptions{CAFile: *ca}
+	}
+	if *compress {
+		opts.Compression = gclog.CompressGzip
+	}
+	sink, err := gclog.NewNetSink(*network, *addr, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "create sink failed: %s\n", err)
+		os.Exit(1)
+	}
+	s, err := shipper.New(flag.Arg(0), sink, shipper.Options{
+		Checkpoint:     *checkpoint,
+		PollInterval:   *poll,
+		HeaderTemplate: *header,
+		TimeLayout:     *layout,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "create shipper failed: %s\n", err)
+		os.Exit(1)
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	<-ch
+	s.Close()
+	sink.Close()
+	fmt.Fprintf(os.Stderr, "shipped %d entries, %d dropped by the sink\n", s.Shipped(), sink.Dropped())
+}
@@ -0,0 +1,138 @@
+package gclog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+//Compression 远程sink的批次压缩方式
+type Compression int
+
+const (
+	//CompressNone 不压缩（默认）
+	CompressNone Compression = iota
+	//CompressGzip gzip压缩，标准库不包含zstd，需要zstd时可以在接收端前加代理转换
+	CompressGzip
+)
+
+//压缩批次的帧格式：1字节标志（0未压缩，1 gzip） + 4字节大端长度 + 内容
+//小于压缩阈值的批次不压缩，接收端使用NewBatchReader还原
+const (
+	batchRaw  = 0
+	batchGzip = 1
+	//defaultCompressThreshold 默认的压缩阈值，过小的批次压缩收益低于开销
+	defaultCompressThreshold = 1024
+)
+
+var errBadBatch = errors.New("gclog: bad compressed batch")
+
+//batchCompressor 压缩批次，复用gzip.Writer，只在发送goroutine中使用
+type batchCompressor struct {
+	threshold int
+	out       bytes.Buffer
+	zw        *gzip.Writer
+}
+
+//newBatchCompressor 创建压缩器，threshold<=0时使用默认阈值
+func newBatchCompressor(threshold int) *batchCompressor {
+	if threshold <= 0 {
+		threshold = defaultCompressThreshold
+	}
+	zw, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+	return &batchCompressor{threshold: threshold, zw: zw}
+}
+
+//gzip 将批次压缩为gzip，小于阈值时返回false，结果在下次调用前有效
+func (c *batchCompressor) gzip(batch []byte) ([]byte, bool) {
+	if len(batch) < c.threshold {
+		return batch, false
+	}
+	c.out.Reset()
+	c.zw.Reset(&c.out)
+	c.zw.Write(batch)
+	c.zw.Close()
+	//压缩后反而变大时不压缩
+	if c.out.Len() >= len(batch) {
+		return batch, false
+	}
+	return c.out.Bytes(), true
+}
+
+//frame 将批次编码为帧
+func (c *batchCompressor) frame(dst, batch []byte) []byte {
+	payload, compressed := c.gzip(batch)
+	flag := byte(batchRaw)
+	if compressed {
+		flag = batchGzip
+	}
+	dst = append(dst, flag)
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(payload)))
+	return append(dst, payload...)
+}
+
+//batchReader 解码压缩批次帧的reader
+type batchReader struct {
+	r   *bufio.Reader
+	buf []byte //当前批次中尚未读取的内容
+	zr  *gzip.Reader
+}
+
+//NewBatchReader 返回还原压缩批次流的reader，用于接收开启了压缩的NetSink发送的数据
+//exp:
+//
+//	conn, _ := ln.Accept()
+//	io.Copy(os.Stdout, gclog.NewBatchReader(conn))
+func NewBatchReader(r io.Reader) io.Reader {
+	return &batchReader{r: bufio.NewReader(r)}
+}
+
+//Read 实现io.Reader
+func (b *batchReader) Read(p []byte) (int, error) {
+	for len(b.buf) == 0 {
+		if err := b.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, b.buf)
+	b.buf = b.buf[n:]
+	return n, nil
+}
+
+//next 读取下一个批次
+func (b *batchReader) next() error {
+	var head [5]byte
+	if _, err := io.ReadFull(b.r, head[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return errBadBatch
+		}
+		return err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(head[1:]))
+	if _, err := io.ReadFull(b.r, payload); err != nil {
+		return errBadBatch
+	}
+	switch head[0] {
+	case batchRaw:
+		b.buf = payload
+	case batchGzip:
+		var err error
+		if b.zr == nil {
+			b.zr, err = gzip.NewReader(bytes.NewReader(payload))
+		} else {
+			err = b.zr.Reset(bytes.NewReader(payload))
+		}
+		if err != nil {
+			return errBadBatch
+		}
+		if b.buf, err = io.ReadAll(b.zr); err != nil {
+			return errBadBatch
+		}
+	default:
+		return errBadBatch
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package gclog
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+//LoggerConfig 日志库当前生效的配置，可以直接序列化为json，用于回答线上进程“实际在怎样记日志”
+//与Status不同，只包含配置，不包含计数等运行状态
+type LoggerConfig struct {
+	Level            string   `json:"level"`         //日志级别
+	OutputLevel      string   `json:"output_level"`  //主输出的最低级别，低于该级别的日志只投递给sink
+	WriteToFile      bool     `json:"write_to_file"` //是否输出到文件
+	FileName         string   `json:"file_name,omitempty"`
+	Encoder          string   `json:"encoder"`                     //编码器类型
+	Header           string   `json:"header"`                      //TextEncoder的日志头模板
+	TimeLayout       string   `json:"time_layout,omitempty"`       //日志时间格式，为空时使用各编码器的默认格式
+	TimeLocation     string   `json:"time_location"`               //日志时间与切分文件名使用的时区
+	ConsoleSplit     string   `json:"console_split,omitempty"`     //控制台分流级别，低于该级别的日志输出到标准输出，为空时不分流
+	StackTrace       string   `json:"stack_trace,omitempty"`       //附加调用栈的最低级别，为空时关闭
+	SyncLevel        string   `json:"sync_level,omitempty"`        //写入后立即落盘的最低级别，为空时关闭
+	Async            bool     `json:"async"`                       //是否为异步写入
+	MultiProcess     bool     `json:"multi_process"`               //是否多进程共享日志文件
+	Encrypted        bool     `json:"encrypted"`                   //是否加密日志文件
+	Signed           bool     `json:"signed"`                      //是否为日志附加HMAC签名
+	AuditFile        string   `json:"audit_file,omitempty"`        //审计日志文件，为空时审计日志写入主输出
+	Suppress         []string `json:"suppress,omitempty"`          //屏蔽规则的正则
+	SampleFirst      int      `json:"sample_first,omitempty"`      //采样时每秒同一消息全部输出的条数，0为不采样
+	SampleThereafter int      `json:"sample_thereafter,omitempty"` //采样时超过SampleFirst后每多少条输出一条
+
+	FileMode string `json:"file_mode"` //新建日志文件的权限，exp: 0640，未调用SetFileMode时再受umask影响
+	DirMode  string `json:"dir_mode"`  //新建目录的权限，未调用SetDirMode时再受umask影响
+	FileUID  int    `json:"file_uid"`  //新建文件与目录的属主，-1为不修改
+	FileGID  int    `json:"file_gid"`  //新建文件与目录的属组，-1为不修改
+
+	Hooks        int `json:"hooks"`        //已注册的前置与后置钩子数
+	Filters      int `json:"filters"`      //已注册的过滤函数数
+	Transformers int `json:"transformers"` //已注册的全局变换函数数
+	Enrichers    int `json:"enrichers"`    //已注册的Enricher数
+
+	SliceMode     string        `json:"slice_mode"`               //切分方式：interval、weekly、iso-week、monthly
+	SliceInterval time.Duration `json:"slice_interval"`           //按间隔切分时的间隔
+	SliceWeekday  string        `json:"slice_weekday,omitempty"`  //每周切分时的切分日
+	SliceLines    int64         `json:"slice_lines,omitempty"`    //切分的行数，0为不按行数切分
+	RotationDelay time.Duration `json:"rotation_delay,omitempty"` //本进程的切分延迟（SetRotationJitter）
+
+	StorageTime   time.Duration    `json:"storage_time"`             //日志保存的时间
+	MaxFiles      int              `json:"max_files,omitempty"`      //切分出的文件最多保留的个数
+	MaxTotalSize  int64            `json:"max_total_size,omitempty"` //切分出的文件最多保留的总大小
+	DeleteDryRun  bool             `json:"delete_dry_run"`           //清理过期日志时是否只报告
+	Retention     *RetentionPolicy `json:"retention,omitempty"`      //分级保留策略
+	ArchiveTarget string           `json:"archive_target,omitempty"` //归档目标类型，为空时没有设置归档器
+	ArchivePrefix string           `json:"archive_prefix,omitempty"`
+	ArchiveGzip   bool             `json:"archive_gzip,omitempty"`
+	DiskMinFree   uint64           `json:"disk_min_free,omitempty"` //SetDiskGuard的最小剩余空间，0为不检查
+	DiskErrorOnly bool             `json:"disk_error_only,omitempty"`
+
+	Sinks []SinkConfig `json:"sinks,omitempty"` //已注册的sink
+}
+
+//SinkConfig 一个sink的配置，RouteLevels的文件附带级别范围与切分设置
+type SinkConfig struct {
+	Name          string        `json:"name"`
+	Type          string        `json:"type"`
+	MinLevel      string        `json:"min_level,omitempty"`
+	MaxLevel      string        `json:"max_level,omitempty"`
+	SliceInterval time.Duration `json:"slice_interval,omitempty"` //为0时与主日志文件相同
+	SliceLines    int64         `json:"slice_lines,omitempty"`    //为0时与主日志文件相同
+	StorageTime   time.Duration `json:"storage_time,omitempty"`   //为0时与主日志文件相同
+}
+
+//Config 返回日志库当前生效的完整配置
+//exp:
+//
+//	b, _ := json.Marshal(gclog.Config())
+//	w.Write(b)
+func Config() LoggerConfig {
+	c := LoggerConfig{
+		Level:        LevelName(GetLogLevel()),
+		OutputLevel:  LevelName(int(atomic.LoadInt32(&outputLevel))),
+		TimeLocation: now().Location().String(),
+		MultiProcess: multiProcessEnabled(),
+		Encrypted:    loadEncryptConfig().enabled(),
+		Signed:       loadHMACKey() != nil,
+		SliceLines:   atomic.LoadInt64(&logSliceLines),
+		StorageTime:  logStorageTime,
+		DeleteDryRun: isDeleteDryRun(),
+	}
+	if level := int(atomic.LoadInt32(&syncLevel)); level <= FatalLevel {
+		c.SyncLevel = LevelName(level)
+	}
+	if level := int(atomic.LoadInt32(&consoleSplitLevel)); level >= 0 {
+		c.ConsoleSplit = LevelName(level)
+	}
+	c.RotationDelay = rotationDelay()
+	c.MaxFiles, c.MaxTotalSize = retentionLimits()
+	c.SampleFirst, c.SampleThereafter = samplingConfig()
+
+	fileLock.RLock()
+	c.WriteToFile, c.FileName = writeToFile, fileName
+	c.Encoder = fmt.Sprintf("%T", encoder)
+	c.Header, c.TimeLayout = header.tpl, timeLayout
+	fileLock.RUnlock()
+
+	p := loadFilePerm()
+	c.FileMode, c.DirMode = fmt.Sprintf("%04o", p.fileMode), fmt.Sprintf("%04o", p.dirMode)
+	c.FileUID, c.FileGID = p.uid, p.gid
+
+	pre, _ := preHooks.Load().([]Hook)
+	post, _ := postHooks.Load().([]Hook)
+	enrich, _ := enrichers.Load().([]Enricher)
+	c.Hooks, c.Enrichers = len(pre)+len(post), len(enrich)
+	c.Filters, c.Transformers = len(loadFilters()), len(loadTransformers())
+
+	levelLock.Lock()
+	if stackTraceEnable {
+		c.StackTrace = LevelName(stackTraceLevel)
+	}
+	levelLock.Unlock()
+
+	asyncLock.RLock()
+	c.Async = asyncQueue != nil
+	asyncLock.RUnlock()
+
+	s := mainSchedule()
+	c.SliceInterval = s.interval
+	switch {
+	case s.mode == sliceWeekly && s.isoWeek:
+		c.SliceMode, c.SliceWeekday = "iso-week", s.weekday.String()
+	case s.mode == sliceWeekly:
+		c.SliceMode, c.SliceWeekday = "weekly", s.weekday.String()
+	case s.mode == sliceMonthly:
+		c.SliceMode = "monthly"
+	default:
+		c.SliceMode = "interval"
+	}
+
+	retentionLock.Lock()
+	if retentionPolicy != nil {
+		p := *retentionPolicy
+		c.Retention = &p
+	}
+	retentionLock.Unlock()
+
+	archiverLock.Lock()
+	if a := currentArchiver; a != nil {
+		c.ArchiveTarget = fmt.Sprintf("%T", a.target)
+		c.ArchivePrefix, c.ArchiveGzip = a.opts.Prefix, a.opts.Gzip
+	}
+	archiverLock.Unlock()
+
+	diskGuardLock.Lock()
+	c.DiskMinFree, c.DiskErrorOnly = diskMinFree, diskErrorOnly
+	diskGuardLock.Unlock()
+
+	auditLock.RLock()
+	if auditSink != nil {
+		c.AuditFile = auditSink.FileName()
+	}
+	auditLock.RUnlock()
+
+	for _, r := range loadSuppressRules() {
+		c.Suppress = append(c.Suppress, r.re.String())
+	}
+	for _, ns := range loadSinks() {
+		sc := SinkConfig{Name: ns.name, Type: fmt.Sprintf("%T", ns.sink)}
+		if f, ok := ns.sink.(*FileSink); ok {
+			f.lock.RLock()
+			sc.MinLevel, sc.MaxLevel = LevelName(f.minLevel), LevelName(f.maxLevel)
+			sc.SliceInterval, sc.StorageTime = f.sliceInterval, f.storageTime
+			f.lock.RUnlock()
+			sc.SliceLines = atomic.LoadInt64(&f.sliceLines)
+		}
+		c.Sinks = append(c.Sinks, sc)
+	}
+	return c
+}
+
+//String 输出json格式的配置
+func (c LoggerConfig) String() string {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
+
+//DumpConfig 将当前生效的配置以json写入日志，不受当前日志级别限制，可以由业务的管理接口调用，kill -QUIT的诊断信息中同样包含
+func DumpConfig() {
+	writeLogForced(WarningLevel, "config dump\n"+Config().String())
+}
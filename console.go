@@ -0,0 +1,134 @@
+package gclog
+
+import (
+	"strconv"
+)
+
+//ANSI颜色
+const (
+	colorReset   = "\x1b[0m"
+	colorDim     = "\x1b[2m"
+	colorRed     = "\x1b[31m"
+	colorGreen   = "\x1b[32m"
+	colorYellow  = "\x1b[33m"
+	colorBlue    = "\x1b[34m"
+	colorMagenta = "\x1b[35m"
+	colorCyan    = "\x1b[36m"
+	colorBoldRed = "\x1b[1;31m"
+)
+
+//levelColor 各日志级别的颜色
+var levelColor = []string{
+	VerbLevel:    colorDim,
+	DebugLevel:   colorMagenta,
+	InfoLevel:    colorBlue,
+	NoticeLevel:  colorGreen,
+	WarningLevel: colorYellow,
+	ErrorLevel:   colorRed,
+	FatalLevel:   colorBoldRed,
+}
+
+//ConsoleEncoder 面向终端的编码器，级别按颜色区分，时间暗色显示，字段按列对齐，用于本地开发
+//默认只在输出到终端时使用颜色，并遵守NO_COLOR、FORCE_COLOR环境变量
+//exp: 16:00:00.000 INFO    main.go:12      message                                  k=v
+type ConsoleEncoder struct {
+	Color        ColorMode //颜色模式，默认ColorAuto
+	MessageWidth int       //消息对齐宽度，字段从该列之后开始输出，<=0时默认40
+}
+
+//Encode 实现Encoder
+func (c *ConsoleEncoder) Encode(buf []byte, e *Entry) []byte {
+	color := useColor(c.Color)
+	//时间
+	if color {
+		buf = append(buf, colorDim...)
+	}
+	buf = appendTime(buf, e.Time, "15:04:05.000")
+	if color {
+		buf = append(buf, colorReset...)
+	}
+	buf = append(buf, ' ')
+
+	//级别，固定7个字符宽
+	name := LevelName(e.Level)
+	if color && e.Level >= VerbLevel && e.Level < len(levelColor) {
+		buf = append(buf, levelColor[e.Level]...)
+		buf = append(buf, name...)
+		buf = append(buf, colorReset...)
+	} else {
+		buf = append(buf, name...)
+	}
+	buf = appendPadding(buf, 8-len(name))
+
+	//调用者，固定16个字符宽
+	if color {
+		buf = append(buf, colorDim...)
+	}
+	mark := len(buf)
+	buf = append(buf, formatCaller(e.File)...)
+	if e.File != "" {
+		buf = append(buf, ':')
+		buf = strconv.AppendInt(buf, int64(e.Line), 10)
+	}
+	callerLen := len(buf) - mark
+	if color {
+		buf = append(buf, colorReset...)
+	}
+	buf = appendPadding(buf, 16-callerLen)
+
+	//消息与字段，字段从固定列开始
+	buf = append(buf, e.Message...)
+	fields := e.Fields
+	if e.Logger != "" {
+		fields = append([]Field{F(LoggerField, e.Logger)}, fields...)
+	}
+	if e.ID != "" {
+		fields = append([]Field{F(IDField, e.ID)}, fields...)
+	}
+	if e.Seq != 0 {
+		fields = append([]Field{F(SeqField, e.Seq)}, fields...)
+	}
+	if len(fields) > 0 {
+		width := c.MessageWidth
+		if width <= 0 {
+			width = 40
+		}
+		buf = appendPadding(buf, width-len(e.Message))
+		for i, f := range fields {
+			if i > 0 {
+				buf = append(buf, ' ')
+			}
+			if color {
+				buf = append(buf, colorCyan...)
+			}
+			buf = append(buf, f.Key...)
+			buf = append(buf, '=')
+			if color {
+				buf = append(buf, colorReset...)
+			}
+			buf = appendFieldValue(buf, f.Value)
+		}
+	}
+	if e.Stack != "" {
+		buf = append(buf, '\n')
+		if color {
+			buf = append(buf, colorDim...)
+		}
+		buf = append(buf, e.Stack...)
+		if color {
+			buf = append(buf, colorReset...)
+		}
+	}
+	return appendNewline(buf)
+}
+
+//appendPadding 追加n个空格，n<1时至少追加一个空格作为分隔
+func appendPadding(buf []byte, n int) []byte {
+	if n < 1 {
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		buf = append(buf, ' ')
+	}
+	return buf
+}
@@ -0,0 +1,27 @@
+package gclog
+
+import "sync/atomic"
+
+//consoleSplitLevel 控制台分流级别，低于该级别的日志输出到标准输出，<0时不分流，原子读写
+var consoleSplitLevel int32 = -1
+
+//SetConsoleSplitLevel 输出到控制台时，level及以上级别的日志输出到标准错误，以下级别输出到标准输出
+//便于shell、CI、容器运行时区分错误输出，写入文件时不生效
+//exp: gclog.SetConsoleSplitLevel(gclog.WarningLevel)
+func SetConsoleSplitLevel(level int) {
+	if level < VerbLevel || level > FatalLevel {
+		return
+	}
+	atomic.StoreInt32(&consoleSplitLevel, int32(level))
+}
+
+//DisableConsoleSplit 关闭控制台分流，所有日志都输出到标准错误（默认）
+func DisableConsoleSplit() {
+	atomic.StoreInt32(&consoleSplitLevel, -1)
+}
+
+//toStdout 判断日志是否输出到标准输出，需要在持有fileLock时调用
+func toStdout(level int) bool {
+	split := atomic.LoadInt32(&consoleSplitLevel)
+	return split >= 0 && !writeToFile && level < int(split)
+}
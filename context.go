@@ -0,0 +1,125 @@
+package gclog
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+)
+
+//contextKey context中保存gclog数据使用的key类型
+type contextKey int
+
+const (
+	//correlationIDKey 关联id
+	correlationIDKey contextKey = iota
+	//traceContextKey W3C trace context
+	traceContextKey
+)
+
+const (
+	//CorrelationIDField 关联id输出的字段名
+	CorrelationIDField = "correlation_id"
+	//TraceIDField trace id输出的字段名
+	TraceIDField = "trace_id"
+	//SpanIDField span id输出的字段名
+	SpanIDField = "span_id"
+)
+
+//NewCorrelationID 生成一个随机的关联id（UUID v4格式）
+func NewCorrelationID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40 //version 4
+	b[8] = (b[8] & 0x3f) | 0x80 //variant RFC4122
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+//WithCorrelationID 将关联id保存到context中
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey, id)
+}
+
+//EnsureCorrelationID context中没有关联id时生成一个新的，返回新的context以及关联id
+//exp: 在请求入口处 ctx, id := gclog.EnsureCorrelationID(r.Context())
+func EnsureCorrelationID(ctx context.Context) (context.Context, string) {
+	if id := CorrelationID(ctx); id != "" {
+		return ctx, id
+	}
+	id := NewCorrelationID()
+	return WithCorrelationID(ctx, id), id
+}
+
+//CorrelationID 取context中的关联id，不存在时返回空字符串
+func CorrelationID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(correlationIDKey).(string)
+	return id
+}
+
+//contextFields 取context中需要附加到日志的字段
+func contextFields(ctx context.Context) []Field {
+	var fields []Field
+	if id := CorrelationID(ctx); id != "" {
+		fields = append(fields, F(CorrelationIDField, id))
+	}
+	if tc, ok := TraceFromContext(ctx); ok {
+		fields = append(fields, F(TraceIDField, tc.TraceID), F(SpanIDField, tc.SpanID))
+	}
+	return fields
+}
+
+//VerbCtx 输出verb日志，附带context中的关联id等字段
+func VerbCtx(ctx context.Context, msg string, v ...interface{}) {
+	if levelEnabled(VerbLevel) {
+		text, fields := sprintf(msg, v...), contextFields(ctx)
+		writeLog(VerbLevel, text, fields...)
+		mirrorSpanEvent(ctx, VerbLevel, text, fields)
+	}
+}
+
+//DebugCtx 输出debug日志，附带context中的关联id等字段
+func DebugCtx(ctx context.Context, msg string, v ...interface{}) {
+	if levelEnabled(DebugLevel) {
+		text, fields := sprintf(msg, v...), contextFields(ctx)
+		writeLog(DebugLevel, text, fields...)
+		mirrorSpanEvent(ctx, DebugLevel, text, fields)
+	}
+}
+
+//InfoCtx 输出info日志，附带context中的关联id等字段
+func InfoCtx(ctx context.Context, msg string, v ...interface{}) {
+	if levelEnabled(InfoLevel) {
+		text, fields := sprintf(msg, v...), contextFields(ctx)
+		writeLog(InfoLevel, text, fields...)
+		mirrorSpanEvent(ctx, InfoLevel, text, fields)
+	}
+}
+
+//NoticeCtx 输出notice日志，附带context中的关联id等字段
+func NoticeCtx(ctx context.Context, msg string, v ...interface{}) {
+	if levelEnabled(NoticeLevel) {
+		text, fields := sprintf(msg, v...), contextFields(ctx)
+		writeLog(NoticeLevel, text, fields...)
+		mirrorSpanEvent(ctx, NoticeLevel, text, fields)
+	}
+}
+
+//WarningCtx 输出warning日志，附带context中的关联id等字段
+func WarningCtx(ctx context.Context, msg string, v ...interface{}) {
+	if levelEnabled(WarningLevel) {
+		text, fields := sprintf(msg, v...), contextFields(ctx)
+		writeLog(WarningLevel, text, fields...)
+		mirrorSpanEvent(ctx, WarningLevel, text, fields)
+	}
+}
+
+//ErrorCtx 输出error日志，附带context中的关联id等字段
+func ErrorCtx(ctx context.Context, msg string, v ...interface{}) {
+	if levelEnabled(ErrorLevel) {
+		text, fields := sprintf(msg, v...), contextFields(ctx)
+		writeLog(ErrorLevel, text, fields...)
+		mirrorSpanEvent(ctx, ErrorLevel, text, fields)
+	}
+}
@@ -0,0 +1,153 @@
+package gclog
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	crashLock = new(sync.Mutex) //修改配置、写崩溃文件时加锁
+	crashDir  string            //崩溃文件目录，为空时使用日志文件所在目录
+	crashStop chan struct{}     //关闭后停止监听致命信号
+)
+
+//EnableCrashHandler 开启崩溃记录，dir为崩溃文件目录，为空时使用日志文件所在目录
+//收到signals中的信号（默认SIGABRT）时，写入崩溃文件后以同一信号结束进程
+//未捕获的panic需要在main与各goroutine入口处defer gclog.CrashHandler()
+//崩溃文件名为crash-<时间>.log，内容为panic的值或信号、所有goroutine的调用栈以及回溯缓冲中的日志（见EnableBacktrace）
+//exp:
+//
+//	gclog.EnableBacktrace(500, gclog.ErrorLevel)
+//	gclog.EnableCrashHandler("/var/log/app")
+//	defer gclog.CrashHandler()
+func EnableCrashHandler(dir string, signals ...os.Signal) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGABRT}
+	}
+	crashLock.Lock()
+	defer crashLock.Unlock()
+	crashDir = dir
+	if crashStop != nil {
+		close(crashStop)
+	}
+	crashStop = make(chan struct{})
+	//在返回前开始监听，之后收到的信号不会漏掉
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, signals...)
+	go crashSignalListen(c, crashStop)
+}
+
+//DisableCrashHandler 停止监听致命信号，CrashHandler仍会在panic时写崩溃文件
+func DisableCrashHandler() {
+	crashLock.Lock()
+	defer crashLock.Unlock()
+	if crashStop != nil {
+		close(crashStop)
+		crashStop = nil
+	}
+}
+
+//crashSignalListen 监听致命信号，写入崩溃文件后恢复默认处理并重新发送信号
+func crashSignalListen(c chan os.Signal, stop chan struct{}) {
+	defer signal.Stop(c)
+	select {
+	case s := <-c:
+		writeCrash("signal: "+s.String(), nil)
+		signal.Reset(s)
+		if sig, ok := s.(syscall.Signal); ok {
+			syscall.Kill(os.Getpid(), sig)
+		}
+		os.Exit(2)
+	case <-stop:
+	}
+}
+
+//CrashHandler 捕获panic，写入崩溃文件并记录error日志后重新panic，进程按原有方式崩溃，必须直接用在defer中
+//与Recover不同，CrashHandler不会让进程继续运行，用于main及不应恢复的goroutine的入口
+//exp: defer gclog.CrashHandler()
+func CrashHandler() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	writeCrash(fmt.Sprintf("panic: %v", r), callers(2))
+	panic(r)
+}
+
+//writeCrash 写入崩溃文件并记录error日志，写入后将异步队列中的日志落盘
+//pcs为CrashHandler中取得的调用栈，日志的调用者位置取panic发生处，收到信号时为nil，不输出调用者位置
+func writeCrash(reason string, pcs []uintptr) {
+	crashLock.Lock()
+	defer crashLock.Unlock()
+	now := time.Now()
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s\ntime: %s\npid: %d\n\n", reason, now.Format(time.RFC3339Nano), os.Getpid())
+
+	//所有goroutine的调用栈，缓冲不够时加倍
+	stack := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(stack, true)
+		if n < len(stack) {
+			stack = stack[:n]
+			break
+		}
+		stack = make([]byte, len(stack)*2)
+	}
+	buf.WriteString("goroutines:\n")
+	buf.Write(stack)
+
+	if b := loadBacktrace(); b != nil {
+		entries := b.take(true)
+		fmt.Fprintf(&buf, "\nrecent entries (%d):\n", len(entries))
+		line := getBuffer()
+		fileLock.RLock()
+		for i := range entries {
+			line.b = encoder.Encode(line.b[:0], &entries[i])
+			buf.Write(line.b)
+		}
+		fileLock.RUnlock()
+		putBuffer(line)
+	}
+
+	name := filepath.Join(crashFileDir(), "crash-"+now.Format("2006_01_02_15_04_05")+".log")
+	if err := writeCrashFile(name, buf.Bytes()); err != nil {
+		fmt.Fprintf(os.Stderr, "gclog: write crash file %s failed: %s\n%s", name, err, buf.Bytes())
+		name = "stderr"
+	}
+	writeLogAt(ErrorLevel, reason+", crash file: "+name, pcs)
+	Flush()
+	syncLogFile()
+}
+
+//writeCrashFile 按SetFileMode设置的权限写入崩溃文件
+func writeCrashFile(name string, data []byte) error {
+	f, err := createFile(name, os.O_WRONLY|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
+
+//crashFileDir 崩溃文件目录，未设置时使用日志文件所在目录，没有日志文件时为当前目录
+func crashFileDir() string {
+	if crashDir != "" {
+		return crashDir
+	}
+	fileLock.RLock()
+	defer fileLock.RUnlock()
+	if writeToFile {
+		return filepath.Dir(fileName)
+	}
+	return "."
+}
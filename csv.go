@@ -0,0 +1,107 @@
+package gclog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//CSV列名，其他列名表示同名字段的值，日志没有该字段时为空
+const (
+	CSVColumnTime   = "time"
+	CSVColumnLevel  = "level"
+	CSVColumnCaller = "caller"
+	CSVColumnMsg    = "msg"
+	CSVColumnSeq    = "seq"
+	CSVColumnID     = "id"
+	CSVColumnLogger = "logger"
+	CSVColumnStack  = "stack"
+)
+
+//defaultCSVColumns 默认的列
+var defaultCSVColumns = []string{CSVColumnTime, CSVColumnLevel, CSVColumnCaller, CSVColumnMsg}
+
+//CSVEncoder CSV(RFC 4180)编码器，每条日志一行，主要用于gclogcat导出日志片段到表格软件
+//含逗号、引号、换行或首尾空格的值加引号，多行消息保留在一个单元格中
+//exp: 2018-04-08 16:00:00.123,ERROR,main.go:12,"login failed, retry",42
+type CSVEncoder struct {
+	//Columns 输出的列，为空时为time、level、caller、msg，exp: []string{"time", "level", "msg", "user_id"}
+	Columns []string
+}
+
+//columns 返回输出的列
+func (c *CSVEncoder) columns() []string {
+	if len(c.Columns) == 0 {
+		return defaultCSVColumns
+	}
+	return c.Columns
+}
+
+//Header 追加列名组成的表头行
+func (c *CSVEncoder) Header(buf []byte) []byte {
+	for i, column := range c.columns() {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = appendCSVValue(buf, column)
+	}
+	return append(buf, '\n')
+}
+
+//Encode 实现Encoder
+func (c *CSVEncoder) Encode(buf []byte, e *Entry) []byte {
+	for i, column := range c.columns() {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		switch column {
+		case CSVColumnTime:
+			buf = appendTime(buf, e.Time, "2006-01-02 15:04:05.000")
+		case CSVColumnLevel:
+			buf = append(buf, LevelName(e.Level)...)
+		case CSVColumnCaller:
+			if e.File != "" {
+				buf = appendCSVValue(buf, formatCaller(e.File)+":"+strconv.Itoa(e.Line))
+			}
+		case CSVColumnMsg:
+			buf = appendCSVValue(buf, e.Message)
+		case CSVColumnSeq:
+			if e.Seq != 0 {
+				buf = strconv.AppendUint(buf, e.Seq, 10)
+			}
+		case CSVColumnID:
+			buf = append(buf, e.ID...)
+		case CSVColumnLogger:
+			buf = appendCSVValue(buf, e.Logger)
+		case CSVColumnStack:
+			buf = appendCSVValue(buf, e.Stack)
+		default:
+			for _, f := range e.Fields {
+				if f.Key == column {
+					if s, ok := f.Value.(string); ok {
+						buf = appendCSVValue(buf, s)
+					} else {
+						buf = appendCSVValue(buf, fmt.Sprint(f.Value))
+					}
+					break
+				}
+			}
+		}
+	}
+	return append(buf, '\n')
+}
+
+//appendCSVValue 追加一个值，需要时加引号，引号写两次
+func appendCSVValue(buf []byte, s string) []byte {
+	if s == "" || !strings.ContainsAny(s, ",\"\r\n") && s[0] != ' ' && s[len(s)-1] != ' ' {
+		return append(buf, s...)
+	}
+	buf = append(buf, '"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' {
+			buf = append(buf, '"')
+		}
+		buf = append(buf, s[i])
+	}
+	return append(buf, '"')
+}
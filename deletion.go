@@ -0,0 +1,98 @@
+package gclog
+
+import (
+	"sync"
+	"time"
+)
+
+//删除切分出的文件的原因
+const (
+	DeleteExpired   = "expired"   //超过保存时间
+	DeleteOverLimit = "limit"     //超过SetLogRetentionLimits的个数或总大小限制
+	DeleteDiskLow   = "disk-low"  //SetDiskGuard清理磁盘空间
+	DeleteRetention = "retention" //保留策略没有设置归档器时删除
+	DeleteArchived  = "archived"  //保留策略上传到归档目标后删除本地文件
+)
+
+//DeleteAuditEvent 删除切分出的文件时输出的审计日志事件，字段为file、size、age、reason
+const DeleteAuditEvent = "log.delete"
+
+//DeletedFile 删除或DryRun时将要删除的文件
+type DeletedFile struct {
+	Path   string
+	Size   int64
+	Age    time.Duration //距修改时间的时长
+	Reason string        //DeleteExpired等
+	DryRun bool          //为true时文件没有删除
+}
+
+var (
+	deleteLock     = new(sync.Mutex)   //保护以下变量
+	deleteDryRun   bool                //清理过期日志时只报告不删除
+	deleteCallback func(DeletedFile)   //删除或DryRun时的回调
+	dryRunReported = map[string]bool{} //DryRun时已报告的文件，每个文件只报告一次
+	deletedPending []DeletedFile       //等待输出审计日志的删除记录
+)
+
+//SetDeleteDryRun 设置清理过期日志（SetLogStorageTime、SetLogRetentionLimits）时是否只报告不删除，用于在生产环境验证保存设置
+//DryRun时将要删除的文件输出notice日志并调用callback，每个文件只报告一次；callback不为nil时实际删除的文件同样回调
+//无论是否DryRun，实际删除的文件（包括SetDiskGuard、保留策略的删除）都输出一条审计日志，事件为DeleteAuditEvent
+//exp:
+//
+//	gclog.SetDeleteDryRun(true, func(f gclog.DeletedFile) {
+//		fmt.Printf("would delete %s (%d bytes, age %s, %s)\n", f.Path, f.Size, f.Age, f.Reason)
+//	})
+func SetDeleteDryRun(dryRun bool, callback func(DeletedFile)) {
+	deleteLock.Lock()
+	defer deleteLock.Unlock()
+	deleteDryRun, deleteCallback = dryRun, callback
+	dryRunReported = map[string]bool{}
+}
+
+//isDeleteDryRun 判断清理过期日志时是否只报告
+func isDeleteDryRun() bool {
+	deleteLock.Lock()
+	defer deleteLock.Unlock()
+	return deleteDryRun
+}
+
+//reportDryRun DryRun时报告将要删除的文件，已报告过的文件忽略
+func reportDryRun(f rotatedFile, reason string) {
+	d := DeletedFile{Path: f.path, Size: f.info.Size(), Age: time.Since(f.info.ModTime()), Reason: reason, DryRun: true}
+	deleteLock.Lock()
+	if dryRunReported[d.Path] {
+		deleteLock.Unlock()
+		return
+	}
+	dryRunReported[d.Path] = true
+	callback := deleteCallback
+	deleteLock.Unlock()
+	Notice("dry run, would delete file %s, %d bytes, age %s, reason %s", d.Path, d.Size, d.Age.Round(time.Second), reason)
+	if callback != nil {
+		callback(d)
+	}
+}
+
+//recordDeletion 记录实际删除的文件，审计日志由auditDeletions在日志切分循环中输出
+//删除可能发生在切分审计日志文件时，此时持有审计日志的锁，不能直接输出审计日志
+func recordDeletion(f rotatedFile, reason string) {
+	d := DeletedFile{Path: f.path, Size: f.info.Size(), Age: time.Since(f.info.ModTime()), Reason: reason}
+	deleteLock.Lock()
+	deletedPending = append(deletedPending, d)
+	callback := deleteCallback
+	deleteLock.Unlock()
+	if callback != nil {
+		callback(d)
+	}
+}
+
+//auditDeletions 为记录的删除输出审计日志
+func auditDeletions() {
+	deleteLock.Lock()
+	pending := deletedPending
+	deletedPending = nil
+	deleteLock.Unlock()
+	for _, d := range pending {
+		Audit(DeleteAuditEvent, F("file", d.Path), F("size", d.Size), F("age", d.Age.Round(time.Second).String()), F("reason", d.Reason))
+	}
+}
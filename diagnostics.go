@@ -0,0 +1,43 @@
+package gclog
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+//DumpDiagnostics 将所有goroutine的调用栈、内存统计以及日志库状态与配置写入日志，用于线上排查卡死等问题
+//kill -QUIT 会触发该方法，也可以由业务的管理接口直接调用
+//诊断信息不受当前日志级别限制，总是输出
+func DumpDiagnostics() {
+	var b strings.Builder
+	b.WriteString("diagnostics dump\n")
+
+	//logger status
+	fmt.Fprintf(&b, "logger: %s\n", Status())
+	fmt.Fprintf(&b, "config: %s\n", Config())
+
+	//memory stats
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	fmt.Fprintf(&b, "memory: alloc=%d total_alloc=%d sys=%d heap_objects=%d num_gc=%d pause_total=%dns\n",
+		m.Alloc, m.TotalAlloc, m.Sys, m.HeapObjects, m.NumGC, m.PauseTotalNs)
+
+	//goroutine stacks
+	fmt.Fprintf(&b, "goroutines: %d\n", runtime.NumGoroutine())
+	b.Write(allGoroutineStacks())
+
+	writeLogForced(WarningLevel, b.String())
+}
+
+//allGoroutineStacks 取所有goroutine的调用栈，缓冲区不足时自动扩容
+func allGoroutineStacks() []byte {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
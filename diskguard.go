@@ -0,0 +1,132 @@
+package gclog
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+var (
+	diskGuardLock = new(sync.Mutex) //修改配置、检查磁盘空间时加锁
+	diskMinFree   uint64            //日志所在磁盘的最小剩余空间，0为不检查
+	diskErrorOnly bool              //清理后空间仍不足时是否只输出error及以上级别的日志
+	diskLow       int32             //是否处于磁盘空间不足的降级状态，原子读写
+)
+
+//SetDiskGuard 开启磁盘空间保护，日志文件所在磁盘剩余空间低于minFree字节时，从最旧的开始删除已切分的日志文件（包括RouteLevels的文件，不包括审计日志）
+//删除后仍然不足且errorOnly为true时，只输出error及以上级别的日志，直到空间恢复
+//进入、退出空间不足状态时输出warning日志，随日志切分循环的每次检查（默认30秒）检查一次，minFree为0时关闭
+//exp: gclog.SetDiskGuard(1<<30, true)
+func SetDiskGuard(minFree uint64, errorOnly bool) {
+	diskGuardLock.Lock()
+	diskMinFree, diskErrorOnly = minFree, errorOnly
+	diskGuardLock.Unlock()
+	if minFree == 0 || !errorOnly {
+		atomic.StoreInt32(&diskLow, 0)
+	}
+}
+
+//diskDegraded 判断日志是否因磁盘空间不足被丢弃
+func diskDegraded(level int) bool {
+	return level < ErrorLevel && atomic.LoadInt32(&diskLow) == 1
+}
+
+//diskFree 返回path所在磁盘非特权用户可用的空间
+func diskFree(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
+
+//checkDiskSpace 检查日志所在磁盘的剩余空间，不足时清理已切分的日志文件，仍不足时降级
+func checkDiskSpace() {
+	diskGuardLock.Lock()
+	defer diskGuardLock.Unlock()
+	if diskMinFree == 0 {
+		return
+	}
+	fileLock.RLock()
+	toFile, current := writeToFile, fileName
+	fileLock.RUnlock()
+	if !toFile {
+		return
+	}
+	dir := filepath.Dir(current)
+	free, err := diskFree(dir)
+	if err != nil {
+		Warning("check disk space of %s failed, because %s", dir, err.Error())
+		return
+	}
+	if free < diskMinFree {
+		files := []string{current}
+		for _, s := range loadSinks() {
+			if f, ok := s.sink.(*FileSink); ok {
+				files = append(files, f.FileName())
+			}
+		}
+		free = deleteOldestFiles(files, dir, free)
+	}
+	if free >= diskMinFree {
+		if atomic.CompareAndSwapInt32(&diskLow, 1, 0) {
+			Warning("disk space of %s recovered, free %d bytes, resume logging", dir, free)
+		}
+		return
+	}
+	if diskErrorOnly && atomic.LoadInt32(&diskLow) == 0 {
+		//先输出warning再降级，否则warning会被丢弃
+		Warning("disk space of %s is low, free %d bytes < %d bytes, only error logs will be written", dir, free, diskMinFree)
+		atomic.StoreInt32(&diskLow, 1)
+	} else if !diskErrorOnly {
+		Warning("disk space of %s is low, free %d bytes < %d bytes", dir, free, diskMinFree)
+	}
+}
+
+//rotatedFile 已切分的日志文件
+type rotatedFile struct {
+	path string
+	info os.FileInfo
+}
+
+//deleteOldestFiles 从最旧的开始删除files切分出的日志文件，直到dir所在磁盘的剩余空间达到diskMinFree，返回删除后的剩余空间
+func deleteOldestFiles(files []string, dir string, free uint64) uint64 {
+	var rotated []rotatedFile
+	for _, filename := range files {
+		fdir, name, suffix := getFileInfo(filename)
+		infos, err := os.ReadDir(fdir)
+		if err != nil {
+			continue
+		}
+		for _, v := range infos {
+			if !isRotatedFile(v.Name(), name, suffix) {
+				continue
+			}
+			if info, err := v.Info(); err == nil {
+				rotated = append(rotated, rotatedFile{path: fdir + "/" + v.Name(), info: info})
+			}
+		}
+	}
+	sort.Slice(rotated, func(i, j int) bool { return rotated[i].info.ModTime().Before(rotated[j].info.ModTime()) })
+	for _, f := range rotated {
+		if free >= diskMinFree {
+			break
+		}
+		if err := os.Remove(f.path); err != nil {
+			Warning("disk space is low, delete file %s failed, because %s", f.path, err.Error())
+			continue
+		}
+		removeTimeIndex(f.path)
+		recordDeletion(f, DeleteDiskLow)
+		Warning("disk space is low, delete file %s, %d bytes", f.path, f.info.Size())
+		if n, err := diskFree(dir); err == nil {
+			free = n
+		} else {
+			free += uint64(f.info.Size())
+		}
+	}
+	return free
+}
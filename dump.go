@@ -0,0 +1,159 @@
+package gclog
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+//dumpMaxDepth Dump展开的最大层级，超出部分以...代替
+var dumpMaxDepth = 10
+
+//SetDumpMaxDepth 设置Dump展开的最大层级，<=0时恢复默认值10
+func SetDumpMaxDepth(depth int) {
+	if depth <= 0 {
+		depth = 10
+	}
+	dumpMaxDepth = depth
+}
+
+//Dump 按缩进格式输出任意值的结构（带类型名），用于调试复杂的struct/map
+//指针成环时输出<cycle>，超过最大层级时输出...
+func Dump(level int, label string, value interface{}) {
+	if level < VerbLevel || level > ErrorLevel {
+		return
+	}
+	if levelEnabled(level) {
+		writeLog(level, label+" = "+Sdump(value))
+	}
+}
+
+//Sdump 返回Dump使用的格式化文本
+func Sdump(value interface{}) string {
+	d := &dumper{visited: make(map[uintptr]bool)}
+	d.dump(reflect.ValueOf(value), 0)
+	return d.buf.String()
+}
+
+//dumper Dump的格式化状态
+type dumper struct {
+	buf     strings.Builder
+	visited map[uintptr]bool //当前路径上已经访问过的指针，用于检测环
+}
+
+//indent 输出depth层缩进
+func (d *dumper) indent(depth int) {
+	d.buf.WriteString(strings.Repeat("  ", depth))
+}
+
+//dump 递归输出v
+func (d *dumper) dump(v reflect.Value, depth int) {
+	if !v.IsValid() {
+		d.buf.WriteString("<nil>")
+		return
+	}
+	if depth > dumpMaxDepth {
+		d.buf.WriteString("...")
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			fmt.Fprintf(&d.buf, "(%s)<nil>", v.Type())
+			return
+		}
+		addr := v.Pointer()
+		if d.visited[addr] {
+			fmt.Fprintf(&d.buf, "<cycle %s>", v.Type())
+			return
+		}
+		d.visited[addr] = true
+		d.buf.WriteString("&")
+		d.dump(v.Elem(), depth)
+		delete(d.visited, addr)
+	case reflect.Interface:
+		if v.IsNil() {
+			fmt.Fprintf(&d.buf, "(%s)<nil>", v.Type())
+			return
+		}
+		d.dump(v.Elem(), depth)
+	case reflect.Struct:
+		fmt.Fprintf(&d.buf, "%s{\n", v.Type())
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			d.indent(depth + 1)
+			d.buf.WriteString(t.Field(i).Name + ": ")
+			d.dump(v.Field(i), depth+1)
+			d.buf.WriteString(",\n")
+		}
+		d.indent(depth)
+		d.buf.WriteString("}")
+	case reflect.Map:
+		if v.IsNil() {
+			fmt.Fprintf(&d.buf, "%s(nil)", v.Type())
+			return
+		}
+		addr := v.Pointer()
+		if d.visited[addr] {
+			fmt.Fprintf(&d.buf, "<cycle %s>", v.Type())
+			return
+		}
+		d.visited[addr] = true
+		fmt.Fprintf(&d.buf, "%s{\n", v.Type())
+		keys := v.MapKeys()
+		//按key的文本排序，保证输出稳定
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+		})
+		for _, k := range keys {
+			d.indent(depth + 1)
+			d.dump(k, depth+1)
+			d.buf.WriteString(": ")
+			d.dump(v.MapIndex(k), depth+1)
+			d.buf.WriteString(",\n")
+		}
+		d.indent(depth)
+		d.buf.WriteString("}")
+		delete(d.visited, addr)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			fmt.Fprintf(&d.buf, "%s(nil)", v.Type())
+			return
+		}
+		//[]byte直接按字符串的形式输出
+		if v.Type().Elem().Kind() == reflect.Uint8 && v.Kind() == reflect.Slice {
+			fmt.Fprintf(&d.buf, "%s(%q)", v.Type(), v.Bytes())
+			return
+		}
+		fmt.Fprintf(&d.buf, "%s{\n", v.Type())
+		for i := 0; i < v.Len(); i++ {
+			d.indent(depth + 1)
+			d.dump(v.Index(i), depth+1)
+			d.buf.WriteString(",\n")
+		}
+		d.indent(depth)
+		d.buf.WriteString("}")
+	case reflect.String:
+		fmt.Fprintf(&d.buf, "%q", v.String())
+	case reflect.Bool:
+		fmt.Fprintf(&d.buf, "%t", v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		fmt.Fprintf(&d.buf, "%s(%d)", v.Type(), v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		fmt.Fprintf(&d.buf, "%s(%d)", v.Type(), v.Uint())
+	case reflect.Float32, reflect.Float64:
+		fmt.Fprintf(&d.buf, "%s(%g)", v.Type(), v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		fmt.Fprintf(&d.buf, "%s%g", v.Type(), v.Complex())
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		if v.IsNil() {
+			fmt.Fprintf(&d.buf, "%s(nil)", v.Type())
+		} else {
+			fmt.Fprintf(&d.buf, "%s(0x%x)", v.Type(), v.Pointer())
+		}
+	default:
+		fmt.Fprintf(&d.buf, "%s", v.Type())
+	}
+}
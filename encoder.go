@@ -0,0 +1,78 @@
+package gclog
+
+import (
+	"strconv"
+	"time"
+)
+
+//Entry 一条日志
+type Entry struct {
+	Time    time.Time //日志时间
+	Level   int       //日志级别
+	Message string    //日志内容
+	File    string    //调用者文件的完整路径
+	Line    int       //调用者行号
+	Fields  []Field   //附加字段，包括context字段与全局字段
+	Stack   string    //调用栈，未开启时为空
+	Seq     uint64    //进程内单调递增的序号，未开启时为0
+	ID      string    //日志的ULID，未开启时为空
+	Logger  string    //logger名称，包级日志接口输出时为空
+}
+
+//Encoder 日志编码器，将一条日志编码后追加到buf并返回，编码结果需以换行结尾
+//多个goroutine写日志时Encode会被并发调用（只持有fileLock的读锁），实现需要是并发安全的，
+//编码时不能修改编码器自身的状态，需要缓存等状态时自行加锁；内置的编码器都是并发安全的
+type Encoder interface {
+	Encode(buf []byte, e *Entry) []byte
+}
+
+//encoder 当前使用的编码器，由fileLock保护
+var encoder Encoder = &TextEncoder{}
+
+//SetEncoder 设置日志编码器，nil表示使用默认的TextEncoder，enc的Encode需要是并发安全的
+//exp: 本地开发时 gclog.SetEncoder(&gclog.ConsoleEncoder{})
+func SetEncoder(enc Encoder) {
+	if enc == nil {
+		enc = &TextEncoder{}
+	}
+	fileLock.Lock()
+	defer fileLock.Unlock()
+	encoder = enc
+}
+
+//TextEncoder 默认的文本编码器，日志头格式可以通过SetHeaderTemplate修改
+//exp: [INFO] 2018/04/08 16:00:00 main.go:12: [INFO] message k=v
+type TextEncoder struct{}
+
+//Encode 实现Encoder
+func (t *TextEncoder) Encode(buf []byte, e *Entry) []byte {
+	h := header
+	buf = h.append(buf, e)
+	buf = append(buf, e.Message...)
+	if e.Seq != 0 && !h.withSeq {
+		buf = append(buf, " seq="...)
+		buf = strconv.AppendUint(buf, e.Seq, 10)
+	}
+	if e.ID != "" && !h.withID {
+		buf = append(buf, " id="...)
+		buf = append(buf, e.ID...)
+	}
+	if e.Logger != "" && !h.withLogger {
+		buf = append(buf, " logger="...)
+		buf = appendFieldString(buf, e.Logger)
+	}
+	buf = appendFields(buf, e.Fields)
+	if e.Stack != "" {
+		buf = append(buf, "\nstack:\n"...)
+		buf = append(buf, e.Stack...)
+	}
+	return appendNewline(buf)
+}
+
+//appendNewline 保证以换行结尾，与标准库log一致，消息本身以换行结尾时不重复添加
+func appendNewline(buf []byte) []byte {
+	if len(buf) == 0 || buf[len(buf)-1] != '\n' {
+		buf = append(buf, '\n')
+	}
+	return buf
+}
@@ -0,0 +1,384 @@
+package gclog
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+//加密日志文件格式：文件头 + 若干记录
+//文件头为encryptMagic + 1字节版本，版本2之后为 2字节长度+密钥id + 2字节长度+包装后的数据密钥
+//记录为 4字节大端长度 + 12字节nonce + AES-GCM密文，每次写入（一条日志或异步模式的一批日志）为一条记录
+const (
+	encryptMagic           = "GCLOGENC"
+	encryptVersionKey      = 1 //使用SetEncryptionKey的密钥直接加密
+	encryptVersionEnvelope = 2 //每个文件使用独立的数据密钥，见SetKeyWrapper
+	dataKeySize            = 32
+)
+
+var (
+	encryptConf      atomic.Value //当前的加密配置，encryptConfig
+	errNotEncrypted  = errors.New("gclog: file is not encrypted")
+	errEncryptedFile = errors.New("gclog: existing file is not encrypted with the current settings, slice it before enabling encryption")
+	errBadRecord     = errors.New("gclog: bad encrypted record")
+	errKeyMismatch   = errors.New("gclog: key does not match the file")
+)
+
+//KeyWrapper 包装、解包数据密钥，用于信封加密，可以使用本地主密钥（NewMasterKeyWrapper）或对接KMS
+type KeyWrapper interface {
+	//WrapKey 包装数据密钥，返回包装后的密钥与主密钥的标识，二者保存在文件头中
+	WrapKey(dataKey []byte) (wrapped []byte, keyID string, err error)
+	//UnwrapKey 解包数据密钥
+	UnwrapKey(wrapped []byte, keyID string) ([]byte, error)
+}
+
+//encryptConfig 加密配置，aead与wrapper最多一个不为nil
+type encryptConfig struct {
+	aead    cipher.AEAD //SetEncryptionKey设置的加密器
+	wrapper KeyWrapper  //SetKeyWrapper设置的数据密钥包装器
+}
+
+//enabled 是否开启了加密
+func (c encryptConfig) enabled() bool {
+	return c.aead != nil || c.wrapper != nil
+}
+
+//fileAEAD 根据文件头取文件内容的加密器
+func (c encryptConfig) fileAEAD(h fileHeader) (cipher.AEAD, error) {
+	switch h.version {
+	case encryptVersionKey:
+		if c.aead == nil {
+			return nil, errKeyMismatch
+		}
+		return c.aead, nil
+	case encryptVersionEnvelope:
+		if c.wrapper == nil {
+			return nil, errKeyMismatch
+		}
+		key, err := c.wrapper.UnwrapKey(h.wrapped, h.keyID)
+		if err != nil {
+			return nil, err
+		}
+		return newAEAD(key)
+	}
+	return nil, errNotEncrypted
+}
+
+//SetEncryptionKey 设置日志文件的加密密钥（AES-128/192/256，16/24/32字节），key为空时关闭加密
+//之后打开的日志文件（InitLogFile、切分、RouteLevels、InitAuditFile）内容使用AES-GCM加密，使用NewDecryptReader或gclogdecrypt解密
+//已有内容的明文文件不能直接追加加密内容，需要先切分
+func SetEncryptionKey(key []byte) error {
+	if len(key) == 0 {
+		encryptConf.Store(encryptConfig{})
+		return nil
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	encryptConf.Store(encryptConfig{aead: aead})
+	return nil
+}
+
+//SetKeyWrapper 开启信封加密，每个新日志文件（包括切分出的文件）生成独立的随机数据密钥，经wrapper包装后保存在文件头中
+//数据密钥泄露只影响一个文件的时间范围，主密钥可以放在KMS中，w为nil时关闭加密
+//exp:
+//
+//	w, _ := gclog.NewMasterKeyWrapper(masterKey)
+//	gclog.SetKeyWrapper(w)
+func SetKeyWrapper(w KeyWrapper) {
+	encryptConf.Store(encryptConfig{wrapper: w})
+}
+
+//loadEncryptConfig 取当前的加密配置
+func loadEncryptConfig() encryptConfig {
+	c, _ := encryptConf.Load().(encryptConfig)
+	return c
+}
+
+//newAEAD 创建AES-GCM加密器
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+//masterKeyWrapper 使用本地主密钥以AES-GCM包装数据密钥
+type masterKeyWrapper struct {
+	aead cipher.AEAD
+	id   string
+}
+
+//NewMasterKeyWrapper 创建使用本地主密钥（16/24/32字节）的KeyWrapper，密钥标识为主密钥sha256的前4字节
+func NewMasterKeyWrapper(masterKey []byte) (KeyWrapper, error) {
+	aead, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(masterKey)
+	return &masterKeyWrapper{aead: aead, id: "master:" + hex.EncodeToString(sum[:4])}, nil
+}
+
+//WrapKey 实现KeyWrapper，结果为nonce+密文
+func (m *masterKeyWrapper) WrapKey(dataKey []byte) ([]byte, string, error) {
+	nonce := make([]byte, m.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, "", err
+	}
+	return m.aead.Seal(nonce, nonce, dataKey, nil), m.id, nil
+}
+
+//UnwrapKey 实现KeyWrapper
+func (m *masterKeyWrapper) UnwrapKey(wrapped []byte, keyID string) ([]byte, error) {
+	n := m.aead.NonceSize()
+	if keyID != m.id || len(wrapped) < n {
+		return nil, errKeyMismatch
+	}
+	key, err := m.aead.Open(nil, wrapped[:n], wrapped[n:], nil)
+	if err != nil {
+		return nil, errKeyMismatch
+	}
+	return key, nil
+}
+
+//fileHeader 加密文件头
+type fileHeader struct {
+	version byte
+	keyID   string
+	wrapped []byte
+}
+
+//encode 编码文件头
+func (h fileHeader) encode() []byte {
+	buf := append([]byte(encryptMagic), h.version)
+	if h.version == encryptVersionEnvelope {
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(h.keyID)))
+		buf = append(buf, h.keyID...)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(h.wrapped)))
+		buf = append(buf, h.wrapped...)
+	}
+	return buf
+}
+
+//readFileHeader 读取加密文件头
+func readFileHeader(r io.Reader) (fileHeader, error) {
+	var h fileHeader
+	head := make([]byte, len(encryptMagic)+1)
+	if _, err := io.ReadFull(r, head); err != nil || string(head[:len(encryptMagic)]) != encryptMagic {
+		return h, errNotEncrypted
+	}
+	h.version = head[len(encryptMagic)]
+	if h.version != encryptVersionEnvelope {
+		return h, nil
+	}
+	keyID, err := readUint16Bytes(r)
+	if err != nil {
+		return h, errNotEncrypted
+	}
+	h.keyID = string(keyID)
+	if h.wrapped, err = readUint16Bytes(r); err != nil {
+		return h, errNotEncrypted
+	}
+	return h, nil
+}
+
+//readUint16Bytes 读取2字节长度前缀的内容
+func readUint16Bytes(r io.Reader) ([]byte, error) {
+	var size [2]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(size[:]))
+	_, err := io.ReadFull(r, buf)
+	return buf, err
+}
+
+//logWriter 返回日志文件的写入目标，开启加密时返回加密写入器
+//新文件写入文件头（信封加密时生成新的数据密钥），已有内容的文件从文件头恢复加密器继续追加
+func logWriter(file *os.File) (io.Writer, error) {
+	conf := loadEncryptConfig()
+	if !conf.enabled() {
+		return indexedWriter(file, fileWriter(file)), nil
+	}
+	//多进程时加锁，只有一个进程写入文件头
+	if multiProcessEnabled() {
+		unlock, err := lockFile(file)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > 0 {
+		h, err := readHeaderFile(file.Name())
+		if err != nil {
+			return nil, errEncryptedFile
+		}
+		aead, err := conf.fileAEAD(h)
+		if err != nil {
+			return nil, err
+		}
+		return &encryptWriter{w: fileWriter(file), aead: aead}, nil
+	}
+
+	h := fileHeader{version: encryptVersionKey}
+	aead := conf.aead
+	if conf.wrapper != nil {
+		dataKey := make([]byte, dataKeySize)
+		if _, err := rand.Read(dataKey); err != nil {
+			return nil, err
+		}
+		h.version = encryptVersionEnvelope
+		if h.wrapped, h.keyID, err = conf.wrapper.WrapKey(dataKey); err != nil {
+			return nil, err
+		}
+		if aead, err = newAEAD(dataKey); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := file.Write(h.encode()); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: fileWriter(file), aead: aead}, nil
+}
+
+//readHeaderFile 读取文件的加密文件头
+func readHeaderFile(name string) (fileHeader, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return fileHeader{}, err
+	}
+	defer f.Close()
+	return readFileHeader(bufio.NewReader(f))
+}
+
+//isEncryptedFile 判断文件是否以加密文件头开始
+func isEncryptedFile(name string) bool {
+	_, err := readHeaderFile(name)
+	return err == nil
+}
+
+//encryptWriter 将每次写入加密为一条记录，记录通过一次Write写入，以追加方式打开的文件可以并发写
+type encryptWriter struct {
+	w    io.Writer
+	aead cipher.AEAD
+}
+
+//Write 实现io.Writer
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	nonceSize := e.aead.NonceSize()
+	buf.b = append(buf.b, 0, 0, 0, 0)
+	buf.b = append(buf.b, make([]byte, nonceSize)...)
+	nonce := buf.b[4 : 4+nonceSize]
+	if _, err := rand.Read(nonce); err != nil {
+		return 0, err
+	}
+	buf.b = e.aead.Seal(buf.b, nonce, p, nil)
+	binary.BigEndian.PutUint32(buf.b[:4], uint32(len(buf.b)-4))
+	if _, err := e.w.Write(buf.b); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+//decryptReader 解密加密日志文件的reader
+type decryptReader struct {
+	r    *bufio.Reader
+	aead cipher.AEAD
+	buf  []byte //当前记录中尚未读取的明文
+	rec  []byte //记录的读取缓冲区
+}
+
+//NewDecryptReader 返回解密日志文件内容的reader
+//key为SetEncryptionKey使用的密钥，或信封加密时NewMasterKeyWrapper使用的主密钥
+//exp:
+//
+//	f, _ := os.Open("app.log")
+//	r, err := gclog.NewDecryptReader(f, key)
+//	io.Copy(os.Stdout, r)
+func NewDecryptReader(r io.Reader, key []byte) (io.Reader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	wrapper, err := NewMasterKeyWrapper(key)
+	if err != nil {
+		return nil, err
+	}
+	return newDecryptReader(r, encryptConfig{aead: aead, wrapper: wrapper})
+}
+
+//NewWrappedDecryptReader 返回解密信封加密日志文件的reader，wrapper用于解包文件头中的数据密钥（如对接KMS）
+func NewWrappedDecryptReader(r io.Reader, wrapper KeyWrapper) (io.Reader, error) {
+	return newDecryptReader(r, encryptConfig{wrapper: wrapper})
+}
+
+//newDecryptReader 读取文件头后返回解密reader
+func newDecryptReader(r io.Reader, conf encryptConfig) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	h, err := readFileHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := conf.fileAEAD(h)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{r: br, aead: aead}, nil
+}
+
+//Read 实现io.Reader
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+//next 读取并解密下一条记录
+func (d *decryptReader) next() error {
+	var size [4]byte
+	if _, err := io.ReadFull(d.r, size[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return errBadRecord
+		}
+		return err
+	}
+	n := int(binary.BigEndian.Uint32(size[:]))
+	nonceSize := d.aead.NonceSize()
+	if n < nonceSize+d.aead.Overhead() {
+		return errBadRecord
+	}
+	if cap(d.rec) < n {
+		d.rec = make([]byte, n)
+	}
+	rec := d.rec[:n]
+	if _, err := io.ReadFull(d.r, rec); err != nil {
+		return errBadRecord
+	}
+	plain, err := d.aead.Open(rec[nonceSize:nonceSize], rec[:nonceSize], rec[nonceSize:], nil)
+	if err != nil {
+		return errBadRecord
+	}
+	d.buf = plain
+	return nil
+}
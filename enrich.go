@@ -0,0 +1,78 @@
+package gclog
+
+import (
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+//Enricher 字段补充接口，为每条日志追加字段，用于k8s元数据、云主机id、特性开关快照等不便在每处调用时传入的上下文
+//Enrich将字段追加到fields后返回，每条日志都会调用，需要并发安全且足够快，耗时的数据应提前取好
+type Enricher interface {
+	Enrich(fields []Field) []Field
+}
+
+//EnricherFunc 函数形式的Enricher
+type EnricherFunc func(fields []Field) []Field
+
+//Enrich 实现Enricher
+func (f EnricherFunc) Enrich(fields []Field) []Field {
+	return f(fields)
+}
+
+var (
+	enricherLock = new(sync.Mutex) //注册时加锁
+	enrichers    atomic.Value      //已注册的Enricher，[]Enricher，注册时整体替换，写日志时无锁读取
+)
+
+//AddEnricher 注册Enricher，一般在初始化时调用，追加的字段位于全局字段之后
+//exp:
+//
+//	gclog.AddEnricher(gclog.EnricherFunc(func(fields []gclog.Field) []gclog.Field {
+//		return append(fields, gclog.F("flags", flags.Snapshot()))
+//	}))
+func AddEnricher(en Enricher) {
+	if en == nil {
+		return
+	}
+	enricherLock.Lock()
+	defer enricherLock.Unlock()
+	old, _ := enrichers.Load().([]Enricher)
+	list := make([]Enricher, 0, len(old)+1)
+	enrichers.Store(append(append(list, old...), en))
+}
+
+//ClearEnrichers 移除所有Enricher
+func ClearEnrichers() {
+	enricherLock.Lock()
+	defer enricherLock.Unlock()
+	enrichers.Store([]Enricher(nil))
+}
+
+//enrichFields 依次调用已注册的Enricher追加字段
+//fields的容量与长度相同（或为新分配的切片），追加时不会写入调用方的数组
+func enrichFields(fields []Field) []Field {
+	list, _ := enrichers.Load().([]Enricher)
+	for _, en := range list {
+		fields = en.Enrich(fields)
+	}
+	return fields
+}
+
+//EnvEnricher 创建以环境变量为字段值的Enricher，vars为字段名到环境变量名的映射，环境变量只在创建时读取一次
+//未设置的环境变量不输出，常用于k8s通过Downward API注入的pod信息
+//exp: gclog.AddEnricher(gclog.EnvEnricher(map[string]string{"pod": "POD_NAME", "namespace": "POD_NAMESPACE", "node": "NODE_NAME"}))
+func EnvEnricher(vars map[string]string) Enricher {
+	var fields []Field
+	for key, env := range vars {
+		if v, ok := os.LookupEnv(env); ok {
+			fields = append(fields, F(key, v))
+		}
+	}
+	//map的遍历顺序不固定，按字段名排序保证输出稳定
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Key < fields[j].Key })
+	return EnricherFunc(func(dst []Field) []Field {
+		return append(dst, fields...)
+	})
+}
@@ -0,0 +1,146 @@
+package gclog
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+//ErrorE 输出error日志，同时记录err本身、逐层解包后的cause，以及调用栈
+//调用栈优先取err链中携带的栈（pkg/errors风格的StackTrace()），取不到时使用当前调用栈
+func ErrorE(err error, msg string, v ...interface{}) {
+	if levelEnabled(ErrorLevel) {
+		//与调用者位置相同，跳过AddCallerSkip设置的封装层
+		writeLog(ErrorLevel, formatError(err, sprintf(msg, v...), 2+int(atomic.LoadInt32(&callerSkip))))
+	}
+}
+
+//formatError 生成带cause链与调用栈的错误文本，skip为取调用栈时跳过的栈帧数（含formatError自身）
+func formatError(err error, msg string, skip int) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	if err == nil {
+		b.WriteString(": <nil>")
+		return b.String()
+	}
+	b.WriteString(": ")
+	b.WriteString(err.Error())
+
+	//逐层解包，同时记录最深一层携带的调用栈
+	var pcs []uintptr
+	for cause := err; cause != nil; cause = unwrapCause(cause) {
+		if cause != err {
+			b.WriteString("\n\tcaused by: ")
+			b.WriteString(cause.Error())
+		}
+		if stack := errorStack(cause); stack != nil {
+			pcs = stack
+		}
+	}
+	//err未携带调用栈时取当前调用栈，若writeLog会附加调用栈则不重复输出
+	if pcs == nil {
+		if needStackTrace(ErrorLevel) {
+			return b.String()
+		}
+		pcs = callers(skip)
+	}
+	b.WriteString("\nstack:\n")
+	b.WriteString(formatFrames(pcs))
+	return b.String()
+}
+
+//unwrapCause 解包一层错误，同时兼容标准库的Unwrap和pkg/errors的Cause
+func unwrapCause(err error) error {
+	if next := errors.Unwrap(err); next != nil {
+		return next
+	}
+	if c, ok := err.(interface{ Cause() error }); ok {
+		if next := c.Cause(); next != err {
+			return next
+		}
+	}
+	return nil
+}
+
+//errorStack 取err携带的调用栈，支持StackTrace()返回[]uintptr兼容切片的实现（如pkg/errors）
+func errorStack(err error) []uintptr {
+	method := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+		return nil
+	}
+	out := method.Type().Out(0)
+	if out.Kind() != reflect.Slice || out.Elem().Kind() != reflect.Uintptr {
+		return nil
+	}
+	frames := method.Call(nil)[0]
+	pcs := make([]uintptr, frames.Len())
+	for i := range pcs {
+		//pkg/errors的Frame保存的是pc+1
+		pcs[i] = uintptr(frames.Index(i).Uint()) - 1
+	}
+	return pcs
+}
+
+//callers 取当前调用栈，skip为跳过的栈帧数，0表示从callers的调用者开始
+func callers(skip int) []uintptr {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+2, pcs)
+	return pcs[:n]
+}
+
+//formatFrames 将调用栈格式化为"函数\n\t文件:行号"的形式
+func formatFrames(pcs []uintptr) string {
+	var b strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" {
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "%s\n\t%s:%d", frame.Function, frame.File, frame.Line)
+		}
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+//CheckErr err不为nil时输出error日志（附带err文本），返回err是否不为nil
+//exp:
+//	if gclog.CheckErr(err, "load user %d failed", uid) {
+//		return
+//	}
+func CheckErr(err error, msg string, v ...interface{}) bool {
+	if err == nil {
+		return false
+	}
+	if levelEnabled(ErrorLevel) {
+		writeLog(ErrorLevel, sprintf(msg, v...)+": "+err.Error())
+	}
+	return true
+}
+
+//CheckErrFatal err不为nil时输出fatal日志（附带err文本）并退出进程
+func CheckErrFatal(err error, msg string, v ...interface{}) {
+	if err == nil {
+		return
+	}
+	writeLog(FatalLevel, sprintf(msg, v...)+": "+err.Error())
+	exit(1)
+}
+
+//NewError 输出error日志并返回同样内容的error，format支持%w包装其他错误
+//保证错误的创建与日志记录一致
+//exp: return gclog.NewError("query order %d: %w", id, err)
+func NewError(format string, v ...interface{}) error {
+	err := fmt.Errorf(format, v...)
+	if levelEnabled(ErrorLevel) {
+		writeLog(ErrorLevel, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package gclog
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	exitHooks       []func()          //Fatal退出前执行的钩子
+	exitHookLock    = new(sync.Mutex) //钩子列表锁
+	exitHookTimeout = 5 * time.Second //钩子执行的总超时时间
+)
+
+//RegisterExitHook 注册Fatal退出进程前执行的钩子，按注册顺序执行
+//可用于刷新trace、关闭数据库连接、通知监控进程等
+func RegisterExitHook(hook func()) {
+	exitHookLock.Lock()
+	defer exitHookLock.Unlock()
+	exitHooks = append(exitHooks, hook)
+}
+
+//SetExitHookTimeout 设置所有退出钩子执行的总超时时间，默认5秒，超时后直接退出
+func SetExitHookTimeout(timeout time.Duration) {
+	exitHookLock.Lock()
+	defer exitHookLock.Unlock()
+	exitHookTimeout = timeout
+}
+
+//runExitHooks 依次执行退出钩子，钩子panic不影响后续钩子，超时后直接返回
+func runExitHooks() {
+	exitHookLock.Lock()
+	hooks := make([]func(), len(exitHooks))
+	copy(hooks, exitHooks)
+	timeout := exitHookTimeout
+	exitHookLock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, hook := range hooks {
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						writeLog(ErrorLevel, fmt.Sprintf("exit hook panic: %v", r))
+					}
+				}()
+				hook()
+			}()
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		writeLog(ErrorLevel, "exit hooks timeout after "+timeout.String())
+	}
+}
+
+//exit 执行退出钩子，写入异步队列中的日志并落盘后退出进程
+func exit(code int) {
+	runExitHooks()
+	Flush()
+	syncLogFile()
+	os.Exit(code)
+}
@@ -0,0 +1,73 @@
+package gclog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Field 日志附带的key=value字段
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+//F 创建字段
+//exp: gclog.F("user_id", 42)
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+//appendFields 将字段以" k1=v1 k2=v2"的形式追加到buf
+func appendFields(buf []byte, fields []Field) []byte {
+	for _, f := range fields {
+		buf = append(buf, ' ')
+		buf = append(buf, f.Key...)
+		buf = append(buf, '=')
+		buf = appendFieldValue(buf, f.Value)
+	}
+	return buf
+}
+
+//appendFieldValue 追加字段值，常用类型不经过fmt，值为空或包含空白、引号、等号时加引号
+func appendFieldValue(buf []byte, value interface{}) []byte {
+	switch v := value.(type) {
+	case string:
+		return appendFieldString(buf, v)
+	case int:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int32:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(buf, v, 10)
+	case uint:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint32:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint64:
+		return strconv.AppendUint(buf, v, 10)
+	case bool:
+		return strconv.AppendBool(buf, v)
+	case float64:
+		return strconv.AppendFloat(buf, v, 'g', -1, 64)
+	case time.Duration:
+		return append(buf, v.String()...)
+	case error:
+		return appendFieldString(buf, v.Error())
+	}
+	return appendFieldString(buf, fmt.Sprint(value))
+}
+
+//appendFieldString 追加字符串字段值，需要时加引号
+func appendFieldString(buf []byte, s string) []byte {
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		return strconv.AppendQuote(buf, s)
+	}
+	return append(buf, s...)
+}
+
+//formatFieldValue 格式化字段值
+func formatFieldValue(value interface{}) string {
+	return string(appendFieldValue(nil, value))
+}
@@ -0,0 +1,243 @@
+package gclog
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//errSinkClosed sink已关闭
+var errSinkClosed = errors.New("gclog: sink closed")
+
+//slicer 需要随日志切分循环定时切分的sink
+type slicer interface {
+	sliceIfDue(force bool) bool
+}
+
+//FileSink 将一定级别范围的日志写入单独文件的sink，文件有自己的切分间隔与保存时间
+//切分出的文件命名规则与主日志文件相同，exp: app_error_2018_04_08_16.log
+type FileSink struct {
+	lines         int64         //写入当前文件的行数，原子读写，放在开头保证32位平台上8字节对齐
+	sliceLines    int64         //切分的行数，为0时与主日志文件相同，原子读写
+	minLevel      int           //写入的最低级别
+	maxLevel      int           //写入的最高级别
+	lock          *sync.RWMutex //写入时持有读锁，切分、关闭时持有写锁
+	file          *os.File      //文件流，关闭后为nil
+	w             io.Writer     //写入目标，开启加密时为加密写入器
+	fileName      string        //日志文件名
+	sliceInterval time.Duration //切分的时间间隔，为0时与主日志文件相同
+	storageTime   time.Duration //保存的时间，为0时与主日志文件相同
+	flashTime     time.Time     //上次文件流刷新的时间
+}
+
+//NewFileSink 创建写入filename的sink，只写入[minLevel, maxLevel]级别的日志
+func NewFileSink(filename string, minLevel, maxLevel int) (*FileSink, error) {
+	file, err := openLogFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	w, err := logWriter(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &FileSink{
+		minLevel:  minLevel,
+		maxLevel:  maxLevel,
+		lock:      new(sync.RWMutex),
+		file:      file,
+		w:         w,
+		fileName:  filename,
+		flashTime: mainSchedule().sliceTime(),
+	}, nil
+}
+
+//RouteLevels 将[minLevel, maxLevel]级别的日志额外写入filename，同一文件重复调用时替换之前的设置
+//配合SetOutputLevel可以把不同级别的日志分到不同文件，错误日志文件保持小而易于检索
+//exp:
+//
+//	gclog.InitLogFile("app.log")
+//	gclog.SetOutputLevel(gclog.InfoLevel)
+//	gclog.RouteLevels("app_error.log", gclog.ErrorLevel, gclog.FatalLevel)
+//	gclog.RouteLevels("app_debug.log", gclog.VerbLevel, gclog.DebugLevel)
+func RouteLevels(filename string, minLevel, maxLevel int) (*FileSink, error) {
+	s, err := NewFileSink(filename, minLevel, maxLevel)
+	if err != nil {
+		return nil, err
+	}
+	if old := addSink("file:"+filename, s); old != nil {
+		closeSink(old)
+	}
+	return s, nil
+}
+
+//SetSliceInterval 设置切分的时间间隔，为0时与主日志文件相同
+func (f *FileSink) SetSliceInterval(interval time.Duration) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.sliceInterval = interval
+}
+
+//SetStorageTime 设置保存的时间，为0时与主日志文件相同
+func (f *FileSink) SetStorageTime(storageTime time.Duration) {
+	if storageTime < 0 {
+		storageTime = -1 * storageTime
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.storageTime = storageTime
+}
+
+//SetSliceLines 设置切分的行数，写入lines行后立即切分，与时间切分同时生效，为0时与主日志文件相同，<0时不按行数切分
+func (f *FileSink) SetSliceLines(lines int64) {
+	atomic.StoreInt64(&f.sliceLines, lines)
+}
+
+//linesLimit 返回生效的切分行数，0为不按行数切分
+func (f *FileSink) linesLimit() int64 {
+	limit := atomic.LoadInt64(&f.sliceLines)
+	if limit == 0 {
+		return atomic.LoadInt64(&logSliceLines)
+	}
+	if limit < 0 {
+		return 0
+	}
+	return limit
+}
+
+//schedule 返回生效的切分规则，没有设置切分间隔时与主日志文件相同
+func (f *FileSink) schedule() sliceSchedule {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	if f.sliceInterval == 0 {
+		return mainSchedule()
+	}
+	return sliceSchedule{interval: f.sliceInterval}
+}
+
+//FileName 返回日志文件名
+func (f *FileSink) FileName() string {
+	return f.fileName
+}
+
+//Write 实现Sink，不在级别范围内的日志直接忽略
+func (f *FileSink) Write(e *Entry, line []byte) error {
+	if e.Level < f.minLevel || e.Level > f.maxLevel {
+		return nil
+	}
+	return f.writeLine(line, e.Level)
+}
+
+//writeLine 写入一行，level用于判断是否需要落盘
+func (f *FileSink) writeLine(line []byte, level int) error {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	if f.file == nil {
+		return errSinkClosed
+	}
+	_, err := f.w.Write(line)
+	if limit := f.linesLimit(); limit > 0 {
+		countLines(&f.lines, line, limit)
+	}
+	if err == nil && needSync(level) {
+		err = f.file.Sync()
+	}
+	return err
+}
+
+//Sync 将写入的内容落盘
+func (f *FileSink) Sync() error {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	if f.file == nil {
+		return errSinkClosed
+	}
+	return f.file.Sync()
+}
+
+//Close 实现Sink，关闭文件流
+func (f *FileSink) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+//sliceIfDue 到达切分时间或切分行数时清理过期文件并切分，force为true时立即切分，返回是否切换到了新文件
+func (f *FileSink) sliceIfDue(force bool) bool {
+	f.lock.RLock()
+	closed, flashTime := f.file == nil, f.flashTime
+	f.lock.RUnlock()
+	if closed {
+		return false
+	}
+	if limit := f.linesLimit(); !force && !f.schedule().due(flashTime) && (limit == 0 || atomic.LoadInt64(&f.lines) < limit) {
+		return false
+	}
+	f.deleteExpired()
+	return f.rotate()
+}
+
+//deleteExpired 清理超过保存时间或SetLogRetentionLimits限制的文件
+func (f *FileSink) deleteExpired() {
+	f.lock.RLock()
+	flashTime, storageTime := f.flashTime, f.storageTime
+	f.lock.RUnlock()
+	if storageTime == 0 {
+		storageTime = logStorageTime
+	}
+	deleteExpiredFiles(f.fileName, flashTime.Add(-1*storageTime))
+}
+
+//rotate 切分日志文件，与moveLogFile相同，切分期间的日志写入改名后的旧文件，返回是否切换到了新文件
+func (f *FileSink) rotate() bool {
+	f.lock.RLock()
+	current, flashTime := f.file, f.flashTime
+	f.lock.RUnlock()
+	s := f.schedule()
+	start := time.Now()
+	newName, renamed, file, err := rotateFile(f.fileName, current, s, flashTime)
+	if newName == "" {
+		f.lock.Lock()
+		f.flashTime = s.sliceTime()
+		atomic.StoreInt64(&f.lines, 0)
+		f.lock.Unlock()
+		Warning("rename file %s failed, because %s", f.fileName, err.Error())
+		return false
+	}
+	if err != nil {
+		Warning("open file %s failed, because %s, keep writing to %s", f.fileName, err.Error(), newName)
+		return false
+	}
+	w, err := logWriter(file)
+	if err != nil {
+		file.Close()
+		Warning("open file %s failed, because %s, keep writing to %s", f.fileName, err.Error(), newName)
+		return false
+	}
+
+	f.lock.Lock()
+	old := f.file
+	if old == nil {
+		//切分期间已关闭
+		f.lock.Unlock()
+		file.Close()
+		return false
+	}
+	f.file, f.w = file, w
+	f.flashTime = s.sliceTime()
+	atomic.StoreInt64(&f.lines, 0)
+	f.lock.Unlock()
+	old.Close()
+	if renamed {
+		notifyRotation(f.fileName, newName, start)
+	}
+	return true
+}
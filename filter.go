@@ -0,0 +1,55 @@
+package gclog
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+//Filter 日志过滤函数，返回false时丢弃该日志
+//调用时已补全时间、字段与调用者位置，调用栈尚未生成
+type Filter func(e Entry) bool
+
+var (
+	filterLock = new(sync.Mutex) //注册过滤函数时加锁
+	filterList atomic.Value      //过滤函数，[]Filter，注册时整体替换，写日志时无锁读取
+)
+
+//AddFilter 注册过滤函数，任一过滤函数返回false时丢弃该日志，在日志级别之外按调用位置、字段、内容等条件过滤
+//exp:
+//
+//	gclog.AddFilter(func(e gclog.Entry) bool {
+//		return !strings.Contains(e.File, "/vendor/noisy/")
+//	})
+func AddFilter(f Filter) {
+	if f == nil {
+		return
+	}
+	filterLock.Lock()
+	defer filterLock.Unlock()
+	old := loadFilters()
+	filters := make([]Filter, 0, len(old)+1)
+	filterList.Store(append(append(filters, old...), f))
+}
+
+//ClearFilters 移除所有过滤函数
+func ClearFilters() {
+	filterLock.Lock()
+	defer filterLock.Unlock()
+	filterList.Store([]Filter(nil))
+}
+
+//loadFilters 取当前的过滤函数，返回的切片不能修改
+func loadFilters() []Filter {
+	filters, _ := filterList.Load().([]Filter)
+	return filters
+}
+
+//filterEntry 判断日志是否通过所有过滤函数
+func filterEntry(e *Entry) bool {
+	for _, f := range loadFilters() {
+		if !f(*e) {
+			return false
+		}
+	}
+	return true
+}
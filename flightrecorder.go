@@ -0,0 +1,167 @@
+package gclog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+)
+
+//飞行记录文件格式：64字节文件头 + 环形数据区
+//文件头依次为8字节魔数、8字节数据区大小、8字节累计写入的字节数（小端），其余保留
+const (
+	flightMagic      = "GCLOGFR1"
+	flightHeaderSize = 64
+	flightSinkName   = "flightrecorder"
+)
+
+var (
+	errNotFlightRecorder = errors.New("gclog: not a flight recorder file")
+	//errFlightBinary 飞行记录按换行还原日志行，二进制格式的日志中可能含有换行，无法还原
+	errFlightBinary = errors.New("gclog: flight recorder does not support BinaryEncoder")
+)
+
+//FlightRecorder 飞行记录器，将最近的日志循环写入固定大小的内存映射文件
+//写入只是内存拷贝，由操作系统回写到磁盘，进程崩溃（包括被kill -9）时已写入的内容不会丢失，主机掉电时不保证
+type FlightRecorder struct {
+	lock *sync.Mutex
+	file *os.File
+	mem  []byte //整个映射区，关闭后为nil
+	data []byte //环形数据区
+	pos  uint64 //累计写入的字节数
+}
+
+//EnableFlightRecorder 开启飞行记录，最近的日志循环写入filename，文件大小固定为size字节（至少4KB）
+//filename已存在时先重命名为filename.prev，保留上一次运行（可能是崩溃前）的记录，之后用ReadFlightRecorder读取
+//重复调用时替换之前的飞行记录器，记录文件不受SetEncryptionKey影响，以明文保存
+//飞行记录不支持BinaryEncoder，当前编码器为BinaryEncoder时返回错误
+//exp:
+//
+//	gclog.EnableFlightRecorder("/var/log/app.flight", 4<<20)
+//	//重启后查看崩溃前的日志
+//	data, err := gclog.ReadFlightRecorder("/var/log/app.flight.prev")
+func EnableFlightRecorder(filename string, size int) (*FlightRecorder, error) {
+	fileLock.RLock()
+	_, binaryEnc := encoder.(*BinaryEncoder)
+	fileLock.RUnlock()
+	if binaryEnc {
+		return nil, errFlightBinary
+	}
+	if _, err := os.Stat(filename); err == nil {
+		if err := os.Rename(filename, filename+".prev"); err != nil {
+			return nil, err
+		}
+	}
+	r, err := NewFlightRecorder(filename, size)
+	if err != nil {
+		return nil, err
+	}
+	AddSink(flightSinkName, r)
+	return r, nil
+}
+
+//DisableFlightRecorder 关闭飞行记录，记录文件保留
+func DisableFlightRecorder() {
+	RemoveSink(flightSinkName)
+}
+
+//NewFlightRecorder 创建飞行记录器，filename已存在时覆盖，需要自行通过AddSink注册
+func NewFlightRecorder(filename string, size int) (*FlightRecorder, error) {
+	if size < 4096 {
+		size = 4096
+	}
+	file, err := createFile(filename, os.O_RDWR|os.O_TRUNC)
+	if err != nil {
+		return nil, err
+	}
+	total := flightHeaderSize + size
+	if err := file.Truncate(int64(total)); err != nil {
+		file.Close()
+		return nil, err
+	}
+	mem, err := syscall.Mmap(int(file.Fd()), 0, total, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	copy(mem, flightMagic)
+	binary.LittleEndian.PutUint64(mem[8:], uint64(size))
+	return &FlightRecorder{lock: new(sync.Mutex), file: file, mem: mem, data: mem[flightHeaderSize:]}, nil
+}
+
+//Write 实现Sink，写入达到日志级别的日志（包括低于主输出级别、只投递给sink的日志），超过数据区大小的日志只保留末尾
+//开启后通过SetEncoder切换为BinaryEncoder时，二进制格式的日志不写入并返回错误
+func (r *FlightRecorder) Write(e *Entry, line []byte) error {
+	if len(line) > 0 && line[0] == BinaryMarker {
+		return errFlightBinary
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.mem == nil {
+		return errSinkClosed
+	}
+	size := uint64(len(r.data))
+	if uint64(len(line)) > size {
+		r.pos += uint64(len(line)) - size
+		line = line[uint64(len(line))-size:]
+	}
+	off := r.pos % size
+	n := copy(r.data[off:], line)
+	copy(r.data, line[n:])
+	r.pos += uint64(len(line))
+	//数据写完后再更新位置，崩溃时最多丢失正在写的一条
+	binary.LittleEndian.PutUint64(r.mem[16:], r.pos)
+	return nil
+}
+
+//Close 实现Sink，解除映射并关闭文件，文件内容保留
+func (r *FlightRecorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.mem == nil {
+		return nil
+	}
+	err := syscall.Munmap(r.mem)
+	r.mem, r.data = nil, nil
+	if errClose := r.file.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
+
+//ReadFlightRecorder 读取飞行记录文件，按时间顺序返回其中完整的日志行
+//被覆盖了一部分的最早一行与没有写完的最后一行会被丢弃
+func ReadFlightRecorder(filename string) ([]byte, error) {
+	mem, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(mem) < flightHeaderSize || string(mem[:8]) != flightMagic {
+		return nil, errNotFlightRecorder
+	}
+	size := binary.LittleEndian.Uint64(mem[8:])
+	pos := binary.LittleEndian.Uint64(mem[16:])
+	if size == 0 || uint64(len(mem)-flightHeaderSize) < size {
+		return nil, errNotFlightRecorder
+	}
+	data := mem[flightHeaderSize : flightHeaderSize+size]
+	var out []byte
+	if pos <= size {
+		out = append(out, data[:pos]...)
+	} else {
+		off := pos % size
+		out = append(append(out, data[off:]...), data[:off]...)
+		//环已写满，第一行可能只剩后半部分
+		if i := bytes.IndexByte(out, '\n'); i >= 0 {
+			out = out[i+1:]
+		} else {
+			out = out[:0]
+		}
+	}
+	if i := bytes.LastIndexByte(out, '\n'); i >= 0 {
+		return out[:i+1], nil
+	}
+	return out[:0], nil
+}
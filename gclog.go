@@ -3,15 +3,19 @@ package gclog
 //自动按照时间切分日志
 //kill -USR1 动态提升日志级别
 //kill -USR2 动态降低日志级别
+//kill -QUIT 输出goroutine调用栈、内存统计等诊断信息到日志
 //Verb 等接口直接输入对应前缀的日志，低于一定等级不进行输出
 
 import (
 	"fmt"
-	"log"
+	"io"
 	"os"
 	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -29,6 +33,8 @@ const (
 	WarningLevel
 	//ErrorLevel error
 	ErrorLevel = 5
+	//FatalLevel fatal，输出后执行退出钩子并退出进程
+	FatalLevel = 6
 )
 
 var headName = []string{
@@ -38,27 +44,35 @@ var headName = []string{
 	NoticeLevel:  "[NOTICE] ",
 	WarningLevel: "[WARNING] ",
 	ErrorLevel:   "[ERROR] ",
+	FatalLevel:   "[FATAL] ",
 }
 
 var (
 	isInitLogFile    bool          //是否已经初始化完毕
 	writeToFile      bool          //是否写入文件，=false写入屏幕
 	logFile          *os.File      //文件流
-	logLevel         int           //日志级别
+	logLevel         int32         //日志级别，原子读写，修改时持有levelLock
 	fileName         string        //日志文件名
-	levelLock        *sync.Mutex   //日志级别锁
-	fileLock         *sync.Mutex   //文件锁，写入时锁住，防止切日志时空指针
+	levelLock        *sync.Mutex   //日志级别锁，用于读取后修改的复合操作
+	fileLock         *sync.RWMutex //文件锁，写日志时持有读锁，切分日志、修改输出配置时持有写锁
 	logSliceInterval time.Duration //日志切分的时间间隔
 	logStorageTime   time.Duration //日志保存的时间
+	logMaxFiles      int64         //每个日志文件切分出的文件最多保留的个数，0为不限制，原子读写
+	logMaxTotalSize  int64         //每个日志文件切分出的文件最多保留的总大小，0为不限制，原子读写
 	logFileFlashTime time.Time     //上次文件流刷新的时间
+	output           io.Writer     //当前输出目标，由fileLock保护
 )
 
+//maxReuseBufSize 复用的编码缓冲区上限，超出后释放，避免偶发的大日志长期占用内存
+const maxReuseBufSize = 64 * 1024
+
 func init() {
 	writeToFile = false                 //默认不输出到文件
-	logLevel = NoticeLevel              //默认notice级别
+	logLevel = int32(NoticeLevel)       //默认notice级别
 	logStorageTime = 7 * 24 * time.Hour //日志文件默认保存7日
 	levelLock = new(sync.Mutex)
-	fileLock = new(sync.Mutex)
+	fileLock = new(sync.RWMutex)
+	output = os.Stderr //与标准库log一致，默认输出到标准错误
 
 	//启动信号量监听
 	go signalListen()
@@ -68,36 +82,45 @@ func init() {
 
 //InitLogFile 初始化日志文件
 func InitLogFile(filename string) error {
-	fileLock.Lock()
-	defer fileLock.Unlock()
-	//尝试打开文件
-	var err error
-	logFile, err = os.OpenFile(filename, os.O_APPEND+os.O_WRONLY, os.ModeAppend)
+	file, err := openLogFile(filename)
 	if err != nil {
-		//发现文件不存在，创建一个新的
-		if os.IsNotExist(err) == true {
-			var createErr error
-			// fmt.Printf("file %s exist, open", filename)
-			logFile, createErr = os.Create(filename)
-			if createErr != nil {
-				fmt.Printf("create file %s failed, bacauce %s", filename, createErr.Error())
-				return createErr
-			}
-		} else {
-			//非文件不存在error
-			fmt.Printf("open file %s failed, bacauce %s", filename, err.Error())
-			return err
-		}
+		return err
+	}
+	w, err := logWriter(file)
+	if err != nil {
+		file.Close()
+		return err
 	}
+	fileLock.Lock()
+	defer fileLock.Unlock()
+	logFile = file
 	writeToFile = true
+	output = w
 	fileName = filename
-	logFileFlashTime = time.Now().Round(time.Hour)
+	logFileFlashTime = mainSchedule().sliceTime()
+	atomic.StoreInt64(&logLines, 0)
 	return nil
 }
 
+//openLogFile 以追加方式打开日志文件，不存在时按SetFileMode设置的权限创建，目录不存在时递归创建
+func openLogFile(filename string) (*os.File, error) {
+	file, err := createFile(filename, os.O_APPEND|os.O_WRONLY)
+	if err != nil {
+		fmt.Printf("open file %s failed, bacauce %s", filename, err.Error())
+		return nil, err
+	}
+	return file, nil
+}
+
 //SetLogSliceInterval 设置日志切分的时间间隔，不设置则默认为1 day
+//小于1小时的间隔在间隔的整数倍时刻切分，切分出的文件名加上分钟，小于1分钟时再加上秒，exp: test_2018_04_08_16_30.log
 func SetLogSliceInterval(interval time.Duration) {
-	logSliceInterval = interval
+	logSliceInterval, logSliceMode = interval, sliceByInterval
+	//唤醒日志切分循环，按新的间隔检查
+	select {
+	case sliceWake <- struct{}{}:
+	default:
+	}
 }
 
 //SetLogStorageTime 设置日志保存的时间，不设置默认为7 day
@@ -109,108 +132,319 @@ func SetLogStorageTime(storageTime time.Duration) {
 	}
 }
 
+//SetLogRetentionLimits 设置切分出的文件最多保留的个数与总大小（字节），0为不限制，主日志文件与RouteLevels的文件各自计算
+//与SetLogStorageTime同时生效，从最旧的开始删除，直到保存时间、个数、总大小都满足，只按时间删除时突发的大量日志可能占满磁盘
+//设置后随日志切分循环的每次检查生效，不需要等到下次切分
+//exp: gclog.SetLogRetentionLimits(50, 10<<30)
+func SetLogRetentionLimits(maxFiles int, maxTotalSize int64) {
+	if maxFiles < 0 {
+		maxFiles = 0
+	}
+	if maxTotalSize < 0 {
+		maxTotalSize = 0
+	}
+	atomic.StoreInt64(&logMaxFiles, int64(maxFiles))
+	atomic.StoreInt64(&logMaxTotalSize, maxTotalSize)
+}
+
+//retentionLimits 取SetLogRetentionLimits设置的个数与总大小限制
+func retentionLimits() (int, int64) {
+	return int(atomic.LoadInt64(&logMaxFiles)), atomic.LoadInt64(&logMaxTotalSize)
+}
+
 //logSliceByDate 根据时间对日志进行切片
 func logSliceByDate() {
+	//Rotate的请求，本次循环切分后通知
+	var forced []chan struct{}
 	for {
+		fileLock.RLock()
+		toFile, flashTime := writeToFile, logFileFlashTime
+		fileLock.RUnlock()
+		force := len(forced) > 0
 		//不写入文件，不需要切分
-		if writeToFile == false {
+		if toFile == false {
 			Verb("logFile close, exit slice log loop")
-		} else if time.Now().After(logFileFlashTime.Add(logSliceInterval)) {
-			//当前时间在上次刷新时间+日志切分间隔时间之后，需要切日志
+		} else if force || mainSchedule().due(flashTime) || logLinesDue() {
+			//调用了Rotate，或当前时间在上次刷新时间+日志切分间隔时间+切分延迟之后，或达到切分行数，需要切日志
 			//清理过期日志
 			deleteLogFile()
 			//rename日志
 			moveLogFile()
 		}
-		time.Sleep(30 * time.Second)
+		//RouteLevels等设置的日志文件各自切分
+		for _, s := range loadSinks() {
+			if r, ok := s.sink.(slicer); ok {
+				r.sliceIfDue(force)
+			}
+		}
+		for _, done := range forced {
+			close(done)
+		}
+		forced = forced[:0]
+		sliceAuditFile()
+		enforceLogLimits()
+		applyRetention()
+		checkDiskSpace()
+		auditDeletions()
+		//达到切分行数或调用Rotate时提前唤醒
+		select {
+		case <-time.After(sliceCheckInterval()):
+		case <-sliceWake:
+		case done := <-rotateRequest:
+			forced = append(forced, done)
+		}
 	}
 }
 
+//sliceCheckInterval 返回日志切分循环的检查间隔，默认30秒，有小于5分钟的切分间隔时缩短为间隔的1/10，最短1秒
+func sliceCheckInterval() time.Duration {
+	check := 30 * time.Second
+	intervals := []time.Duration{logSliceInterval}
+	for _, s := range loadSinks() {
+		if f, ok := s.sink.(*FileSink); ok {
+			intervals = append(intervals, f.schedule().interval)
+		}
+	}
+	auditLock.RLock()
+	if auditSink != nil {
+		intervals = append(intervals, auditSink.schedule().interval)
+	}
+	auditLock.RUnlock()
+	for _, interval := range intervals {
+		if interval > 0 && interval/10 < check {
+			check = interval / 10
+		}
+	}
+	if check < time.Second {
+		check = time.Second
+	}
+	return check
+}
+
 //moveLogFile 将当前输出日志文件，根据时间变更名称
+//先rename再打开新文件，期间的日志继续写入改名后的旧文件，新文件打开后持有写锁切换输出，不会有日志输出到标准错误
 func moveLogFile() {
-	//对logFile加锁，日志暂时输出到标准输出（防止失败后无输出情况）
+	fileLock.RLock()
+	current, currentFile, flashTime := fileName, logFile, logFileFlashTime
+	fileLock.RUnlock()
+	start := time.Now()
+	newName, renamed, file, err := rotateFile(current, currentFile, mainSchedule(), flashTime)
+	if newName == "" {
+		//rename失败，继续使用旧的日志文件，下个周期重试
+		fileLock.Lock()
+		logFileFlashTime = mainSchedule().sliceTime()
+		atomic.StoreInt64(&logLines, 0)
+		fileLock.Unlock()
+		Warning("rename file %s failed, because %s", current, err.Error())
+		return
+	}
+	//打开新文件失败时不更新切分时间，下个循环只重试打开新文件
+	if err != nil {
+		Warning("open file %s failed, because %s, keep writing to %s", current, err.Error(), newName)
+		return
+	}
+	w, err := logWriter(file)
+	if err != nil {
+		file.Close()
+		Warning("open file %s failed, because %s, keep writing to %s", current, err.Error(), newName)
+		return
+	}
+
 	fileLock.Lock()
-	writeToFile = false
+	//切分期间调用了CloseFile或InitLogFile，不再切换
+	if !writeToFile || fileName != current {
+		fileLock.Unlock()
+		file.Close()
+		return
+	}
+	old := logFile
+	logFile = file
+	output = w
+	logFileFlashTime = mainSchedule().sliceTime()
+	atomic.StoreInt64(&logLines, 0)
+	fileLock.Unlock()
+	//持有写锁切换后不再有写入旧文件的操作
+	old.Close()
+	if renamed {
+		notifyRotation(current, newName, start)
+	}
+}
 
+//rotateFile 将日志文件按切分规则s改名，并打开一个同名的新文件，current为当前写入的文件，flashTime为上次切分时间
+//rename失败时newName为空，打开新文件失败时file为nil，此时日志仍在写入改名后的文件，下次调用时只重试打开新文件
+//renamed为是否由本进程改名，只打开了新文件时为false
+func rotateFile(filename string, current *os.File, s sliceSchedule, flashTime time.Time) (newName string, renamed bool, file *os.File, err error) {
 	//获取日志目录、日志名称等信息
-	dir, name, suffix := getFileInfo()
-	timeNow := time.Now()
-	//exp:"./test_2018_4_8_16.log"
-	newName := fmt.Sprintf("%s/%s_%02d_%02d_%02d_%02d%s", dir, name, timeNow.Year(), timeNow.Month(), timeNow.Day(), timeNow.Hour(), suffix)
+	dir, name, suffix := getFileInfo(filename)
+	//exp:"./test_2018_04_08_16.log"
+	base := dir + "/" + name + "_" + s.rotatedTime(flashTime)
+	target := base + suffix
+	//多进程共享日志文件时，同一时刻只有一个进程切分
+	if multiProcessEnabled() {
+		unlock, errLock := lockRotation(filename)
+		if errLock != nil {
+			return "", false, nil, errLock
+		}
+		defer unlock()
+	}
+	//当前写入的已不是filename（已被其他进程切分、上次改名后打开新文件失败或被外部删除）时只打开新文件，不再改名
+	if current != nil && !isCurrentFile(current, filename) {
+		file, err = openLogFile(filename)
+		return target, false, file, err
+	}
+	//同一小时内多次切分或重启时目标文件已存在，依次尝试加上_2、_3等后缀，不覆盖已有的文件
+	for i := 2; rotatedExists(target); i++ {
+		target = base + "_" + strconv.Itoa(i) + suffix
+	}
+	if err = os.Rename(filename, target); err != nil {
+		return "", false, nil, err
+	}
+	renameTimeIndex(filename, target)
+	//只由执行改名的进程上传，上传前等待切换与其他进程的写入结束
+	archiveRotated(target)
+	file, err = openLogFile(filename)
+	return target, true, file, err
+}
 
-	logFile.Close()
-	err := os.Rename(fileName, newName)
-	if err != nil {
-		Warning("rename file %s to %s failed, because %s", fileName, newName)
-		//不跳出，继续Init使用旧的日志文件
+//rotatedExists 判断切分的目标文件或保留策略压缩后的文件是否已存在
+func rotatedExists(target string) bool {
+	for _, name := range []string{target, target + ".gz"} {
+		if _, err := os.Lstat(name); err == nil {
+			return true
+		}
 	}
-	//rename成功，初始化全新的日志文件，失败，使用旧的日志文件
-	fileLock.Unlock()
-	InitLogFile(fileName)
+	return false
 }
 
 //deleteLogFile 清理过期日志
 func deleteLogFile() {
-	//删除操作不涉及logFile，因此不加锁
-	//获取日志目录、日志名称等信息
-	dir, name, suffix := getFileInfo()
-	file, err := os.Open(dir)
-	defer file.Close()
-	if err != nil {
-		Warning("try to delete file, open dir %s failed, because %s", dir, err.Error())
+	//删除操作不涉及logFile，只在读取配置时加锁
+	fileLock.RLock()
+	current, flashTime := fileName, logFileFlashTime
+	fileLock.RUnlock()
+	deleteExpiredFiles(current, flashTime.Add(-1*logStorageTime))
+}
+
+//enforceLogLimits 设置了个数或总大小限制时，每次循环检查主日志文件与RouteLevels的文件
+func enforceLogLimits() {
+	if maxFiles, maxTotalSize := retentionLimits(); maxFiles == 0 && maxTotalSize == 0 {
 		return
 	}
+	fileLock.RLock()
+	toFile := writeToFile
+	fileLock.RUnlock()
+	if toFile {
+		deleteLogFile()
+	}
+	for _, s := range loadSinks() {
+		if f, ok := s.sink.(*FileSink); ok {
+			f.deleteExpired()
+		}
+	}
+}
 
-	//取日志目录下，所有文件
-	fileNames, err := file.Readdir(0)
+//deleteExpiredFiles 从最旧的开始删除filename切分出的日志文件，直到修改时间都不早于before且满足SetLogRetentionLimits的限制，SetDeleteDryRun开启时只报告
+func deleteExpiredFiles(filename string, before time.Time) {
+	//设置了保留策略时由保留策略处理
+	if retentionActive() {
+		return
+	}
+	//获取日志目录、日志名称等信息
+	dir, name, suffix := getFileInfo(filename)
+	entries, err := os.ReadDir(dir)
 	if err != nil {
-		Warning("try to delete file, read dir %s info failed, because %s", dir, err.Error())
+		Warning("try to delete file, read dir %s failed, because %s", dir, err.Error())
 		return
 	}
-	for _, v := range fileNames {
-		//必须要包含name、后缀，创建时间在logStorageTime之前才能删除
-		if strings.Contains(v.Name(), name) && strings.Contains(v.Name(), suffix) &&
-			v.ModTime().Before(logFileFlashTime.Add(-1*logStorageTime)) {
-			//防止极端情况下，删除正在写入的log文件
-			if v.Name() == name+suffix {
-				continue
-			}
-
-			//删除对应文件
-			errRemove := os.Remove(dir + "/" + v.Name())
-			if errRemove != nil {
-				Warning("try to delete file, delete file name %s failed, because %s", dir+"/"+v.Name(), err.Error())
-				continue
-			} else {
-				Notice("try to delete file, delete file name %s success", dir+"/"+v.Name())
+	//必须是name切分出的文件
+	var rotated []rotatedFile
+	for _, v := range entries {
+		if !isRotatedFile(v.Name(), name, suffix) {
+			continue
+		}
+		if info, err := v.Info(); err == nil {
+			rotated = append(rotated, rotatedFile{path: dir + "/" + v.Name(), info: info})
+		}
+	}
+	sort.Slice(rotated, func(i, j int) bool { return rotated[i].info.ModTime().Before(rotated[j].info.ModTime()) })
+	maxFiles, maxTotalSize := retentionLimits()
+	overLimit := trimCount(rotated, maxFiles, maxTotalSize)
+	dryRun := isDeleteDryRun()
+	for i, f := range rotated {
+		reason := DeleteExpired
+		if !f.info.ModTime().Before(before) {
+			if i >= overLimit {
+				break
 			}
+			reason = DeleteOverLimit
+		}
+		if dryRun {
+			reportDryRun(f, reason)
+			continue
+		}
+		//删除对应文件
+		errRemove := os.Remove(f.path)
+		if os.IsNotExist(errRemove) {
+			//多进程时已被其他进程删除
+			continue
+		} else if errRemove != nil {
+			Warning("try to delete file, delete file name %s failed, because %s", f.path, errRemove.Error())
+			continue
 		}
+		removeTimeIndex(f.path)
+		recordDeletion(f, reason)
+		Notice("try to delete file, delete file name %s success", f.path)
 	}
 }
 
-//getFileInfo 取当前日志名称的信息，返回:日志目录,日志名称,日志后缀
-func getFileInfo() (string, string, string) {
+//trimCount 返回按修改时间从旧到新排序的files中，为满足个数与总大小限制需要删除的最旧文件数，限制为0时不限制
+func trimCount(files []rotatedFile, maxFiles int, maxTotalSize int64) int {
+	count, total := len(files), int64(0)
+	for _, f := range files {
+		total += f.info.Size()
+	}
+	n := 0
+	for n < len(files) && (maxFiles > 0 && count > maxFiles || maxTotalSize > 0 && total > maxTotalSize) {
+		count--
+		total -= files[n].info.Size()
+		n++
+	}
+	return n
+}
+
+//isRotatedFile 判断文件是否为name+suffix切分出的文件，exp: test_2018_04_08_16.log
+//name之后必须紧跟“_数字”，避免test.log的清理误删test_error.log等其他日志文件及正在写入的文件
+func isRotatedFile(file, name, suffix string) bool {
+	if !strings.HasPrefix(file, name+"_") || !strings.HasSuffix(file, suffix) {
+		return false
+	}
+	rest := file[len(name)+1:]
+	return len(rest) > len(suffix) && rest[0] >= '0' && rest[0] <= '9'
+}
+
+//getFileInfo 取日志文件名的信息，返回:日志目录,日志名称,日志后缀
+func getFileInfo(file string) (string, string, string) {
 	var (
 		dir    string
 		name   string
 		suffix string
 	)
-	tablePoint := strings.LastIndex(fileName, "/")
-	suffixPoint := strings.LastIndex(fileName, ".")
+	tablePoint := strings.LastIndex(file, "/")
+	suffixPoint := strings.LastIndex(file, ".")
 	//找不到“/”，默认选当前目录
 	if tablePoint == -1 {
 		dir = "./"
 	} else {
-		dir = fileName[:tablePoint]
+		dir = file[:tablePoint]
 	}
 
 	//找不到后缀的"."，默认后缀为.log，名称取"/"后所有字符
-	if suffixPoint == -1 {
-		name = fileName[tablePoint+1:]
+	if suffixPoint == -1 || suffixPoint < tablePoint {
+		name = file[tablePoint+1:]
 		suffix = ".log"
 	} else {
-		name = fileName[tablePoint+1 : suffixPoint]
-		suffix = fileName[suffixPoint:]
+		name = file[tablePoint+1 : suffixPoint]
+		suffix = file[suffixPoint:]
 	}
 
 	return dir, name, suffix
@@ -218,24 +452,29 @@ func getFileInfo() (string, string, string) {
 
 //CloseFile 关闭文件流，继续打印改为输出到标准输出
 func CloseFile() {
+	//先写入异步队列中的日志
+	Flush()
 	fileLock.Lock()
 	defer fileLock.Unlock()
 	logFile.Close()
 	writeToFile = false
+	output = os.Stderr
 }
 
-//signalListen 监听日志级别改变事件
+//signalListen 监听日志级别改变、诊断信息输出事件
 func signalListen() {
-	c := make(chan os.Signal)
-	signal.Notify(c, syscall.SIGUSR1, syscall.SIGUSR2)
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGQUIT)
 	defer signal.Stop(c)
 	for {
 		s := <-c
-		Warning("recvice signal %s")
+		Warning("recvice signal %s", s)
 		if s == syscall.SIGUSR1 {
 			LogLevelUp()
 		} else if s == syscall.SIGUSR2 {
 			LogLevelDown()
+		} else if s == syscall.SIGQUIT {
+			DumpDiagnostics()
 		}
 	}
 }
@@ -244,8 +483,9 @@ func signalListen() {
 func LogLevelUp() {
 	levelLock.Lock()
 	defer levelLock.Unlock()
-	if logLevel >= VerbLevel && logLevel < ErrorLevel {
-		logLevel++
+	level := atomic.LoadInt32(&logLevel)
+	if level >= int32(VerbLevel) && level < int32(ErrorLevel) {
+		atomic.StoreInt32(&logLevel, level+1)
 		Warning("log level up")
 	}
 }
@@ -254,79 +494,255 @@ func LogLevelUp() {
 func LogLevelDown() {
 	levelLock.Lock()
 	defer levelLock.Unlock()
-	if logLevel >= VerbLevel && logLevel < ErrorLevel {
-		logLevel--
+	level := atomic.LoadInt32(&logLevel)
+	if level >= int32(VerbLevel) && level < int32(ErrorLevel) {
+		atomic.StoreInt32(&logLevel, level-1)
 		Warning("log level down")
 	}
 }
 
+//LevelName 返回日志级别的名称，exp: INFO
+func LevelName(level int) string {
+	if level < VerbLevel || level >= len(headName) {
+		return "UNKNOWN"
+	}
+	head := headName[level]
+	return head[1 : len(head)-2]
+}
+
+//ParseLevel 根据级别名称（不区分大小写，exp: info、WARNING）或数字返回日志级别
+func ParseLevel(name string) (int, bool) {
+	if n, err := strconv.Atoi(name); err == nil && n >= VerbLevel && n < len(headName) {
+		return n, true
+	}
+	for level := range headName {
+		if strings.EqualFold(LevelName(level), name) {
+			return level, true
+		}
+	}
+	return 0, false
+}
+
 //SetLogLevel 设置日志级别
 func SetLogLevel(level int) {
 	levelLock.Lock()
 	defer levelLock.Unlock()
 	if level >= VerbLevel && level < ErrorLevel {
-		logLevel = level
+		atomic.StoreInt32(&logLevel, int32(level))
 	}
 }
 
+//GetLogLevel 返回当前日志级别
+func GetLogLevel() int {
+	return int(atomic.LoadInt32(&logLevel))
+}
+
+//levelEnabled 判断对应级别的日志是否需要进入logEntry，日志接口的热路径，达到日志级别时只做一次原子读
+//开启回溯缓冲时低级别的日志也需要记录
+func levelEnabled(level int) bool {
+	return int(atomic.LoadInt32(&logLevel)) <= level || atomic.LoadInt32(&backtraceOn) == 1
+}
+
+//logLevelEnabled 只按日志级别判断是否需要输出
+func logLevelEnabled(level int) bool {
+	return int(atomic.LoadInt32(&logLevel)) <= level
+}
+
 //Verb 输出verb日志
 func Verb(msg string, v ...interface{}) {
-	if logLevel <= VerbLevel {
-		writeLog(headName[VerbLevel], fmt.Sprintf(msg, v...))
+	if levelEnabled(VerbLevel) {
+		writeLog(VerbLevel, sprintf(msg, v...))
 	}
 }
 
-//Debugln 输出debug的日志，自带换行符
+//Debugln 输出debug的日志，参数之间以空格分隔
 func Debugln(v ...interface{}) {
-	if logLevel >= DebugLevel {
-		writeLog(headName[DebugLevel], fmt.Sprintln(v...))
+	if levelEnabled(DebugLevel) {
+		writeLog(DebugLevel, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
 	}
 }
 
 //Debug 输出debug日志
 func Debug(msg string, v ...interface{}) {
-	if logLevel <= DebugLevel {
-		writeLog(headName[DebugLevel], fmt.Sprintf(msg, v...))
+	if levelEnabled(DebugLevel) {
+		writeLog(DebugLevel, sprintf(msg, v...))
 	}
 }
 
 //Info 输出info日志
 func Info(msg string, v ...interface{}) {
-	if logLevel <= InfoLevel {
-		writeLog(headName[InfoLevel], fmt.Sprintf(msg, v...))
+	if levelEnabled(InfoLevel) {
+		writeLog(InfoLevel, sprintf(msg, v...))
 	}
 }
 
 //Notice 输出notice日志
 func Notice(msg string, v ...interface{}) {
-	if logLevel <= NoticeLevel {
-		writeLog(headName[NoticeLevel], fmt.Sprintf(msg, v...))
+	if levelEnabled(NoticeLevel) {
+		writeLog(NoticeLevel, sprintf(msg, v...))
 	}
 }
 
 //Warning 输出warning日志
 func Warning(msg string, v ...interface{}) {
-	if logLevel <= WarningLevel {
-		writeLog(headName[WarningLevel], fmt.Sprintf(msg, v...))
+	if levelEnabled(WarningLevel) {
+		writeLog(WarningLevel, sprintf(msg, v...))
 	}
 }
 
 //Error 输出error日志
 func Error(msg string, v ...interface{}) {
-	if logLevel <= ErrorLevel {
-		writeLog(headName[ErrorLevel], fmt.Sprintf(msg, v...))
+	if levelEnabled(ErrorLevel) {
+		writeLog(ErrorLevel, sprintf(msg, v...))
 	}
 }
 
-//writeLog 输出日志的方法
-func writeLog(level string, msg string) {
-	if writeToFile == true {
-		fileLock.Lock()
-		defer fileLock.Unlock()
-		logger := log.New(logFile, level, log.LstdFlags+log.Lshortfile)
-		logger.Output(3, level+msg)
+//Fatal 输出fatal日志，执行退出钩子后以状态码1退出进程
+//fatal日志不受日志级别限制，总是输出
+func Fatal(msg string, v ...interface{}) {
+	writeLog(FatalLevel, sprintf(msg, v...))
+	exit(1)
+}
+
+//writeLog 输出日志的方法，fields之后附加全局字段，由当前编码器编码后输出
+//writeLog需要由日志接口直接调用，调用者位置取调用日志接口处（再加上AddCallerSkip设置的层数）
+func writeLog(level int, msg string, fields ...Field) {
+	e := getEntry()
+	e.Level, e.Message, e.Fields = level, msg, fields
+	logEntry(e, 2+int(atomic.LoadInt32(&callerSkip)), callerEnabled(level), false, nil)
+	putEntry(e)
+}
+
+//writeLogForced 与writeLog相同，但不受日志级别、屏蔽规则与过滤函数限制
+//用于诊断信息、构建信息、配置等必须输出的日志，需要由日志接口直接调用
+func writeLogForced(level int, msg string, fields ...Field) {
+	e := getEntry()
+	e.Level, e.Message, e.Fields = level, msg, fields
+	logEntry(e, 2+int(atomic.LoadInt32(&callerSkip)), callerEnabled(level), true, nil)
+	putEntry(e)
+}
+
+//writeLogAt 与writeLog相同，但调用者位置使用pcs中第一个不属于runtime的帧，用于在defer中记录panic发生处
+//pcs为空或没有这样的帧时不输出调用者位置
+func writeLogAt(level int, msg string, pcs []uintptr) {
+	e := getEntry()
+	e.Level, e.Message = level, msg
+	if callerEnabled(level) {
+		e.File, e.Line = panicFrame(pcs)
+	}
+	logEntry(e, 2+int(atomic.LoadInt32(&callerSkip)), false, false, nil)
+	putEntry(e)
+}
+
+//logEntry 补全时间、调用者等信息后输出日志
+//skip为logEntry的调用者到业务代码之间的层数，exp: 业务代码->Info->writeLog->logEntry 时skip为2
+//withCaller为false时不取调用者位置，File为空，transformers为logger自身的变换函数
+//force为true时不受日志级别、屏蔽规则与过滤函数限制，fatal日志总是如此
+//logEntry返回后不再引用e，调用方可以复用e
+func logEntry(e *Entry, skip int, withCaller, force bool, transformers []Transformer) {
+	e.Time = now()
+	force = force || e.Level == FatalLevel
+	//限制容量，变换函数与钩子追加字段时不会写入logger共享的字段数组
+	e.Fields = e.Fields[:len(e.Fields):len(e.Fields)]
+	//变换后级别低于日志级别或匹配屏蔽规则时丢弃
+	if level := e.Level; !force {
+		transformEntry(e, transformers)
+		if e.Level != level && !levelEnabled(e.Level) {
+			return
+		}
+		if suppressed(e.Message) {
+			return
+		}
+	}
+	e.Message = maskMessage(e.Message)
+	e.Fields = redactFields(enrichFields(appendGlobalFields(e.Fields)))
+	//0为logEntry，1+skip为业务代码
+	if withCaller {
+		if file, line, ok := callerFileLine(1 + skip); ok {
+			e.File, e.Line = file, line
+		} else {
+			e.File = "???"
+		}
+	}
+	//被过滤函数丢弃
+	if !force && !filterEntry(e) {
+		return
+	}
+	//记录到回溯缓冲，低于日志级别的日志只记录不输出
+	recordBacktrace(e)
+	if !force && !logLevelEnabled(e.Level) {
+		return
+	}
+	//同一消息过多时按采样设置丢弃
+	if !force && sampled(e) {
+		return
+	}
+	//磁盘空间不足降级时只输出error及以上级别
+	if diskDegraded(e.Level) {
+		return
+	}
+	//达到调用栈输出级别，附加业务代码处的调用栈
+	if needStackTrace(e.Level) {
+		e.Stack = formatFrames(callers(1 + skip))
+	}
+	fireHooks(&preHooks, e)
+	writeEntry(e)
+	fireHooks(&postHooks, e)
+}
+
+//writeEntry 编码日志并写入sink与主输出
+func writeEntry(e *Entry) {
+	//编码与写入只持有读锁，多个goroutine可以并发写，只有切分日志、修改配置时互斥
+	//并发写入时文件中的顺序与序号可能略有差异，按序号排序即可还原
+	fileLock.RLock()
+	e.Seq = nextSeq()
+	e.ID = nextEntryID(e.Time)
+	buf := getBuffer()
+	buf.b = encoder.Encode(buf.b, e)
+	//设置了签名密钥时附加签名
+	if key := loadHMACKey(); key != nil {
+		buf.b = signEntry(buf.b, e, key)
+	}
+	//投递给额外的sink，低于主输出级别的日志只写入sink
+	writeSinks(e, buf.b)
+	if !outputEnabled(e.Level) {
+		fileLock.RUnlock()
+		putBuffer(buf)
+		return
+	}
+	//控制台分流时低级别日志输出到标准输出
+	stdout := toStdout(e.Level)
+	//异步模式下放入队列，由后台goroutine批量写入
+	if atomic.LoadInt32(&asyncEnable) == 1 {
+		fileLock.RUnlock()
+		if enqueueAsync(buf, stdout) {
+			//需要落盘时等待后台goroutine写入
+			if !stdout && needSync(e.Level) {
+				Flush()
+				syncLogFile()
+			}
+			return
+		}
+		fileLock.RLock()
+	}
+	if stdout {
+		os.Stdout.Write(buf.b)
 	} else {
-		log.SetFlags(log.LstdFlags + log.Lshortfile)
-		log.Output(3, level+msg)
+		output.Write(buf.b)
+		countLogLines(buf.b)
+		if writeToFile && needSync(e.Level) {
+			logFile.Sync()
+		}
+	}
+	fileLock.RUnlock()
+	putBuffer(buf)
+}
+
+//sprintf 格式化日志内容，没有参数且msg中没有%时直接返回msg，省去一次格式化
+//msg中有%时总是格式化，"%%"等转义与没有参数时相同
+func sprintf(msg string, v ...interface{}) string {
+	if len(v) == 0 && strings.IndexByte(msg, '%') < 0 {
+		return msg
 	}
+	return fmt.Sprintf(msg, v...)
 }
@@ -0,0 +1,375 @@
+package gclog
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//gcsScope 申请token的权限
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+//gcsMetadataToken GCE、GKE元数据服务的token地址
+const gcsMetadataToken = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+//GCSOptions Google Cloud Storage的设置
+type GCSOptions struct {
+	Bucket string
+	//CredentialsFile 服务账号的JSON密钥文件，为空时使用GCE、GKE元数据服务的默认服务账号
+	CredentialsFile string
+	StorageClass    string //存储类型，exp: NEARLINE，为空时使用bucket的默认值
+	//ChunkSize 断点续传每次上传的大小，向上取整为256KiB的倍数，<=0时默认8MiB
+	ChunkSize int64
+	//Endpoint 服务地址，为空时为https://storage.googleapis.com，使用模拟器时设置
+	Endpoint string
+	TLS      *TLSOptions   //证书设置，为nil时使用系统证书
+	Timeout  time.Duration //一次请求的超时，<=0时默认5min
+}
+
+//GCSTarget 上传到Google Cloud Storage的归档目标，使用断点续传（resumable upload），网络中断后从已上传的位置继续
+//对象的customTime为日志文件的修改时间，bucket的生命周期规则可以用daysSinceCustomTime按日志时间转存或删除，与上传时间无关
+type GCSTarget struct {
+	opts      GCSOptions
+	endpoint  string
+	client    *http.Client
+	account   *gcsServiceAccount
+	tokenLock sync.Mutex
+	token     string    //access token，tokenLock保护
+	expires   time.Time //token的过期时间，tokenLock保护
+}
+
+//gcsServiceAccount 服务账号密钥
+type gcsServiceAccount struct {
+	email    string
+	tokenURI string
+	key      *rsa.PrivateKey
+}
+
+//gcsStatusError 服务端返回的非预期状态码
+type gcsStatusError struct {
+	status int
+	msg    string
+}
+
+func (e *gcsStatusError) Error() string {
+	return fmt.Sprintf("gclog: gcs returned %d: %s", e.status, e.msg)
+}
+
+//NewGCSTarget 创建GCS归档目标
+//exp: gcs, err := gclog.NewGCSTarget(gclog.GCSOptions{Bucket: "app-logs", CredentialsFile: "/etc/app/sa.json"})
+func NewGCSTarget(opts GCSOptions) (*GCSTarget, error) {
+	if opts.Bucket == "" {
+		return nil, errors.New("gclog: empty gcs bucket")
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = "https://storage.googleapis.com"
+	}
+	base, err := url.Parse(opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" || base.Host == "" {
+		return nil, fmt.Errorf("gclog: invalid gcs endpoint %q", opts.Endpoint)
+	}
+	const quantum = 256 << 10
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = 8 << 20
+	}
+	opts.ChunkSize = (opts.ChunkSize + quantum - 1) / quantum * quantum
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Minute
+	}
+	t := &GCSTarget{opts: opts, endpoint: strings.TrimRight(opts.Endpoint, "/")}
+	if opts.CredentialsFile != "" {
+		if t.account, err = loadGCSServiceAccount(opts.CredentialsFile); err != nil {
+			return nil, err
+		}
+	}
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if opts.TLS != nil {
+		if transport.TLSClientConfig, err = opts.TLS.config(base.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+	t.client = &http.Client{Transport: transport, Timeout: opts.Timeout}
+	return t, nil
+}
+
+//loadGCSServiceAccount 读取服务账号的JSON密钥文件
+func loadGCSServiceAccount(filename string) (*gcsServiceAccount, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode([]byte(file.PrivateKey))
+	if block == nil || file.ClientEmail == "" {
+		return nil, fmt.Errorf("gclog: invalid gcs credentials file %s", filename)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("gclog: gcs credentials file %s does not contain an RSA key", filename)
+	}
+	if file.TokenURI == "" {
+		file.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return &gcsServiceAccount{email: file.ClientEmail, tokenURI: file.TokenURI, key: key}, nil
+}
+
+//Upload 实现ArchiveTarget，创建断点续传会话后分块上传，请求失败时查询已上传的位置继续，连续失败3次时返回错误
+func (t *GCSTarget) Upload(key string, file *os.File, size int64) error {
+	session, err := t.startSession(key, file, size)
+	if err != nil {
+		return err
+	}
+	offset, failures := int64(0), 0
+	for {
+		n := t.opts.ChunkSize
+		if size-offset < n {
+			n = size - offset
+		}
+		next, done, err := t.putChunk(session, file, offset, n, size)
+		if done {
+			return nil
+		}
+		if err != nil {
+			//会话过期或请求被拒绝，由归档器重新上传
+			if e, ok := err.(*gcsStatusError); ok && e.status != http.StatusTooManyRequests && e.status < 500 {
+				return err
+			}
+			if failures++; failures > 3 {
+				return err
+			}
+			time.Sleep(time.Duration(failures) * time.Second)
+			if next, done, err = t.putChunk(session, nil, 0, 0, size); done {
+				return nil
+			} else if err != nil {
+				continue
+			}
+		} else {
+			failures = 0
+		}
+		offset = next
+	}
+}
+
+//Delete 实现ArchiveDeleter，对象不存在时返回nil
+func (t *GCSTarget) Delete(key string) error {
+	req, err := http.NewRequest("DELETE", t.endpoint+"/storage/v1/b/"+url.PathEscape(t.opts.Bucket)+"/o/"+url.PathEscape(key), nil)
+	if err != nil {
+		return err
+	}
+	if err := t.authorize(req); err != nil {
+		return err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 || resp.StatusCode == http.StatusNotFound {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return gcsResponseError(resp)
+}
+
+//startSession 创建断点续传会话，返回会话地址
+func (t *GCSTarget) startSession(key string, file *os.File, size int64) (string, error) {
+	meta := map[string]string{"name": key, "contentType": "application/octet-stream"}
+	if info, err := file.Stat(); err == nil {
+		meta["customTime"] = info.ModTime().UTC().Format(time.RFC3339)
+	}
+	if t.opts.StorageClass != "" {
+		meta["storageClass"] = t.opts.StorageClass
+	}
+	body, _ := json.Marshal(meta)
+	query := url.Values{}
+	query.Set("uploadType", "resumable")
+	query.Set("name", key)
+	req, err := http.NewRequest("POST", t.endpoint+"/upload/storage/v1/b/"+url.PathEscape(t.opts.Bucket)+"/o?"+query.Encode(), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("X-Upload-Content-Type", "application/octet-stream")
+	req.Header.Set("X-Upload-Content-Length", strconv.FormatInt(size, 10))
+	if err := t.authorize(req); err != nil {
+		return "", err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", gcsResponseError(resp)
+	}
+	io.Copy(io.Discard, resp.Body)
+	session := resp.Header.Get("Location")
+	if session == "" {
+		return "", errors.New("gclog: gcs did not return an upload session")
+	}
+	return session, nil
+}
+
+//putChunk 上传[offset, offset+n)，file为nil时只查询已上传的位置，返回下一次上传的位置，上传完成时done为true
+func (t *GCSTarget) putChunk(session string, file *os.File, offset, n, size int64) (int64, bool, error) {
+	var body io.Reader = http.NoBody
+	contentRange := "bytes */" + strconv.FormatInt(size, 10)
+	if file != nil && n > 0 {
+		body = io.NewSectionReader(file, offset, n)
+		contentRange = fmt.Sprintf("bytes %d-%d/%d", offset, offset+n-1, size)
+	}
+	req, err := http.NewRequest("PUT", session, body)
+	if err != nil {
+		return 0, false, err
+	}
+	if file != nil {
+		req.ContentLength = n
+	}
+	req.Header.Set("Content-Range", contentRange)
+	if err := t.authorize(req); err != nil {
+		return 0, false, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return 0, false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		io.Copy(io.Discard, resp.Body)
+		return size, true, nil
+	case http.StatusPermanentRedirect:
+		//308 Resume Incomplete，Range为已上传的范围，exp: bytes=0-8388607，没有Range时为还未上传
+		io.Copy(io.Discard, resp.Body)
+		r := resp.Header.Get("Range")
+		if i := strings.LastIndexByte(r, '-'); i >= 0 {
+			end, err := strconv.ParseInt(r[i+1:], 10, 64)
+			if err != nil {
+				return 0, false, err
+			}
+			return end + 1, false, nil
+		}
+		return 0, false, nil
+	}
+	return 0, false, gcsResponseError(resp)
+}
+
+//authorize 设置Authorization头
+func (t *GCSTarget) authorize(req *http.Request) error {
+	token, err := t.accessToken()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+//accessToken 返回access token，过期前5分钟重新申请
+func (t *GCSTarget) accessToken() (string, error) {
+	t.tokenLock.Lock()
+	defer t.tokenLock.Unlock()
+	if t.token != "" && time.Now().Before(t.expires.Add(-5*time.Minute)) {
+		return t.token, nil
+	}
+	var req *http.Request
+	var err error
+	if t.account != nil {
+		assertion, errSign := t.account.assertion()
+		if errSign != nil {
+			return "", errSign
+		}
+		form := url.Values{}
+		form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		form.Set("assertion", assertion)
+		req, err = http.NewRequest("POST", t.account.tokenURI, strings.NewReader(form.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	} else {
+		req, err = http.NewRequest("GET", gcsMetadataToken, nil)
+		if err == nil {
+			req.Header.Set("Metadata-Flavor", "Google")
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", gcsResponseError(resp)
+	}
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&result); err != nil {
+		return "", err
+	}
+	if result.AccessToken == "" {
+		return "", errors.New("gclog: gcs returned an empty access token")
+	}
+	t.token = result.AccessToken
+	t.expires = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+	return t.token, nil
+}
+
+//assertion 返回申请token的JWT，RS256签名，有效期1小时
+func (a *gcsServiceAccount) assertion() (string, error) {
+	now := time.Now().Unix()
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   a.email,
+		"scope": gcsScope,
+		"aud":   a.tokenURI,
+		"iat":   now,
+		"exp":   now + 3600,
+	})
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." + enc.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(signature), nil
+}
+
+//gcsResponseError 读取错误响应
+func gcsResponseError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return &gcsStatusError{status: resp.StatusCode, msg: strings.TrimSpace(string(msg))}
+}
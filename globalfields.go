@@ -0,0 +1,67 @@
+package gclog
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	globalFieldsLock = new(sync.RWMutex)
+	globalFields     []Field //附加到每条日志的全局字段
+	resourceFields   []Field //主机名、pid、服务名等资源字段
+	buildFields      []Field //版本号、提交id等构建信息字段
+)
+
+const (
+	//HostField 主机名字段名
+	HostField = "host"
+	//PidField 进程id字段名
+	PidField = "pid"
+	//ServiceField 服务名字段名
+	ServiceField = "service"
+)
+
+//SetGlobalFields 设置附加到每条日志的全局字段，覆盖之前的设置
+//exp: gclog.SetGlobalFields(gclog.F("region", "cn-north"), gclog.F("env", "prod"))
+func SetGlobalFields(fields ...Field) {
+	globalFieldsLock.Lock()
+	defer globalFieldsLock.Unlock()
+	globalFields = append([]Field(nil), fields...)
+}
+
+//EnableResourceFields 开启主机名、pid、服务名字段，附加到每条日志，用于多机汇总时区分来源
+//service为空时使用进程名
+func EnableResourceFields(service string) {
+	if service == "" {
+		service = filepath.Base(os.Args[0])
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	globalFieldsLock.Lock()
+	defer globalFieldsLock.Unlock()
+	resourceFields = []Field{F(HostField, host), F(PidField, os.Getpid()), F(ServiceField, service)}
+}
+
+//DisableResourceFields 关闭主机名、pid、服务名字段
+func DisableResourceFields() {
+	globalFieldsLock.Lock()
+	defer globalFieldsLock.Unlock()
+	resourceFields = nil
+}
+
+//appendGlobalFields 在fields后追加资源字段、构建信息字段和全局字段
+func appendGlobalFields(fields []Field) []Field {
+	globalFieldsLock.RLock()
+	defer globalFieldsLock.RUnlock()
+	if len(resourceFields) == 0 && len(buildFields) == 0 && len(globalFields) == 0 {
+		return fields
+	}
+	all := make([]Field, 0, len(fields)+len(resourceFields)+len(buildFields)+len(globalFields))
+	all = append(all, fields...)
+	all = append(all, resourceFields...)
+	all = append(all, buildFields...)
+	return append(all, globalFields...)
+}
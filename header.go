@@ -0,0 +1,166 @@
+package gclog
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//headerPart 日志头模板中的一段，placeholder为空时表示字面文本
+type headerPart struct {
+	literal     string
+	placeholder string
+}
+
+//headerTemplate 解析后的日志头模板
+type headerTemplate struct {
+	tpl        string //模板原文
+	parts      []headerPart
+	withSeq    bool //模板中包含{{seq}}，行尾不再输出seq
+	withID     bool //模板中包含{{id}}，行尾不再输出id
+	withLogger bool //模板中包含{{logger}}，行尾不再输出logger
+}
+
+//headerPlaceholders 日志头模板支持的占位符
+var headerPlaceholders = map[string]bool{
+	"time":      true, //日志时间
+	"level":     true, //带括号的级别，exp: [INFO]
+	"levelname": true, //级别名称，exp: INFO
+	"caller":    true, //调用者，exp: main.go:12
+	"pid":       true, //进程id
+	"seq":       true, //序号，需要EnableSequence
+	"id":        true, //日志id，需要EnableEntryID
+	"logger":    true, //logger名称
+}
+
+//defaultHeader 默认的日志头模板，与基于标准库log输出时的格式相同，级别在调用者之后再输出一次
+const defaultHeader = "{{level}} {{time}} {{caller}}: {{level}}"
+
+//levelNameWidth 级别名称的最大宽度，即WARNING的长度
+const levelNameWidth = 7
+
+var (
+	header       = mustParseHeader(defaultHeader) //当前日志头模板，由fileLock保护
+	headerPid    = strconv.Itoa(os.Getpid())
+	levelPadding bool //是否将级别补齐到固定宽度，由fileLock保护
+)
+
+//SetLevelPadding 设置是否将TextEncoder的级别补齐到固定宽度，使各列对齐
+//exp: 开启后输出 [INFO]    和 [WARNING] 宽度相同
+func SetLevelPadding(pad bool) {
+	fileLock.Lock()
+	defer fileLock.Unlock()
+	levelPadding = pad
+}
+
+//SetHeaderTemplate 设置TextEncoder的日志头模板，日志头与消息之间以一个空格分隔，为空时恢复默认模板
+//支持的占位符: {{time}} {{level}} {{levelname}} {{caller}} {{pid}} {{seq}} {{id}} {{logger}}
+//exp: gclog.SetHeaderTemplate("{{time}} {{level}} {{caller}} -")
+//输出: 2018/04/08 16:00:00 [INFO] main.go:12 - message
+func SetHeaderTemplate(tpl string) error {
+	if tpl == "" {
+		tpl = defaultHeader
+	}
+	h, err := parseHeader(tpl)
+	if err != nil {
+		return err
+	}
+	fileLock.Lock()
+	defer fileLock.Unlock()
+	header = h
+	return nil
+}
+
+//parseHeader 解析日志头模板
+func parseHeader(tpl string) (*headerTemplate, error) {
+	h := &headerTemplate{tpl: tpl}
+	for len(tpl) > 0 {
+		start := strings.Index(tpl, "{{")
+		if start < 0 {
+			h.parts = append(h.parts, headerPart{literal: tpl})
+			break
+		}
+		if start > 0 {
+			h.parts = append(h.parts, headerPart{literal: tpl[:start]})
+		}
+		end := strings.Index(tpl[start:], "}}")
+		if end < 0 {
+			return nil, fmt.Errorf("header template: unclosed placeholder at %q", tpl[start:])
+		}
+		name := strings.TrimSpace(tpl[start+2 : start+end])
+		if !headerPlaceholders[name] {
+			return nil, fmt.Errorf("header template: unknown placeholder {{%s}}", name)
+		}
+		h.parts = append(h.parts, headerPart{placeholder: name})
+		if name == "seq" {
+			h.withSeq = true
+		} else if name == "id" {
+			h.withID = true
+		} else if name == "logger" {
+			h.withLogger = true
+		}
+		tpl = tpl[start+end+2:]
+	}
+	return h, nil
+}
+
+//mustParseHeader 解析内置模板，失败时panic
+func mustParseHeader(tpl string) *headerTemplate {
+	h, err := parseHeader(tpl)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+//append 按模板追加日志头，需要在持有fileLock时调用
+func (h *headerTemplate) append(buf []byte, e *Entry) []byte {
+	for _, p := range h.parts {
+		switch p.placeholder {
+		case "":
+			buf = append(buf, p.literal...)
+		case "time":
+			buf = appendTime(buf, e.Time, "2006/01/02 15:04:05")
+		case "level":
+			name := LevelName(e.Level)
+			buf = append(buf, '[')
+			buf = append(buf, name...)
+			buf = append(buf, ']')
+			buf = appendLevelPadding(buf, name)
+		case "levelname":
+			name := LevelName(e.Level)
+			buf = append(buf, name...)
+			buf = appendLevelPadding(buf, name)
+		case "caller":
+			buf = append(buf, formatCaller(e.File)...)
+			if e.File != "" {
+				buf = append(buf, ':')
+				buf = strconv.AppendInt(buf, int64(e.Line), 10)
+			}
+		case "pid":
+			buf = append(buf, headerPid...)
+		case "seq":
+			buf = strconv.AppendUint(buf, e.Seq, 10)
+		case "id":
+			buf = append(buf, e.ID...)
+		case "logger":
+			buf = append(buf, e.Logger...)
+		}
+	}
+	if len(buf) > 0 && buf[len(buf)-1] != ' ' {
+		buf = append(buf, ' ')
+	}
+	return buf
+}
+
+//appendLevelPadding 开启级别补齐时，按级别名称的长度补齐空格，需要在持有fileLock时调用
+func appendLevelPadding(buf []byte, name string) []byte {
+	if !levelPadding {
+		return buf
+	}
+	for i := len(name); i < levelNameWidth; i++ {
+		buf = append(buf, ' ')
+	}
+	return buf
+}
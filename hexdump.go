@@ -0,0 +1,35 @@
+package gclog
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+//hexDumpLimit DebugHex单次最多输出的字节数，超出部分截断
+var hexDumpLimit = 4096
+
+//SetHexDumpLimit 设置DebugHex单次最多输出的字节数，<=0表示不限制
+func SetHexDumpLimit(limit int) {
+	hexDumpLimit = limit
+}
+
+//DebugHex 以debug级别输出二进制数据的hex+ASCII对照格式，方便调试协议数据
+//exp:
+//	[DEBUG] packet (len=11):
+//	00000000  48 65 6c 6c 6f 20 77 6f  72 6c 64                 |Hello world|
+func DebugHex(label string, data []byte) {
+	if levelEnabled(DebugLevel) {
+		writeLog(DebugLevel, formatHex(label, data))
+	}
+}
+
+//formatHex 生成hex dump文本，超过hexDumpLimit时截断并注明
+func formatHex(label string, data []byte) string {
+	show := data
+	head := fmt.Sprintf("%s (len=%d):\n", label, len(data))
+	if hexDumpLimit > 0 && len(data) > hexDumpLimit {
+		show = data[:hexDumpLimit]
+		head = fmt.Sprintf("%s (len=%d, truncated to %d):\n", label, len(data), hexDumpLimit)
+	}
+	return head + hex.Dump(show)
+}
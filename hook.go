@@ -0,0 +1,96 @@
+package gclog
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+//Hook 日志钩子，用于在不修改gclog的情况下扩展日志处理，如补充字段、计数、转发
+//前置钩子在编码前调用，可以修改Entry；后置钩子在写入主输出与sink之后调用，不应再修改Entry
+//Fire返回的错误只计数，不影响日志的写入与其他钩子
+type Hook interface {
+	Fire(e *Entry) error
+}
+
+//HookFunc 函数形式的Hook
+type HookFunc func(e *Entry) error
+
+//Fire 实现Hook
+func (f HookFunc) Fire(e *Entry) error {
+	return f(e)
+}
+
+//HookChain 按顺序调用的一组钩子，本身也是Hook，可以继续组合
+//前面的钩子返回错误时仍会调用后面的钩子，返回第一个错误
+type HookChain []Hook
+
+//Fire 实现Hook
+func (c HookChain) Fire(e *Entry) error {
+	var first error
+	for _, h := range c {
+		if err := h.Fire(e); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+//Chain 将多个钩子组合为一个，按参数顺序调用
+//exp: gclog.AddPreHook(gclog.Chain(addRegion, countLevel))
+func Chain(hooks ...Hook) Hook {
+	return HookChain(append([]Hook(nil), hooks...))
+}
+
+var (
+	hookLock   = new(sync.Mutex) //注册钩子时加锁
+	preHooks   atomic.Value      //前置钩子，[]Hook，注册时整体替换，写日志时无锁读取
+	postHooks  atomic.Value      //后置钩子，[]Hook
+	hookErrors uint64            //钩子返回错误的次数，原子读写
+)
+
+//AddPreHook 注册前置钩子，在日志编码前按注册顺序调用，钩子对Entry的修改会反映到输出中
+//exp:
+//
+//	gclog.AddPreHook(gclog.HookFunc(func(e *gclog.Entry) error {
+//		e.Fields = append(e.Fields, gclog.F("region", region))
+//		return nil
+//	}))
+func AddPreHook(h Hook) {
+	addHook(&preHooks, h)
+}
+
+//AddPostHook 注册后置钩子，在日志写入主输出与sink之后按注册顺序调用
+//exp: gclog.AddPostHook(gclog.HookFunc(func(e *gclog.Entry) error { counter.Inc(e.Level); return nil }))
+func AddPostHook(h Hook) {
+	addHook(&postHooks, h)
+}
+
+//ClearHooks 移除所有前置与后置钩子
+func ClearHooks() {
+	hookLock.Lock()
+	defer hookLock.Unlock()
+	preHooks.Store([]Hook(nil))
+	postHooks.Store([]Hook(nil))
+}
+
+//addHook 复制钩子列表，追加后替换
+func addHook(list *atomic.Value, h Hook) {
+	if h == nil {
+		return
+	}
+	hookLock.Lock()
+	defer hookLock.Unlock()
+	old, _ := list.Load().([]Hook)
+	hooks := make([]Hook, 0, len(old)+1)
+	list.Store(append(append(hooks, old...), h))
+}
+
+//fireHooks 调用钩子列表，错误只计数
+func fireHooks(list *atomic.Value, e *Entry) {
+	hooks, _ := list.Load().([]Hook)
+	for _, h := range hooks {
+		if err := h.Fire(e); err != nil {
+			atomic.AddUint64(&hookErrors, 1)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package gclog
+
+import (
+	"crypto/rand"
+	"math/big"
+	"sync/atomic"
+	"time"
+)
+
+var rotationDelayNanos int64 //本进程的切分延迟，原子读写
+
+//SetRotationJitter 设置切分的随机延迟范围，每个进程在[0, jitter)内随机取一个固定的延迟，到达切分时间后再等待该延迟才切分
+//共享NFS、SAN卷的大量实例不会在同一秒改名、压缩、上传，切分间隔不变，主日志文件、RouteLevels与审计日志的文件均生效
+//jitter<=0时关闭，切分检查随日志切分循环进行，检查间隔默认30秒，有小于5分钟的切分间隔时为间隔的1/10（最短1秒），小于检查间隔的jitter没有意义
+//exp: gclog.SetRotationJitter(10 * time.Minute)
+func SetRotationJitter(jitter time.Duration) {
+	var delay int64
+	if jitter > 0 {
+		if n, err := rand.Int(rand.Reader, big.NewInt(int64(jitter))); err == nil {
+			delay = n.Int64()
+		}
+	}
+	atomic.StoreInt64(&rotationDelayNanos, delay)
+}
+
+//rotationDelay 返回本进程的切分延迟
+func rotationDelay() time.Duration {
+	return time.Duration(atomic.LoadInt64(&rotationDelayNanos))
+}
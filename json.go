@@ -0,0 +1,153 @@
+package gclog
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+//JSONEncoder 每条日志输出为一行json，便于日志平台采集
+//exp: {"time":"2018-04-08T16:00:00.123+08:00","level":"INFO","caller":"main.go:12","msg":"message","k":"v"}
+type JSONEncoder struct{}
+
+//Encode 实现Encoder
+func (j *JSONEncoder) Encode(buf []byte, e *Entry) []byte {
+	buf = append(buf, `{"time":`...)
+	if timeLayout == TimeEpochMillis {
+		buf = appendTime(buf, e.Time, time.RFC3339Nano)
+	} else {
+		buf = append(buf, '"')
+		buf = appendTime(buf, e.Time, time.RFC3339Nano)
+		buf = append(buf, '"')
+	}
+	buf = append(buf, `,"level":"`...)
+	buf = append(buf, LevelName(e.Level)...)
+	buf = append(buf, '"')
+	if e.File != "" {
+		buf = append(buf, `,"caller":`...)
+		buf = appendJSONString(buf, formatCaller(e.File)+":"+strconv.Itoa(e.Line))
+	}
+	buf = append(buf, `,"msg":`...)
+	buf = appendJSONString(buf, e.Message)
+	if e.Seq != 0 {
+		buf = append(buf, `,"seq":`...)
+		buf = strconv.AppendUint(buf, e.Seq, 10)
+	}
+	if e.ID != "" {
+		buf = append(buf, `,"id":"`...)
+		buf = append(buf, e.ID...)
+		buf = append(buf, '"')
+	}
+	if e.Logger != "" {
+		buf = append(buf, `,"logger":`...)
+		buf = appendJSONString(buf, e.Logger)
+	}
+	for _, f := range e.Fields {
+		buf = append(buf, ',')
+		buf = appendJSONString(buf, f.Key)
+		buf = append(buf, ':')
+		buf = appendJSONValue(buf, f.Value)
+	}
+	if e.Stack != "" {
+		buf = append(buf, `,"stack":`...)
+		buf = appendJSONString(buf, e.Stack)
+	}
+	return append(buf, "}\n"...)
+}
+
+//appendJSONValue 追加json格式的字段值
+func appendJSONValue(buf []byte, value interface{}) []byte {
+	switch v := value.(type) {
+	case nil:
+		return append(buf, "null"...)
+	case string:
+		return appendJSONString(buf, v)
+	case bool:
+		return strconv.AppendBool(buf, v)
+	case int:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int8:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int16:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int32:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(buf, v, 10)
+	case uint:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint8:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint16:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint32:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint64:
+		return strconv.AppendUint(buf, v, 10)
+	case float32:
+		return appendJSONFloat(buf, float64(v), 32)
+	case float64:
+		return appendJSONFloat(buf, v, 64)
+	case time.Time:
+		buf = append(buf, '"')
+		buf = v.AppendFormat(buf, time.RFC3339Nano)
+		return append(buf, '"')
+	case time.Duration:
+		return appendJSONString(buf, v.String())
+	case error:
+		return appendJSONString(buf, v.Error())
+	case fmt.Stringer:
+		return appendJSONString(buf, v.String())
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return appendJSONString(buf, fmt.Sprint(value))
+	}
+	return append(buf, data...)
+}
+
+//appendJSONFloat 追加json格式的浮点数，NaN与Inf以字符串表示
+func appendJSONFloat(buf []byte, f float64, bitSize int) []byte {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return appendJSONString(buf, strconv.FormatFloat(f, 'g', -1, bitSize))
+	}
+	return strconv.AppendFloat(buf, f, 'g', -1, bitSize)
+}
+
+//appendJSONString 追加转义后的json字符串
+func appendJSONString(buf []byte, s string) []byte {
+	const hexDigits = "0123456789abcdef"
+	buf = append(buf, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				buf = append(buf, '\\', c)
+			case c == '\n':
+				buf = append(buf, '\\', 'n')
+			case c == '\r':
+				buf = append(buf, '\\', 'r')
+			case c == '\t':
+				buf = append(buf, '\\', 't')
+			case c < 0x20:
+				buf = append(buf, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			default:
+				buf = append(buf, c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, `\ufffd`...)
+		} else {
+			buf = append(buf, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(buf, '"')
+}
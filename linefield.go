@@ -0,0 +1,48 @@
+package gclog
+
+import "bytes"
+
+//lineFieldForms 字段在文本（ key=value）与JSON（,"key":"value"）编码中的前缀
+func lineFieldForms(key string) [2][]byte {
+	return [2][]byte{[]byte(" " + key + "="), []byte(`,"` + key + `":"`)}
+}
+
+//lineFieldEnd 返回字段值的结束位置，值中不包含空格、引号、逗号、右括号
+func lineFieldEnd(rest []byte) int {
+	end := bytes.IndexAny(rest, " \\\",}\n")
+	if end < 0 {
+		return len(rest)
+	}
+	return end
+}
+
+//lineFieldValue 取编码后的日志中最后一个key字段的值，只用于链式哈希、签名等不含特殊字符的值
+func lineFieldValue(line []byte, key string) (string, bool) {
+	for _, form := range lineFieldForms(key) {
+		start := bytes.LastIndex(line, form)
+		if start < 0 {
+			continue
+		}
+		rest := line[start+len(form):]
+		return string(rest[:lineFieldEnd(rest)]), true
+	}
+	return "", false
+}
+
+//removeLineField 去掉编码后的日志中最后一个key字段，还原添加该字段之前的编码结果
+func removeLineField(line []byte, key string) []byte {
+	for i, form := range lineFieldForms(key) {
+		start := bytes.LastIndex(line, form)
+		if start < 0 {
+			continue
+		}
+		end := start + len(form) + lineFieldEnd(line[start+len(form):])
+		if i == 1 && end < len(line) {
+			end++ //JSON的结束引号
+		}
+		out := make([]byte, 0, len(line))
+		out = append(out, line[:start]...)
+		return append(out, line[end:]...)
+	}
+	return line
+}
@@ -0,0 +1,135 @@
+package gclog
+
+import "sync/atomic"
+
+//LoggerField logger名称输出的字段名
+const LoggerField = "logger"
+
+//callerSkip 包级日志接口额外跳过的调用层数，原子读写
+var callerSkip int32
+
+//AddCallerSkip 包级日志接口（gclog.Info等）取调用者位置时额外跳过n层，可以为负数
+//用于业务对gclog做了一层封装时，输出封装函数调用者的位置
+//exp: func myInfo(msg string) { gclog.Info(msg) } 使用前调用 gclog.AddCallerSkip(1)
+func AddCallerSkip(n int) {
+	atomic.AddInt32(&callerSkip, int32(n))
+}
+
+//Logger 带名称和固定字段的日志对象，共享包级的日志级别与输出
+type Logger struct {
+	name         string        //logger名称，子logger以.连接
+	fields       []Field       //每条日志附带的字段
+	callerSkip   int           //取调用者位置时额外跳过的层数
+	noCaller     bool          //不取调用者位置
+	transformers []Transformer //该logger的变换函数
+}
+
+//NewLogger 创建一个带名称的logger
+//exp:
+//
+//	var dbLog = gclog.NewLogger("db")
+//	dbLog.Info("connect %s", addr)
+func NewLogger(name string) *Logger {
+	return &Logger{name: name}
+}
+
+//clone 复制logger，子logger与父logger互不影响
+func (l *Logger) clone() *Logger {
+	c := *l
+	c.fields = append([]Field(nil), l.fields...)
+	return &c
+}
+
+//Name 返回logger的名称
+func (l *Logger) Name() string {
+	return l.name
+}
+
+//Named 创建子logger，名称为"父名称.name"
+func (l *Logger) Named(name string) *Logger {
+	c := l.clone()
+	if c.name == "" {
+		c.name = name
+	} else if name != "" {
+		c.name += "." + name
+	}
+	return c
+}
+
+//With 创建附带额外字段的子logger
+func (l *Logger) With(fields ...Field) *Logger {
+	c := l.clone()
+	c.fields = append(c.fields, fields...)
+	return c
+}
+
+//AddCallerSkip 创建取调用者位置时额外跳过n层的子logger，用于对logger再做封装的场景
+func (l *Logger) AddCallerSkip(n int) *Logger {
+	c := l.clone()
+	c.callerSkip += n
+	return c
+}
+
+//WithCaller 创建开启或关闭调用者位置的子logger，关闭后该logger的日志不再调用runtime.Caller
+func (l *Logger) WithCaller(enabled bool) *Logger {
+	c := l.clone()
+	c.noCaller = !enabled
+	return c
+}
+
+//log 输出日志，需要由logger的日志接口直接调用
+func (l *Logger) log(level int, msg string) {
+	//业务代码->Logger.Info->log->logEntry
+	e := getEntry()
+	e.Level, e.Message, e.Fields, e.Logger = level, msg, l.fields, l.name
+	logEntry(e, 2+l.callerSkip, !l.noCaller && callerEnabled(level), false, l.transformers)
+	putEntry(e)
+}
+
+//Verb 输出verb日志
+func (l *Logger) Verb(msg string, v ...interface{}) {
+	if levelEnabled(VerbLevel) {
+		l.log(VerbLevel, sprintf(msg, v...))
+	}
+}
+
+//Debug 输出debug日志
+func (l *Logger) Debug(msg string, v ...interface{}) {
+	if levelEnabled(DebugLevel) {
+		l.log(DebugLevel, sprintf(msg, v...))
+	}
+}
+
+//Info 输出info日志
+func (l *Logger) Info(msg string, v ...interface{}) {
+	if levelEnabled(InfoLevel) {
+		l.log(InfoLevel, sprintf(msg, v...))
+	}
+}
+
+//Notice 输出notice日志
+func (l *Logger) Notice(msg string, v ...interface{}) {
+	if levelEnabled(NoticeLevel) {
+		l.log(NoticeLevel, sprintf(msg, v...))
+	}
+}
+
+//Warning 输出warning日志
+func (l *Logger) Warning(msg string, v ...interface{}) {
+	if levelEnabled(WarningLevel) {
+		l.log(WarningLevel, sprintf(msg, v...))
+	}
+}
+
+//Error 输出error日志
+func (l *Logger) Error(msg string, v ...interface{}) {
+	if levelEnabled(ErrorLevel) {
+		l.log(ErrorLevel, sprintf(msg, v...))
+	}
+}
+
+//Fatal 输出fatal日志，执行退出钩子后以状态码1退出进程
+func (l *Logger) Fatal(msg string, v ...interface{}) {
+	l.log(FatalLevel, sprintf(msg, v...))
+	exit(1)
+}
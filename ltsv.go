@@ -0,0 +1,87 @@
+package gclog
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+//LTSVEncoder Labeled Tab-separated Values编码器，每条日志一行，各项为“标签:值”并以tab分隔
+//值中的tab、换行与反斜杠转义为\t、\n、\\，标签中只保留字母、数字与_.-
+//exp: time:2018-04-08T16:00:00.123+08:00	level:INFO	caller:main.go:12	msg:message	k:v
+type LTSVEncoder struct{}
+
+//Encode 实现Encoder
+func (l *LTSVEncoder) Encode(buf []byte, e *Entry) []byte {
+	buf = append(buf, "time:"...)
+	buf = appendTime(buf, e.Time, time.RFC3339Nano)
+	buf = append(buf, "\tlevel:"...)
+	buf = append(buf, LevelName(e.Level)...)
+	if e.File != "" {
+		buf = append(buf, "\tcaller:"...)
+		buf = appendLTSVValue(buf, formatCaller(e.File)+":"+strconv.Itoa(e.Line))
+	}
+	buf = append(buf, "\tmsg:"...)
+	buf = appendLTSVValue(buf, e.Message)
+	if e.Seq != 0 {
+		buf = append(buf, "\tseq:"...)
+		buf = strconv.AppendUint(buf, e.Seq, 10)
+	}
+	if e.ID != "" {
+		buf = append(buf, "\tid:"...)
+		buf = append(buf, e.ID...)
+	}
+	if e.Logger != "" {
+		buf = append(buf, "\tlogger:"...)
+		buf = appendLTSVValue(buf, e.Logger)
+	}
+	for _, f := range e.Fields {
+		label := ltsvLabel(f.Key)
+		if label == "" {
+			continue
+		}
+		buf = append(buf, '\t')
+		buf = append(buf, label...)
+		buf = append(buf, ':')
+		if s, ok := f.Value.(string); ok {
+			buf = appendLTSVValue(buf, s)
+		} else {
+			buf = appendLTSVValue(buf, fmt.Sprint(f.Value))
+		}
+	}
+	if e.Stack != "" {
+		buf = append(buf, "\tstack:"...)
+		buf = appendLTSVValue(buf, e.Stack)
+	}
+	return append(buf, '\n')
+}
+
+//appendLTSVValue 追加值，转义tab、换行与反斜杠
+func appendLTSVValue(buf []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\t':
+			buf = append(buf, '\\', 't')
+		case '\n':
+			buf = append(buf, '\\', 'n')
+		case '\r':
+			buf = append(buf, '\\', 'r')
+		case '\\':
+			buf = append(buf, '\\', '\\')
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return buf
+}
+
+//ltsvLabel LTSV的标签只能包含字母、数字与_.-，去掉其他字符
+func ltsvLabel(key string) string {
+	b := make([]byte, 0, len(key))
+	for i := 0; i < len(key); i++ {
+		if c := key[i]; isAlnum(c) || c == '_' || c == '.' || c == '-' {
+			b = append(b, c)
+		}
+	}
+	return string(b)
+}
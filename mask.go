@@ -0,0 +1,142 @@
+package gclog
+
+import (
+	"regexp"
+	"sync"
+	"sync/atomic"
+)
+
+//MaskRule 脱敏规则，日志消息中匹配Pattern的部分替换为Replace（支持$1等分组引用）
+//Func不为nil时使用Func计算替换结果，Replace被忽略
+type MaskRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	Replace string
+	Func    func(match string) string
+}
+
+//内置的脱敏规则
+var (
+	//MaskEmail 邮箱，保留首字符与域名，exp: t***@example.com
+	MaskEmail = MaskRule{
+		Name:    "email",
+		Pattern: regexp.MustCompile(`\b([A-Za-z0-9])[A-Za-z0-9._%+-]*@([A-Za-z0-9.-]+\.[A-Za-z]{2,})\b`),
+		Replace: "$1***@$2",
+	}
+	//MaskPhone 中国大陆手机号，保留前3后4位，exp: 138****5678
+	MaskPhone = MaskRule{
+		Name:    "phone",
+		Pattern: regexp.MustCompile(`\b(1[3-9]\d)\d{4}(\d{4})\b`),
+		Replace: "$1****$2",
+	}
+	//MaskIDCard 中国大陆18位身份证号，保留前6后4位
+	MaskIDCard = MaskRule{
+		Name:    "id_card",
+		Pattern: regexp.MustCompile(`\b(\d{6})\d{8}(\d{3}[\dXx])\b`),
+		Replace: "$1********$2",
+	}
+	//MaskCardPAN 银行卡号，13到19位数字（允许空格、-分隔），通过Luhn校验才脱敏，保留后4位
+	MaskCardPAN = MaskRule{
+		Name:    "card_pan",
+		Pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		Func:    maskCardPAN,
+	}
+	//DefaultMaskRules 内置的全部脱敏规则
+	DefaultMaskRules = []MaskRule{MaskEmail, MaskPhone, MaskIDCard, MaskCardPAN}
+)
+
+var (
+	maskLock  = new(sync.Mutex) //修改规则时加锁
+	maskRules atomic.Value      //当前的脱敏规则，[]MaskRule，修改时整体替换，写日志时无锁读取
+)
+
+//AddMaskRules 添加脱敏规则，规则按添加顺序作用于每条日志的消息，在编码与投递给任何sink之前执行
+//exp:
+//
+//	gclog.AddMaskRules(gclog.DefaultMaskRules...)
+//	gclog.AddMaskRules(gclog.MaskRule{Name: "order", Pattern: regexp.MustCompile(`order=\d+`), Replace: "order=***"})
+func AddMaskRules(rules ...MaskRule) {
+	maskLock.Lock()
+	defer maskLock.Unlock()
+	old := loadMaskRules()
+	all := make([]MaskRule, 0, len(old)+len(rules))
+	all = append(all, old...)
+	for _, r := range rules {
+		if r.Pattern != nil {
+			all = append(all, r)
+		}
+	}
+	maskRules.Store(all)
+}
+
+//AddMaskRule 使用正则表达式字符串添加脱敏规则
+//exp: gclog.AddMaskRule("token", `token=\w+`, "token=***")
+func AddMaskRule(name, pattern, replace string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	AddMaskRules(MaskRule{Name: name, Pattern: re, Replace: replace})
+	return nil
+}
+
+//ClearMaskRules 清除所有脱敏规则
+func ClearMaskRules() {
+	maskLock.Lock()
+	defer maskLock.Unlock()
+	maskRules.Store([]MaskRule(nil))
+}
+
+//loadMaskRules 取当前的脱敏规则，返回的切片不能修改
+func loadMaskRules() []MaskRule {
+	rules, _ := maskRules.Load().([]MaskRule)
+	return rules
+}
+
+//maskMessage 按脱敏规则处理消息，没有规则时直接返回
+func maskMessage(msg string) string {
+	for _, r := range loadMaskRules() {
+		if r.Func != nil {
+			msg = r.Pattern.ReplaceAllStringFunc(msg, r.Func)
+		} else {
+			msg = r.Pattern.ReplaceAllString(msg, r.Replace)
+		}
+	}
+	return msg
+}
+
+//maskCardPAN 通过Luhn校验的卡号只保留后4位
+func maskCardPAN(match string) string {
+	digits := make([]byte, 0, len(match))
+	for i := 0; i < len(match); i++ {
+		if match[i] >= '0' && match[i] <= '9' {
+			digits = append(digits, match[i])
+		}
+	}
+	if !luhnValid(digits) {
+		return match
+	}
+	masked := make([]byte, 0, len(digits))
+	for i := 0; i < len(digits)-4; i++ {
+		masked = append(masked, '*')
+	}
+	return string(append(masked, digits[len(digits)-4:]...))
+}
+
+//luhnValid Luhn校验
+func luhnValid(digits []byte) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
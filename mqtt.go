@@ -0,0 +1,486 @@
+package gclog
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//mqttMaxBatch 一次发送的最多消息数
+const mqttMaxBatch = 64
+
+//MQTT控制报文类型
+const (
+	mqttConnect    = 0x10
+	mqttConnack    = 0x20
+	mqttPublish    = 0x30
+	mqttPuback     = 0x40
+	mqttPubrec     = 0x50
+	mqttPubrel     = 0x62
+	mqttPubcomp    = 0x70
+	mqttPingreq    = 0xc0
+	mqttDisconnect = 0xe0
+)
+
+var errMQTTProtocol = errors.New("gclog: unexpected mqtt packet")
+
+//MQTTOptions MQTT sink的设置
+type MQTTOptions struct {
+	TLS      *TLSOptions //不为nil时使用TLS连接
+	ClientID string      //为空时随机生成
+	Username string
+	Password string
+	//QoS 发布日志的QoS，0为最多一次；1、2时等待broker确认，未确认的批次重连后重发一次，均为至少一次
+	//连接使用CleanSession，重发的日志是新的消息，broker可能收到重复的日志，QoS 2只保证同一连接内不重复
+	QoS    byte
+	Retain bool //为true时broker保留最后一条日志
+	//WillTopic 遗嘱消息的topic，不为空时连接异常断开后broker发布WillMessage，用于标记设备离线
+	WillTopic   string
+	WillMessage string
+	WillQoS     byte
+	WillRetain  bool
+	//KeepAlive 心跳间隔，<=0时默认60s
+	KeepAlive    time.Duration
+	QueueSize    int           //等待发送的最多日志数，超出时丢弃日志，<=0时默认1024
+	DialTimeout  time.Duration //连接超时，<=0时默认10s
+	WriteTimeout time.Duration //写入超时，<=0时默认10s
+	AckTimeout   time.Duration //QoS 1、2确认的超时，<=0时默认10s
+}
+
+//MQTTSink 将日志发布到MQTT topic的sink，边缘设备上的程序可以将日志上报到已有的IoT broker
+//使用MQTT 3.1.1协议，不依赖第三方库，日志先进入有界的等待队列，由后台goroutine发送，断线后自动重连
+//Close时发送DISCONNECT，正常退出不会触发遗嘱消息
+type MQTTSink struct {
+	addr      string
+	topic     string
+	opts      MQTTOptions
+	tlsConfig *tls.Config
+	queue     chan *buffer
+	done      chan struct{}
+	closeOnce sync.Once
+	conn      *mqttConn //只在发送goroutine中使用
+	redial    redial    //重连间隔，只在发送goroutine中使用
+	dropped   uint64    //丢弃的日志数，原子读写
+	sent      uint64    //发送成功的日志数，原子读写
+}
+
+//mqttConn 一个MQTT连接，读goroutine接收确认并回复PUBREL
+type mqttConn struct {
+	conn     net.Conn
+	r        *bufio.Reader
+	wlock    *sync.Mutex   //写入时加锁，读goroutine也会写入PUBREL
+	acks     chan bool     //QoS 1的PUBACK与QoS 2的PUBCOMP
+	closed   chan struct{} //读goroutine退出时关闭
+	packetID uint16        //上一个报文标识符，只在发送goroutine中使用
+	lastSend time.Time     //上一次发送的时间，只在发送goroutine中使用
+}
+
+//NewMQTTSink 创建发布到topic的MQTT sink，addr为host:port
+//exp: s, err := gclog.NewMQTTSink("broker.local:1883", "devices/42/logs", gclog.MQTTOptions{QoS: 1, WillTopic: "devices/42/status", WillMessage: "offline"})
+func NewMQTTSink(addr, topic string, opts MQTTOptions) (*MQTTSink, error) {
+	if topic == "" || strings.ContainsAny(topic, "+#") {
+		return nil, fmt.Errorf("gclog: invalid mqtt topic %q", topic)
+	}
+	if opts.QoS > 2 || opts.WillQoS > 2 {
+		return nil, errors.New("gclog: mqtt qos must be 0, 1 or 2")
+	}
+	if opts.ClientID == "" {
+		id := make([]byte, 8)
+		rand.Read(id)
+		opts.ClientID = "gclog-" + hex.EncodeToString(id)
+	}
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = 60 * time.Second
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 10 * time.Second
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = 10 * time.Second
+	}
+	if opts.AckTimeout <= 0 {
+		opts.AckTimeout = 10 * time.Second
+	}
+	s := &MQTTSink{
+		addr:  addr,
+		topic: topic,
+		opts:  opts,
+		queue: make(chan *buffer, opts.QueueSize),
+		done:  make(chan struct{}),
+	}
+	if opts.TLS != nil {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		if s.tlsConfig, err = opts.TLS.config(host); err != nil {
+			return nil, err
+		}
+	}
+	go s.run()
+	return s, nil
+}
+
+//SendToMQTT 创建MQTT sink并注册，同一地址与topic重复调用时替换之前的sink
+//exp: gclog.SendToMQTT("127.0.0.1:1883", "logs/app", gclog.MQTTOptions{})
+func SendToMQTT(addr, topic string, opts MQTTOptions) (*MQTTSink, error) {
+	s, err := NewMQTTSink(addr, topic, opts)
+	if err != nil {
+		return nil, err
+	}
+	if old := addSink("mqtt:"+addr+"/"+topic, s); old != nil {
+		closeSink(old)
+	}
+	return s, nil
+}
+
+//Write 实现Sink，复制日志后放入等待队列，队列满时丢弃并返回错误
+func (s *MQTTSink) Write(e *Entry, line []byte) error {
+	buf := getBuffer()
+	buf.b = append(buf.b, line...)
+	select {
+	case s.queue <- buf:
+		return nil
+	default:
+		putBuffer(buf)
+		atomic.AddUint64(&s.dropped, 1)
+		return errSinkFull
+	}
+}
+
+//Close 实现Sink，发送队列中剩余的日志后断开连接
+func (s *MQTTSink) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.queue)
+	})
+	<-s.done
+	return nil
+}
+
+//Dropped 返回因队列满、发送失败或确认超时丢弃的日志数
+func (s *MQTTSink) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+//Sent 返回发送成功（QoS 1、2时为已确认）的日志数
+func (s *MQTTSink) Sent() uint64 {
+	return atomic.LoadUint64(&s.sent)
+}
+
+//run 发送goroutine，阻塞等待第一条日志后合并队列中已有的日志一起发送，空闲时发送心跳
+func (s *MQTTSink) run() {
+	defer close(s.done)
+	batch := make([]*buffer, 0, mqttMaxBatch)
+	ticker := time.NewTicker(s.opts.KeepAlive / 2)
+	defer ticker.Stop()
+	for {
+		var buf *buffer
+		var ok bool
+		select {
+		case buf, ok = <-s.queue:
+		case <-ticker.C:
+			s.ping()
+			continue
+		}
+		if !ok {
+			break
+		}
+		batch = append(batch[:0], buf)
+	drain:
+		for len(batch) < mqttMaxBatch {
+			select {
+			case buf, ok := <-s.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, buf)
+			default:
+				break drain
+			}
+		}
+		sent := s.publish(batch)
+		atomic.AddUint64(&s.sent, uint64(sent))
+		atomic.AddUint64(&s.dropped, uint64(len(batch)-sent))
+		for _, buf := range batch {
+			putBuffer(buf)
+		}
+	}
+	if s.conn != nil {
+		s.conn.write([]byte{mqttDisconnect, 0}, time.Second)
+		s.conn.close()
+	}
+}
+
+//ping 距上次发送超过心跳间隔的一半时发送PINGREQ，避免broker断开空闲连接
+func (s *MQTTSink) ping() {
+	if s.conn == nil || time.Since(s.conn.lastSend) < s.opts.KeepAlive/2 {
+		return
+	}
+	if err := s.conn.write([]byte{mqttPingreq, 0}, s.opts.WriteTimeout); err != nil {
+		s.drop()
+		return
+	}
+	s.conn.lastSend = time.Now()
+}
+
+//publish 发送一批日志，返回发送成功的条数
+//QoS 1、2时等待全部确认，连接断开或确认超时时重连后以新的报文标识符重发一次，已到达broker的日志会重复
+func (s *MQTTSink) publish(batch []*buffer) int {
+	var out []byte
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil && !s.dial() {
+			return 0
+		}
+		c := s.conn
+		out = out[:0]
+		for _, buf := range batch {
+			out = s.appendPublish(out, c, buf.b)
+		}
+		if err := c.write(out, s.opts.WriteTimeout); err != nil {
+			s.drop()
+			continue
+		}
+		c.lastSend = time.Now()
+		if s.opts.QoS == 0 {
+			return len(batch)
+		}
+		if acked, ok := c.waitAcks(len(batch), s.opts.AckTimeout); ok {
+			return acked
+		}
+		s.drop()
+	}
+	return 0
+}
+
+//appendPublish 追加一个PUBLISH报文，QoS 1、2时分配报文标识符
+func (s *MQTTSink) appendPublish(dst []byte, c *mqttConn, payload []byte) []byte {
+	header := byte(mqttPublish) | s.opts.QoS<<1
+	if s.opts.Retain {
+		header |= 1
+	}
+	size := 2 + len(s.topic) + len(payload)
+	if s.opts.QoS > 0 {
+		size += 2
+	}
+	dst = append(dst, header)
+	dst = appendMQTTLength(dst, size)
+	dst = appendMQTTString(dst, s.topic)
+	if s.opts.QoS > 0 {
+		c.packetID++
+		if c.packetID == 0 {
+			c.packetID = 1
+		}
+		dst = append(dst, byte(c.packetID>>8), byte(c.packetID))
+	}
+	return append(dst, payload...)
+}
+
+//drop 关闭出错的连接，下次发送时重连
+func (s *MQTTSink) drop() {
+	s.conn.close()
+	s.conn = nil
+}
+
+//dial 建立连接并完成CONNECT，连接失败后在重连间隔内直接返回失败
+func (s *MQTTSink) dial() bool {
+	if !s.redial.allow() {
+		return false
+	}
+	c, err := s.connect()
+	if err != nil {
+		s.redial.failed()
+		return false
+	}
+	s.conn = c
+	s.redial.reset()
+	go c.readLoop()
+	return true
+}
+
+//connect 连接broker，发送CONNECT并等待CONNACK
+func (s *MQTTSink) connect() (*mqttConn, error) {
+	dialer := &net.Dialer{Timeout: s.opts.DialTimeout}
+	var conn net.Conn
+	var err error
+	if s.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.addr, s.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", s.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(s.opts.DialTimeout))
+	//可变头：协议名、协议级别4、连接标志、保持连接
+	flags := byte(0x02)
+	body := appendMQTTString(nil, "MQTT")
+	body = append(body, 4, 0)
+	keepAlive := int(s.opts.KeepAlive / time.Second)
+	if keepAlive > 0xffff {
+		keepAlive = 0xffff
+	}
+	body = append(body, byte(keepAlive>>8), byte(keepAlive))
+	body = appendMQTTString(body, s.opts.ClientID)
+	if s.opts.WillTopic != "" {
+		flags |= 0x04 | s.opts.WillQoS<<3
+		if s.opts.WillRetain {
+			flags |= 0x20
+		}
+		body = appendMQTTString(body, s.opts.WillTopic)
+		body = appendMQTTString(body, s.opts.WillMessage)
+	}
+	if s.opts.Username != "" {
+		flags |= 0x80
+		body = appendMQTTString(body, s.opts.Username)
+		if s.opts.Password != "" {
+			flags |= 0x40
+			body = appendMQTTString(body, s.opts.Password)
+		}
+	}
+	body[7] = flags
+	packet := appendMQTTLength([]byte{mqttConnect}, len(body))
+	if _, err := conn.Write(append(packet, body...)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	typ, payload, err := readMQTTPacket(r)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if typ&0xf0 != mqttConnack || len(payload) != 2 {
+		conn.Close()
+		return nil, errMQTTProtocol
+	}
+	if payload[1] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("gclog: mqtt connection refused, return code %d", payload[1])
+	}
+	conn.SetDeadline(time.Time{})
+	return &mqttConn{
+		conn:     conn,
+		r:        r,
+		wlock:    new(sync.Mutex),
+		acks:     make(chan bool, mqttMaxBatch),
+		closed:   make(chan struct{}),
+		lastSend: time.Now(),
+	}, nil
+}
+
+//readLoop 读goroutine，QoS 2时收到PUBREC回复PUBREL，将PUBACK、PUBCOMP放入acks，连接出错时退出
+func (c *mqttConn) readLoop() {
+	defer close(c.closed)
+	for {
+		typ, payload, err := readMQTTPacket(c.r)
+		if err != nil {
+			return
+		}
+		switch typ & 0xf0 {
+		case mqttPuback, mqttPubcomp:
+			select {
+			case c.acks <- true:
+			default:
+			}
+		case mqttPubrec:
+			if len(payload) != 2 {
+				c.conn.Close()
+				return
+			}
+			if err := c.write([]byte{mqttPubrel, 2, payload[0], payload[1]}, 5*time.Second); err != nil {
+				return
+			}
+		}
+	}
+}
+
+//write 写入报文，与读goroutine的PUBREL互斥
+func (c *mqttConn) write(p []byte, timeout time.Duration) error {
+	c.wlock.Lock()
+	defer c.wlock.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(timeout))
+	_, err := c.conn.Write(p)
+	return err
+}
+
+//waitAcks 等待n个确认，返回确认的条数；超时或连接断开时第二个返回值为false
+func (c *mqttConn) waitAcks(n int, timeout time.Duration) (int, bool) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for i := 0; i < n; i++ {
+		select {
+		case <-c.acks:
+		case <-c.closed:
+			return 0, false
+		case <-timer.C:
+			return 0, false
+		}
+	}
+	return n, true
+}
+
+//close 关闭连接并等待读goroutine退出
+func (c *mqttConn) close() {
+	c.conn.Close()
+	<-c.closed
+}
+
+//appendMQTTLength 追加剩余长度（变长编码）
+func appendMQTTLength(dst []byte, n int) []byte {
+	for {
+		b := byte(n & 0x7f)
+		n >>= 7
+		if n > 0 {
+			b |= 0x80
+		}
+		dst = append(dst, b)
+		if n == 0 {
+			return dst
+		}
+	}
+}
+
+//appendMQTTString 追加长度前缀的UTF-8字符串
+func appendMQTTString(dst []byte, s string) []byte {
+	dst = append(dst, byte(len(s)>>8), byte(len(s)))
+	return append(dst, s...)
+}
+
+//readMQTTPacket 读取一个报文，返回首字节与剩余部分
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	typ, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	size := 0
+	for shift := uint(0); ; shift += 7 {
+		if shift > 21 {
+			return 0, nil, errMQTTProtocol
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return typ, payload, nil
+}
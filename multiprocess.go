@@ -0,0 +1,95 @@
+package gclog
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+	"syscall"
+)
+
+//multiProcess 是否开启多进程共享日志文件，原子读写
+var multiProcess int32
+
+//SetMultiProcess 设置是否有多个进程（如prefork的worker）写同一个日志文件，默认关闭，需要在InitLogFile、RouteLevels之前调用
+//开启后每次写入持有文件的flock排他锁，日志不会交错；切分时持有“日志文件名.lock”的排他锁，
+//日志文件已被其他进程切分时只打开新文件，不会重复改名；其他进程最晚在下一个切分检查周期（默认30秒）切换到新文件
+//exp:
+//
+//	gclog.SetMultiProcess(true)
+//	gclog.InitLogFile("/var/log/app/app.log")
+func SetMultiProcess(enable bool) {
+	if enable {
+		atomic.StoreInt32(&multiProcess, 1)
+	} else {
+		atomic.StoreInt32(&multiProcess, 0)
+	}
+}
+
+//multiProcessEnabled 判断是否开启多进程共享日志文件
+func multiProcessEnabled() bool {
+	return atomic.LoadInt32(&multiProcess) == 1
+}
+
+//lockedWriter 写入前对文件加flock排他锁，与其他进程的写入互斥
+type lockedWriter struct {
+	file *os.File
+}
+
+//Write 实现io.Writer，文件以追加方式打开，加锁后一次写入
+func (w lockedWriter) Write(p []byte) (int, error) {
+	fd := int(w.file.Fd())
+	if err := syscall.Flock(fd, syscall.LOCK_EX); err != nil {
+		return 0, err
+	}
+	defer syscall.Flock(fd, syscall.LOCK_UN)
+	return w.file.Write(p)
+}
+
+//fileWriter 返回日志文件的直接写入目标，开启多进程时每次写入加锁
+func fileWriter(file *os.File) io.Writer {
+	if multiProcessEnabled() {
+		return lockedWriter{file: file}
+	}
+	return file
+}
+
+//lockFile 对文件加flock排他锁，返回解锁函数
+func lockFile(file *os.File) (func(), error) {
+	fd := int(file.Fd())
+	if err := syscall.Flock(fd, syscall.LOCK_EX); err != nil {
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(fd, syscall.LOCK_UN)
+	}, nil
+}
+
+//lockRotation 持有filename.lock的排他锁，多个进程中同一时刻只有一个切分filename，返回解锁函数
+func lockRotation(filename string) (func(), error) {
+	f, err := createFile(filename+".lock", os.O_RDWR)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := lockFile(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		unlock()
+		f.Close()
+	}, nil
+}
+
+//isCurrentFile 判断file是否仍为filename指向的文件，已被其他进程改名时返回false
+func isCurrentFile(file *os.File, filename string) bool {
+	opened, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(filename)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(opened, current)
+}
@@ -0,0 +1,382 @@
+package gclog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//natsMaxBatch 一次发送的最多消息数
+const natsMaxBatch = 256
+
+var errNATSProtocol = errors.New("gclog: unexpected nats protocol line")
+
+//NATSOptions NATS sink的设置
+type NATSOptions struct {
+	TLS      *TLSOptions //不为nil时使用TLS连接
+	Token    string      //token认证
+	User     string      //用户名密码认证
+	Password string
+	//JetStream 为true时使用JetStream发布，等待服务端确认消息已持久化，未确认的批次重连后重发一次
+	JetStream bool
+	//MaxPending 等待发送的最多日志数，超出时丢弃日志，<=0时默认4096
+	MaxPending   int
+	DialTimeout  time.Duration //连接超时，<=0时默认5s
+	WriteTimeout time.Duration //写入超时，<=0时默认5s
+	AckTimeout   time.Duration //JetStream确认的超时，<=0时默认5s
+}
+
+//NATSSink 将日志发布到NATS subject的sink，消息内容为编码后的一条日志
+//使用NATS的文本协议，不依赖第三方库，日志先进入有界的等待队列，由后台goroutine发送，断线后自动重连
+type NATSSink struct {
+	addr      string
+	subject   string
+	opts      NATSOptions
+	tlsConfig *tls.Config
+	inbox     string //JetStream确认消息的subject前缀
+	queue     chan *buffer
+	done      chan struct{}
+	closeOnce sync.Once
+	conn      *natsConn //只在发送goroutine中使用
+	redial    redial    //重连间隔，只在发送goroutine中使用
+	dropped   uint64    //丢弃的日志数，原子读写
+	sent      uint64    //发送成功的日志数，原子读写
+}
+
+//natsConn 一个NATS连接，读goroutine回复服务端的PING并接收JetStream确认
+type natsConn struct {
+	conn   net.Conn
+	r      *bufio.Reader //握手时已使用的读缓冲，读goroutine继续使用
+	wlock  *sync.Mutex   //写入时加锁，读goroutine也会写入PONG
+	acks   chan bool     //JetStream确认，true为成功
+	closed chan struct{} //读goroutine退出时关闭
+}
+
+//NewNATSSink 创建发布到subject的NATS sink，addr为host:port
+//exp: s, err := gclog.NewNATSSink("nats.example.com:4222", "logs.app", gclog.NATSOptions{JetStream: true})
+func NewNATSSink(addr, subject string, opts NATSOptions) (*NATSSink, error) {
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n") {
+		return nil, fmt.Errorf("gclog: invalid nats subject %q", subject)
+	}
+	if opts.MaxPending <= 0 {
+		opts.MaxPending = 4096
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 5 * time.Second
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = 5 * time.Second
+	}
+	if opts.AckTimeout <= 0 {
+		opts.AckTimeout = 5 * time.Second
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	s := &NATSSink{
+		addr:    addr,
+		subject: subject,
+		opts:    opts,
+		inbox:   "_INBOX." + hex.EncodeToString(id),
+		queue:   make(chan *buffer, opts.MaxPending),
+		done:    make(chan struct{}),
+	}
+	if opts.TLS != nil {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		if s.tlsConfig, err = opts.TLS.config(host); err != nil {
+			return nil, err
+		}
+	}
+	go s.run()
+	return s, nil
+}
+
+//SendToNATS 创建NATS sink并注册，同一地址与subject重复调用时替换之前的sink
+//exp: gclog.SendToNATS("127.0.0.1:4222", "logs.app", gclog.NATSOptions{})
+func SendToNATS(addr, subject string, opts NATSOptions) (*NATSSink, error) {
+	s, err := NewNATSSink(addr, subject, opts)
+	if err != nil {
+		return nil, err
+	}
+	if old := addSink("nats:"+addr+"/"+subject, s); old != nil {
+		closeSink(old)
+	}
+	return s, nil
+}
+
+//Write 实现Sink，复制日志后放入等待队列，队列满时丢弃并返回错误
+func (s *NATSSink) Write(e *Entry, line []byte) error {
+	buf := getBuffer()
+	buf.b = append(buf.b, line...)
+	select {
+	case s.queue <- buf:
+		return nil
+	default:
+		putBuffer(buf)
+		atomic.AddUint64(&s.dropped, 1)
+		return errSinkFull
+	}
+}
+
+//Close 实现Sink，发送队列中剩余的日志后关闭连接
+func (s *NATSSink) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.queue)
+	})
+	<-s.done
+	return nil
+}
+
+//Dropped 返回因队列满、发送失败或JetStream拒绝丢弃的日志数
+func (s *NATSSink) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+//Sent 返回发送成功（JetStream时为已确认）的日志数
+func (s *NATSSink) Sent() uint64 {
+	return atomic.LoadUint64(&s.sent)
+}
+
+//run 发送goroutine，阻塞等待第一条日志后合并队列中已有的日志一起发送
+func (s *NATSSink) run() {
+	defer close(s.done)
+	batch := make([]*buffer, 0, natsMaxBatch)
+	var out []byte
+	for {
+		buf, ok := <-s.queue
+		if !ok {
+			break
+		}
+		batch = append(batch[:0], buf)
+	drain:
+		for len(batch) < natsMaxBatch {
+			select {
+			case buf, ok := <-s.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, buf)
+			default:
+				break drain
+			}
+		}
+		out = out[:0]
+		for i, buf := range batch {
+			out = s.appendPub(out, i, buf.b)
+			putBuffer(buf)
+		}
+		sent := s.publish(out, len(batch))
+		atomic.AddUint64(&s.sent, uint64(sent))
+		atomic.AddUint64(&s.dropped, uint64(len(batch)-sent))
+	}
+	if s.conn != nil {
+		s.conn.close()
+	}
+}
+
+//appendPub 追加一条PUB命令，JetStream时带上确认消息的subject
+func (s *NATSSink) appendPub(dst []byte, i int, payload []byte) []byte {
+	dst = append(dst, "PUB "...)
+	dst = append(dst, s.subject...)
+	if s.opts.JetStream {
+		dst = append(dst, ' ')
+		dst = append(dst, s.inbox...)
+		dst = append(dst, '.')
+		dst = strconv.AppendInt(dst, int64(i), 10)
+	}
+	dst = append(dst, ' ')
+	dst = strconv.AppendInt(dst, int64(len(payload)), 10)
+	dst = append(dst, "\r\n"...)
+	dst = append(dst, payload...)
+	return append(dst, "\r\n"...)
+}
+
+//publish 发送n条日志组成的批次，返回发送成功的条数
+//JetStream时等待全部确认，连接断开或确认超时时重连后重发一次，服务端拒绝的日志不重发
+func (s *NATSSink) publish(out []byte, n int) int {
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil && !s.dial() {
+			return 0
+		}
+		c := s.conn
+		if err := c.write(out, s.opts.WriteTimeout); err != nil {
+			s.drop()
+			continue
+		}
+		if !s.opts.JetStream {
+			return n
+		}
+		if acked, ok := c.waitAcks(n, s.opts.AckTimeout); ok {
+			return acked
+		}
+		s.drop()
+	}
+	return 0
+}
+
+//drop 关闭出错的连接，下次发送时重连
+func (s *NATSSink) drop() {
+	s.conn.close()
+	s.conn = nil
+}
+
+//dial 建立连接并完成握手，连接失败后在重连间隔内直接返回失败
+func (s *NATSSink) dial() bool {
+	if !s.redial.allow() {
+		return false
+	}
+	c, err := s.handshake()
+	if err != nil {
+		s.redial.failed()
+		return false
+	}
+	s.conn = c
+	s.redial.reset()
+	go c.readLoop(s.inbox)
+	return true
+}
+
+//handshake 连接服务端：读取INFO，需要时升级TLS，发送CONNECT与PING并等待PONG
+func (s *NATSSink) handshake() (*natsConn, error) {
+	conn, err := net.DialTimeout("tcp", s.addr, s.opts.DialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(s.opts.DialTimeout))
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return nil, errNATSProtocol
+	}
+	if s.tlsConfig != nil {
+		tconn := tls.Client(conn, s.tlsConfig)
+		if err := tconn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn, r = tconn, bufio.NewReader(tconn)
+	}
+	connect := map[string]interface{}{"verbose": false, "pedantic": false, "name": "gclog", "lang": "go", "version": "1", "protocol": 1}
+	if s.opts.Token != "" {
+		connect["auth_token"] = s.opts.Token
+	}
+	if s.opts.User != "" {
+		connect["user"], connect["pass"] = s.opts.User, s.opts.Password
+	}
+	data, _ := json.Marshal(connect)
+	cmd := "CONNECT " + string(data) + "\r\n"
+	if s.opts.JetStream {
+		cmd += "SUB " + s.inbox + ".* 1\r\n"
+	}
+	if _, err := conn.Write([]byte(cmd + "PING\r\n")); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		switch {
+		case strings.HasPrefix(line, "PONG"):
+			conn.SetDeadline(time.Time{})
+			return &natsConn{conn: conn, r: r, wlock: new(sync.Mutex), acks: make(chan bool, natsMaxBatch), closed: make(chan struct{})}, nil
+		case strings.HasPrefix(line, "-ERR"):
+			conn.Close()
+			return nil, errors.New("gclog: nats " + strings.TrimSpace(line))
+		}
+	}
+}
+
+//readLoop 读goroutine，回复PING，将JetStream确认放入acks，连接出错时退出
+func (c *natsConn) readLoop(inbox string) {
+	defer close(c.closed)
+	r := c.r
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "PING":
+			if err := c.write([]byte("PONG\r\n"), 5*time.Second); err != nil {
+				return
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			//服务端出错后会关闭连接
+			c.conn.Close()
+			return
+		case strings.HasPrefix(line, "MSG "):
+			//MSG <subject> <sid> [reply-to] <size>
+			args := strings.Fields(line)
+			size, err := strconv.Atoi(args[len(args)-1])
+			if err != nil {
+				c.conn.Close()
+				return
+			}
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			if strings.HasPrefix(args[1], inbox+".") {
+				//确认消息为{"stream":"LOGS","seq":1}，失败时包含error
+				select {
+				case c.acks <- !bytes.Contains(payload, []byte(`"error"`)):
+				default:
+				}
+			}
+		}
+	}
+}
+
+//write 写入命令，与读goroutine的PONG互斥
+func (c *natsConn) write(p []byte, timeout time.Duration) error {
+	c.wlock.Lock()
+	defer c.wlock.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(timeout))
+	_, err := c.conn.Write(p)
+	return err
+}
+
+//waitAcks 等待n个JetStream确认，返回确认成功的条数；超时或连接断开时第二个返回值为false
+func (c *natsConn) waitAcks(n int, timeout time.Duration) (int, bool) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	acked := 0
+	for i := 0; i < n; i++ {
+		select {
+		case ok := <-c.acks:
+			if ok {
+				acked++
+			}
+		case <-c.closed:
+			return 0, false
+		case <-timer.C:
+			return 0, false
+		}
+	}
+	return acked, true
+}
+
+//close 关闭连接并等待读goroutine退出
+func (c *natsConn) close() {
+	c.conn.Close()
+	<-c.closed
+}
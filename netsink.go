@@ -0,0 +1,242 @@
+package gclog
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var errSinkFull = errors.New("gclog: sink queue full, entry dropped")
+
+//NetOptions 网络sink的设置
+type NetOptions struct {
+	TLS          *TLSOptions   //不为nil时使用TLS连接
+	QueueSize    int           //发送队列长度，队列满时丢弃日志，<=0时默认4096
+	DialTimeout  time.Duration //连接超时，<=0时默认5s
+	WriteTimeout time.Duration //写入超时，<=0时默认5s
+	//Frame 将一条编码后的日志追加到发送缓冲区，为nil时按行发送（编码结果以换行结尾）
+	Frame func(dst []byte, e *Entry, line []byte) []byte
+	//Compression 批次压缩方式，开启后按压缩批次帧发送，接收端使用NewBatchReader还原
+	Compression Compression
+	//CompressThreshold 批次达到该字节数才压缩，<=0时默认1024
+	CompressThreshold int
+}
+
+//NetSink 通过TCP（可选TLS）发送日志的sink
+//日志先进入发送队列，由后台goroutine合并成批次发送，网络异常不会阻塞写日志，断线后自动重连
+type NetSink struct {
+	network   string
+	addr      string
+	opts      NetOptions
+	tlsConfig *tls.Config
+	datagram  bool //udp等数据报连接，每条日志单独发送，不合并批次
+	queue     chan *buffer
+	done      chan struct{}
+	closeOnce sync.Once
+	conn      net.Conn         //只在发送goroutine中使用
+	compress  *batchCompressor //开启压缩时的压缩器，只在发送goroutine中使用
+	redial    redial           //重连间隔，只在发送goroutine中使用
+	dropped   uint64           //丢弃的日志数，原子读写
+	sent      uint64           //发送成功的日志数，原子读写
+}
+
+//NewNetSink 创建发送到addr的网络sink，network为tcp、tcp4、tcp6、unix等，udp、unixgram时每条日志单独发送一个数据报
+func NewNetSink(network, addr string, opts NetOptions) (*NetSink, error) {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 4096
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 5 * time.Second
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = 5 * time.Second
+	}
+	s := &NetSink{
+		network:  network,
+		addr:     addr,
+		opts:     opts,
+		datagram: isDatagram(network),
+		queue:    make(chan *buffer, opts.QueueSize),
+		done:     make(chan struct{}),
+	}
+	if opts.Compression == CompressGzip {
+		s.compress = newBatchCompressor(opts.CompressThreshold)
+	}
+	if opts.TLS != nil {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		if s.tlsConfig, err = opts.TLS.config(host); err != nil {
+			return nil, err
+		}
+	}
+	go s.run()
+	return s, nil
+}
+
+//SendTo 创建网络sink并注册，同一地址重复调用时替换之前的sink
+//exp: gclog.SendTo("tcp", "log.example.com:6514", gclog.NetOptions{TLS: &gclog.TLSOptions{CAFile: "ca.pem"}})
+func SendTo(network, addr string, opts NetOptions) (*NetSink, error) {
+	s, err := NewNetSink(network, addr, opts)
+	if err != nil {
+		return nil, err
+	}
+	if old := addSink("net:"+addr, s); old != nil {
+		closeSink(old)
+	}
+	return s, nil
+}
+
+//Write 实现Sink，复制日志后放入发送队列，队列满时丢弃并返回错误
+func (s *NetSink) Write(e *Entry, line []byte) error {
+	buf := getBuffer()
+	if s.opts.Frame != nil {
+		buf.b = s.opts.Frame(buf.b, e, line)
+	} else {
+		buf.b = append(buf.b, line...)
+	}
+	select {
+	case s.queue <- buf:
+		return nil
+	default:
+		putBuffer(buf)
+		atomic.AddUint64(&s.dropped, 1)
+		return errSinkFull
+	}
+}
+
+//Close 实现Sink，发送队列中剩余的日志后关闭连接
+func (s *NetSink) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.queue)
+	})
+	<-s.done
+	return nil
+}
+
+//Dropped 返回因队列满或发送失败丢弃的日志数
+func (s *NetSink) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+//Sent 返回发送成功的日志数
+func (s *NetSink) Sent() uint64 {
+	return atomic.LoadUint64(&s.sent)
+}
+
+//run 发送goroutine，阻塞等待第一条日志后合并队列中已有的日志一起发送
+func (s *NetSink) run() {
+	defer close(s.done)
+	batch := make([]byte, 0, asyncBatchSize)
+	var framed []byte
+	for {
+		buf, ok := <-s.queue
+		if !ok {
+			break
+		}
+		count := 1
+		batch = append(batch[:0], buf.b...)
+		putBuffer(buf)
+	drain:
+		for len(batch) < asyncBatchSize && !s.datagram {
+			select {
+			case buf, ok := <-s.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, buf.b...)
+				putBuffer(buf)
+				count++
+			default:
+				break drain
+			}
+		}
+		data := batch
+		if s.compress != nil {
+			framed = s.compress.frame(framed[:0], batch)
+			data = framed
+		}
+		if s.send(data) {
+			atomic.AddUint64(&s.sent, uint64(count))
+		} else {
+			atomic.AddUint64(&s.dropped, uint64(count))
+		}
+	}
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+//isDatagram 判断network是否为数据报连接
+func isDatagram(network string) bool {
+	return strings.HasPrefix(network, "udp") || network == "unixgram"
+}
+
+//send 发送一个批次，连接断开时重连后重试一次
+func (s *NetSink) send(batch []byte) bool {
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil && !s.dial() {
+			return false
+		}
+		s.conn.SetWriteDeadline(time.Now().Add(s.opts.WriteTimeout))
+		if _, err := s.conn.Write(batch); err == nil {
+			return true
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return false
+}
+
+//dial 建立连接，连接失败后在重连间隔内直接返回失败
+func (s *NetSink) dial() bool {
+	if !s.redial.allow() {
+		return false
+	}
+	dialer := &net.Dialer{Timeout: s.opts.DialTimeout}
+	var conn net.Conn
+	var err error
+	if s.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, s.network, s.addr, s.tlsConfig)
+	} else {
+		conn, err = dialer.Dial(s.network, s.addr)
+	}
+	if err != nil {
+		s.redial.failed()
+		return false
+	}
+	s.conn = conn
+	s.redial.reset()
+	return true
+}
+
+//redial 连接失败后的重连间隔，从1s开始倍增，最大30s
+type redial struct {
+	backoff time.Duration
+	next    time.Time
+}
+
+//allow 判断是否到了允许重连的时间
+func (r *redial) allow() bool {
+	return !time.Now().Before(r.next)
+}
+
+//failed 连接失败，增加重连间隔
+func (r *redial) failed() {
+	if r.backoff == 0 {
+		r.backoff = time.Second
+	} else if r.backoff < 30*time.Second {
+		r.backoff *= 2
+	}
+	r.next = time.Now().Add(r.backoff)
+}
+
+//reset 连接成功，重置重连间隔
+func (r *redial) reset() {
+	r.backoff = 0
+}
@@ -0,0 +1,112 @@
+package gclog
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+//filePerm 创建日志文件、目录时使用的权限与属主
+type filePerm struct {
+	fileMode  os.FileMode //日志文件权限
+	dirMode   os.FileMode //目录权限
+	chmodFile bool        //是否设置过文件权限，设置后创建时不受umask影响
+	chmodDir  bool        //是否设置过目录权限
+	uid       int         //属主，-1为不修改
+	gid       int         //属组，-1为不修改
+}
+
+var (
+	permLock = new(sync.RWMutex)
+	perm     = filePerm{fileMode: 0666, dirMode: 0755, uid: -1, gid: -1} //默认与os.Create、mkdir -p相同，受umask影响
+)
+
+//SetFileMode 设置新建日志文件（包括切分后的新文件、RouteLevels的文件、崩溃文件等）的权限，不受umask影响
+//默认为0666（再受umask影响，一般为0644），日志含敏感信息时可以设为0640或0600
+//exp: gclog.SetFileMode(0640)
+func SetFileMode(mode os.FileMode) {
+	permLock.Lock()
+	defer permLock.Unlock()
+	perm.fileMode = mode.Perm()
+	perm.chmodFile = true
+}
+
+//SetDirMode 设置日志目录不存在时新建目录的权限，设置后不受umask影响，默认为0755（受umask影响）
+func SetDirMode(mode os.FileMode) {
+	permLock.Lock()
+	defer permLock.Unlock()
+	perm.dirMode = mode.Perm()
+	perm.chmodDir = true
+}
+
+//SetFileOwner 设置新建日志文件与目录的属主、属组，-1为不修改，需要进程有相应权限（一般为root）
+//exp: 以root启动后降权的服务 gclog.SetFileOwner(-1, appGid)
+func SetFileOwner(uid, gid int) {
+	permLock.Lock()
+	defer permLock.Unlock()
+	perm.uid, perm.gid = uid, gid
+}
+
+//loadFilePerm 取当前的权限配置
+func loadFilePerm() filePerm {
+	permLock.RLock()
+	defer permLock.RUnlock()
+	return perm
+}
+
+//createFile 以flag打开文件，文件不存在时按设置的权限创建，目录不存在时递归创建
+func createFile(filename string, flag int) (*os.File, error) {
+	p := loadFilePerm()
+	if err := mkdirAll(filepath.Dir(filename), p); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filename, flag|os.O_CREATE|os.O_EXCL, p.fileMode)
+	if os.IsExist(err) {
+		//已存在，不修改权限
+		return os.OpenFile(filename, flag, p.fileMode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := applyPerm(file.Name(), p.fileMode, p.chmodFile, p); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+//mkdirAll 递归创建目录，新建的目录按设置的权限与属主
+func mkdirAll(dir string, p filePerm) error {
+	if info, err := os.Stat(dir); err == nil {
+		if !info.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: dir, Err: os.ErrExist}
+		}
+		return nil
+	}
+	if parent := filepath.Dir(dir); parent != dir {
+		if err := mkdirAll(parent, p); err != nil {
+			return err
+		}
+	}
+	if err := os.Mkdir(dir, p.dirMode); err != nil {
+		//并发创建
+		if os.IsExist(err) {
+			return nil
+		}
+		return err
+	}
+	return applyPerm(dir, p.dirMode, p.chmodDir, p)
+}
+
+//applyPerm 对新建的文件或目录设置权限与属主
+func applyPerm(name string, mode os.FileMode, chmod bool, p filePerm) error {
+	if chmod {
+		if err := os.Chmod(name, mode); err != nil {
+			return err
+		}
+	}
+	if p.uid != -1 || p.gid != -1 {
+		return os.Chown(name, p.uid, p.gid)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package gclog
+
+import (
+	"runtime"
+	"sync"
+)
+
+//buffer 可复用的编码缓冲区，放入sync.Pool时使用指针避免额外分配
+type buffer struct {
+	b []byte
+}
+
+var (
+	//bufferPool 编码缓冲区池
+	bufferPool = sync.Pool{New: func() interface{} {
+		return &buffer{b: make([]byte, 0, 512)}
+	}}
+	//entryPool Entry对象池
+	entryPool = sync.Pool{New: func() interface{} {
+		return new(Entry)
+	}}
+)
+
+//getBuffer 从池中取一个空的缓冲区
+func getBuffer() *buffer {
+	buf := bufferPool.Get().(*buffer)
+	buf.b = buf.b[:0]
+	return buf
+}
+
+//putBuffer 归还缓冲区，超过maxReuseBufSize的缓冲区直接丢弃，避免偶发的大日志长期占用内存
+func putBuffer(buf *buffer) {
+	if cap(buf.b) > maxReuseBufSize {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+//getEntry 从池中取一个空的Entry
+func getEntry() *Entry {
+	return entryPool.Get().(*Entry)
+}
+
+//putEntry 清空并归还Entry，归还后不能再引用其中的字段切片
+func putEntry(e *Entry) {
+	*e = Entry{}
+	entryPool.Put(e)
+}
+
+//callerFileLine 取调用者的文件与行号，skip为0时表示callerFileLine的调用者
+//使用runtime.Callers+FuncForPC代替runtime.Caller，避免每次调用产生堆分配
+func callerFileLine(skip int) (string, int, bool) {
+	var pcs [1]uintptr
+	if runtime.Callers(skip+2, pcs[:]) < 1 {
+		return "", 0, false
+	}
+	//Callers返回的是返回地址，减1后落在调用指令上，保证行号正确
+	pc := pcs[0] - 1
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return "", 0, false
+	}
+	file, line := fn.FileLine(pc)
+	return file, line, true
+}
@@ -0,0 +1,780 @@
+package gclog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//postgresSpillPrefix 落盘批次的文件名前缀
+const postgresSpillPrefix = "gclog-pg-"
+
+var errPostgresProtocol = errors.New("gclog: unexpected postgres message")
+
+//PostgresOptions PostgreSQL sink的设置
+type PostgresOptions struct {
+	TLS      *TLSOptions //不为nil时使用TLS连接（sslmode=verify-full）
+	User     string
+	Password string //支持cleartext、md5与SCRAM-SHA-256认证
+	Database string //为空时与User相同
+	Table    string //表名，为空时为logs
+	//PoolSize 连接数，每个连接由一个goroutine写入，<=0时默认2
+	PoolSize      int
+	BatchSize     int           //一次COPY的最多日志数，<=0时默认1000
+	FlushInterval time.Duration //未满一批时的写入间隔，<=0时默认1s
+	QueueSize     int           //等待写入的最多日志数，超出时丢弃日志，<=0时默认8192
+	Timeout       time.Duration //连接与一次COPY的超时，<=0时默认10s
+	//SpillDir 写入失败的批次落盘的目录，连接恢复后按顺序补写，为空时写入失败的日志直接丢弃
+	SpillDir string
+	//MaxSpillSize 落盘批次的总大小上限，超出时丢弃日志，<=0时默认256MB
+	MaxSpillSize int64
+}
+
+//PostgresSink 以COPY批量写入PostgreSQL分区表的sink，用于需要关系查询的审计场景
+//使用PostgreSQL的前后端协议，不依赖第三方库；表不存在时自动创建，按time以天（UTC）为范围分区，需要时创建分区
+//列为time、level、logger、caller、msg、fields（jsonb）、stack、entry_id、seq，按time与level、time建索引
+//数据库不可用时批次写入SpillDir，连接恢复后补写，服务端拒绝的批次（数据或表结构错误）丢弃并提示
+//exp:
+//
+//	SELECT time, msg, fields->>'user' FROM logs WHERE level >= 5 AND time > now() - interval '1 day'
+type PostgresSink struct {
+	addr       string
+	table      string
+	opts       PostgresOptions
+	tlsConfig  *tls.Config
+	copySQL    string
+	queue      chan *buffer
+	batches    chan *postgresBatch
+	done       chan struct{}
+	closeOnce  sync.Once
+	ddlLock    sync.Mutex
+	schema     bool            //表与索引已创建，ddlLock保护
+	partitions map[string]bool //已创建的分区，ddlLock保护
+	replaying  int32           //有连接正在补写落盘批次，原子读写
+	spillSeq   uint64          //落盘文件序号，原子读写
+	spillSize  int64           //落盘批次的总大小，原子读写
+	dropped    uint64          //丢弃的日志数，原子读写
+	sent       uint64          //写入成功的日志数，原子读写
+}
+
+//postgresBatch 一次COPY的数据，每行一条日志
+type postgresBatch struct {
+	data []byte
+	n    int
+}
+
+//postgresError 服务端返回的ErrorResponse，连接仍然可用
+type postgresError struct {
+	code string
+	msg  string
+}
+
+func (e *postgresError) Error() string {
+	return "gclog: postgres " + e.msg + " (SQLSTATE " + e.code + ")"
+}
+
+//postgresConn 一个PostgreSQL连接，只在一个写入goroutine中使用
+type postgresConn struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+}
+
+//NewPostgresSink 创建写入PostgreSQL的sink，addr为host:port，连接在写入时建立
+//exp: s, err := gclog.NewPostgresSink("127.0.0.1:5432", gclog.PostgresOptions{User: "audit", Password: "secret", SpillDir: "/var/spool/app"})
+func NewPostgresSink(addr string, opts PostgresOptions) (*PostgresSink, error) {
+	if opts.Table == "" {
+		opts.Table = "logs"
+	}
+	for _, c := range []byte(opts.Table) {
+		if !isAlnum(c) && c != '_' {
+			return nil, fmt.Errorf("gclog: invalid postgres table name %q", opts.Table)
+		}
+	}
+	if opts.User == "" {
+		return nil, errors.New("gclog: empty postgres user")
+	}
+	if opts.Database == "" {
+		opts.Database = opts.User
+	}
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = 2
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 8192
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.MaxSpillSize <= 0 {
+		opts.MaxSpillSize = 256 << 20
+	}
+	s := &PostgresSink{
+		addr:       addr,
+		table:      opts.Table,
+		opts:       opts,
+		copySQL:    "COPY " + opts.Table + " (time, level, logger, caller, msg, fields, stack, entry_id, seq) FROM STDIN",
+		queue:      make(chan *buffer, opts.QueueSize),
+		batches:    make(chan *postgresBatch, opts.PoolSize),
+		done:       make(chan struct{}),
+		partitions: make(map[string]bool),
+	}
+	if opts.TLS != nil {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		if s.tlsConfig, err = opts.TLS.config(host); err != nil {
+			return nil, err
+		}
+	}
+	if opts.SpillDir != "" {
+		if err := os.MkdirAll(opts.SpillDir, 0755); err != nil {
+			return nil, err
+		}
+		for _, name := range s.spillFiles() {
+			if fi, err := os.Stat(name); err == nil {
+				s.spillSize += fi.Size()
+			}
+		}
+	}
+	go s.run()
+	return s, nil
+}
+
+//SendToPostgres 创建PostgreSQL sink并注册，同一地址与表重复调用时替换之前的sink
+//exp: gclog.SendToPostgres("127.0.0.1:5432", gclog.PostgresOptions{User: "audit", Table: "audit_logs"})
+func SendToPostgres(addr string, opts PostgresOptions) (*PostgresSink, error) {
+	s, err := NewPostgresSink(addr, opts)
+	if err != nil {
+		return nil, err
+	}
+	if old := addSink("postgres:"+addr+"/"+s.table, s); old != nil {
+		closeSink(old)
+	}
+	return s, nil
+}
+
+//Write 实现Sink，将日志编码为COPY的一行放入等待队列，队列满时丢弃并返回错误
+func (s *PostgresSink) Write(e *Entry, line []byte) error {
+	buf := getBuffer()
+	buf.b = appendPostgresRow(buf.b, e)
+	select {
+	case s.queue <- buf:
+		return nil
+	default:
+		putBuffer(buf)
+		atomic.AddUint64(&s.dropped, 1)
+		return errSinkFull
+	}
+}
+
+//Close 实现Sink，写入队列中剩余的日志后关闭连接，写入失败的批次留在SpillDir中下次启动时补写
+func (s *PostgresSink) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.queue)
+	})
+	<-s.done
+	return nil
+}
+
+//Dropped 返回因队列满、写入失败或服务端拒绝丢弃的日志数，落盘的日志不计入
+func (s *PostgresSink) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+//Sent 返回写入成功的日志数，包括补写的落盘日志
+func (s *PostgresSink) Sent() uint64 {
+	return atomic.LoadUint64(&s.sent)
+}
+
+//appendPostgresRow 追加COPY文本格式的一行，空的可选列为NULL
+func appendPostgresRow(buf []byte, e *Entry) []byte {
+	buf = e.Time.UTC().AppendFormat(buf, "2006-01-02 15:04:05.000000+00")
+	buf = append(buf, '\t')
+	buf = strconv.AppendInt(buf, int64(e.Level), 10)
+	buf = append(buf, '\t')
+	buf = appendCopyText(buf, e.Logger)
+	buf = append(buf, '\t')
+	if e.File != "" {
+		buf = appendCopyText(buf, formatCaller(e.File)+":"+strconv.Itoa(e.Line))
+	} else {
+		buf = append(buf, `\N`...)
+	}
+	buf = append(buf, '\t')
+	if e.Message == "" {
+		buf = append(buf, `\N`...)
+	} else {
+		buf = appendCopyText(buf, e.Message)
+	}
+	buf = append(buf, '\t')
+	if len(e.Fields) == 0 {
+		buf = append(buf, `\N`...)
+	} else {
+		fields := getBuffer()
+		fields.b = append(fields.b, '{')
+		for i, f := range e.Fields {
+			if i > 0 {
+				fields.b = append(fields.b, ',')
+			}
+			fields.b = appendJSONString(fields.b, f.Key)
+			fields.b = append(fields.b, ':')
+			fields.b = appendJSONValue(fields.b, f.Value)
+		}
+		fields.b = append(fields.b, '}')
+		buf = appendCopyText(buf, string(fields.b))
+		putBuffer(fields)
+	}
+	buf = append(buf, '\t')
+	buf = appendCopyText(buf, e.Stack)
+	buf = append(buf, '\t')
+	buf = appendCopyText(buf, e.ID)
+	buf = append(buf, '\t')
+	buf = strconv.AppendUint(buf, e.Seq, 10)
+	return append(buf, '\n')
+}
+
+//appendCopyText 追加COPY文本格式的一个值，转义反斜杠与控制字符，去掉NUL，空字符串为NULL
+func appendCopyText(buf []byte, s string) []byte {
+	if s == "" {
+		return append(buf, `\N`...)
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case 0:
+		case '\\':
+			buf = append(buf, `\\`...)
+		case '\t':
+			buf = append(buf, `\t`...)
+		case '\n':
+			buf = append(buf, `\n`...)
+		case '\r':
+			buf = append(buf, `\r`...)
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return buf
+}
+
+//run 收集goroutine，满一批或到达FlushInterval时交给写入goroutine
+func (s *PostgresSink) run() {
+	defer close(s.done)
+	var wg sync.WaitGroup
+	for i := 0; i < s.opts.PoolSize; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.worker()
+		}()
+	}
+	ticker := time.NewTicker(s.opts.FlushInterval)
+	defer ticker.Stop()
+	batch := &postgresBatch{}
+	flush := func() {
+		if batch.n > 0 {
+			s.batches <- batch
+			batch = &postgresBatch{}
+		}
+	}
+loop:
+	for {
+		select {
+		case buf, ok := <-s.queue:
+			if !ok {
+				break loop
+			}
+			batch.data = append(batch.data, buf.b...)
+			batch.n++
+			putBuffer(buf)
+			if batch.n >= s.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+	flush()
+	close(s.batches)
+	wg.Wait()
+}
+
+//worker 写入goroutine，持有一个连接，写入成功后补写落盘批次，连接不可用时将批次落盘
+func (s *PostgresSink) worker() {
+	var c *postgresConn
+	var redial redial
+	var lastErr string
+	warn := func(err error) {
+		//相同的错误只提示一次，避免数据库不可用时刷屏
+		if err.Error() != lastErr {
+			lastErr = err.Error()
+			Warning("postgres sink %s failed, because %s", s.addr, lastErr)
+		}
+	}
+	for batch := range s.batches {
+		if c == nil && redial.allow() {
+			var err error
+			if c, err = s.connect(); err != nil {
+				redial.failed()
+				warn(err)
+			} else {
+				redial.reset()
+			}
+		}
+		if c != nil {
+			err := s.copy(c, batch.data)
+			if err == nil {
+				lastErr = ""
+				atomic.AddUint64(&s.sent, uint64(batch.n))
+				if err = s.replaySpill(c); err != nil {
+					warn(err)
+					c.close()
+					c = nil
+				}
+				continue
+			}
+			warn(err)
+			if _, ok := err.(*postgresError); ok {
+				atomic.AddUint64(&s.dropped, uint64(batch.n))
+				continue
+			}
+			c.close()
+			c = nil
+		}
+		s.spill(batch)
+	}
+	if c != nil {
+		c.close()
+	}
+}
+
+//copy 确保表与分区存在后以COPY写入数据
+func (s *PostgresSink) copy(c *postgresConn, data []byte) error {
+	if err := s.ensurePartitions(c, data); err != nil {
+		return err
+	}
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if err := c.send('Q', append([]byte(s.copySQL), 0)); err != nil {
+		return err
+	}
+	for {
+		typ, body, err := c.receive()
+		if err != nil {
+			return err
+		}
+		if typ == 'G' {
+			break
+		}
+		if typ == 'E' {
+			return c.finish(parsePostgresError(body))
+		}
+	}
+	if err := c.send('d', data); err != nil {
+		return err
+	}
+	if err := c.send('c', nil); err != nil {
+		return err
+	}
+	return c.finish(nil)
+}
+
+//ensurePartitions 需要时创建表、索引与数据中各天的分区
+func (s *PostgresSink) ensurePartitions(c *postgresConn, data []byte) error {
+	s.ddlLock.Lock()
+	defer s.ddlLock.Unlock()
+	if !s.schema {
+		t := s.table
+		err := c.exec("CREATE TABLE IF NOT EXISTS " + t + " (time timestamptz NOT NULL, level smallint NOT NULL, logger text, caller text, " +
+			"msg text, fields jsonb, stack text, entry_id text, seq bigint) PARTITION BY RANGE (time);" +
+			"CREATE INDEX IF NOT EXISTS " + t + "_time ON " + t + " (time);" +
+			"CREATE INDEX IF NOT EXISTS " + t + "_level_time ON " + t + " (level, time)")
+		if err = ignoreExists(err); err != nil {
+			return err
+		}
+		s.schema = true
+	}
+	//每行以“2006-01-02 ”开头
+	for len(data) >= 10 {
+		day := string(data[:10])
+		if !s.partitions[day] {
+			start, err := time.Parse("2006-01-02", day)
+			if err != nil {
+				return err
+			}
+			end := start.AddDate(0, 0, 1).Format("2006-01-02")
+			name := s.table + "_" + strings.Replace(day, "-", "", -1)
+			err = c.exec("CREATE TABLE IF NOT EXISTS " + name + " PARTITION OF " + s.table +
+				" FOR VALUES FROM ('" + day + " 00:00:00+00') TO ('" + end + " 00:00:00+00')")
+			if err = ignoreExists(err); err != nil {
+				return err
+			}
+			s.partitions[day] = true
+		}
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		data = data[i+1:]
+	}
+	return nil
+}
+
+//ignoreExists 忽略多个进程同时建表时的对象已存在错误
+func ignoreExists(err error) error {
+	if e, ok := err.(*postgresError); ok && (e.code == "42P07" || e.code == "23505") {
+		return nil
+	}
+	return err
+}
+
+//spill 将写入失败的批次落盘，没有SpillDir或超出MaxSpillSize时丢弃
+func (s *PostgresSink) spill(batch *postgresBatch) {
+	if s.opts.SpillDir == "" || atomic.LoadInt64(&s.spillSize)+int64(len(batch.data)) > s.opts.MaxSpillSize {
+		atomic.AddUint64(&s.dropped, uint64(batch.n))
+		return
+	}
+	//文件名按时间与序号排序，补写时保持顺序
+	name := filepath.Join(s.opts.SpillDir, fmt.Sprintf("%s%s-%020d-%06d.copy", postgresSpillPrefix, s.table,
+		time.Now().UnixNano(), atomic.AddUint64(&s.spillSeq, 1)%1000000))
+	if err := os.WriteFile(name+".tmp", batch.data, 0600); err != nil {
+		atomic.AddUint64(&s.dropped, uint64(batch.n))
+		return
+	}
+	if err := os.Rename(name+".tmp", name); err != nil {
+		os.Remove(name + ".tmp")
+		atomic.AddUint64(&s.dropped, uint64(batch.n))
+		return
+	}
+	atomic.AddInt64(&s.spillSize, int64(len(batch.data)))
+}
+
+//spillFiles 返回按顺序排列的落盘批次
+func (s *PostgresSink) spillFiles() []string {
+	names, _ := filepath.Glob(filepath.Join(s.opts.SpillDir, postgresSpillPrefix+s.table+"-*.copy"))
+	sort.Strings(names)
+	return names
+}
+
+//replaySpill 补写落盘批次，同一时间只有一个连接补写，连接出错时返回错误
+func (s *PostgresSink) replaySpill(c *postgresConn) error {
+	if s.opts.SpillDir == "" || atomic.LoadInt64(&s.spillSize) == 0 || !atomic.CompareAndSwapInt32(&s.replaying, 0, 1) {
+		return nil
+	}
+	defer atomic.StoreInt32(&s.replaying, 0)
+	for _, name := range s.spillFiles() {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			continue
+		}
+		n := bytes.Count(data, []byte{'\n'})
+		err = s.copy(c, data)
+		if _, ok := err.(*postgresError); ok {
+			Warning("postgres sink dropped spilled batch %s, because %s", name, err.Error())
+			atomic.AddUint64(&s.dropped, uint64(n))
+		} else if err != nil {
+			return err
+		} else {
+			atomic.AddUint64(&s.sent, uint64(n))
+		}
+		os.Remove(name)
+		atomic.AddInt64(&s.spillSize, -int64(len(data)))
+	}
+	return nil
+}
+
+//connect 建立连接，需要时升级TLS，完成认证并等待ReadyForQuery
+func (s *PostgresSink) connect() (*postgresConn, error) {
+	conn, err := net.DialTimeout("tcp", s.addr, s.opts.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(s.opts.Timeout))
+	if s.tlsConfig != nil {
+		//SSLRequest
+		if _, err := conn.Write([]byte{0, 0, 0, 8, 0x04, 0xd2, 0x16, 0x2f}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		reply := make([]byte, 1)
+		if _, err := io.ReadFull(conn, reply); err != nil || reply[0] != 'S' {
+			conn.Close()
+			return nil, errors.New("gclog: postgres server does not support TLS")
+		}
+		tconn := tls.Client(conn, s.tlsConfig)
+		if err := tconn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tconn
+	}
+	c := &postgresConn{conn: conn, r: bufio.NewReader(conn), timeout: s.opts.Timeout}
+	if err := c.startup(s.opts.User, s.opts.Password, s.opts.Database); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+//startup 发送StartupMessage并完成认证
+func (c *postgresConn) startup(user, password, database string) error {
+	msg := []byte{0, 3, 0, 0}
+	for _, kv := range []string{"user", user, "database", database, "application_name", "gclog", "client_encoding", "UTF8"} {
+		msg = append(msg, kv...)
+		msg = append(msg, 0)
+	}
+	msg = append(msg, 0)
+	if err := c.send(0, msg); err != nil {
+		return err
+	}
+	var scram *scramClient
+	for {
+		typ, body, err := c.receive()
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case 'E':
+			return parsePostgresError(body)
+		case 'Z':
+			return nil
+		case 'R':
+			if len(body) < 4 {
+				return errPostgresProtocol
+			}
+			switch binary.BigEndian.Uint32(body) {
+			case 0:
+			case 3:
+				err = c.send('p', append([]byte(password), 0))
+			case 5:
+				if len(body) < 8 {
+					return errPostgresProtocol
+				}
+				inner := md5.Sum([]byte(password + user))
+				outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), body[4:8]...))
+				err = c.send('p', append([]byte("md5"+hex.EncodeToString(outer[:])), 0))
+			case 10:
+				if !bytes.Contains(body[4:], []byte("SCRAM-SHA-256\x00")) {
+					return errors.New("gclog: postgres server requires an unsupported SASL mechanism")
+				}
+				scram = newSCRAMClient(password)
+				first := scram.first()
+				msg := append([]byte("SCRAM-SHA-256\x00"), 0, 0, 0, 0)
+				binary.BigEndian.PutUint32(msg[len(msg)-4:], uint32(len(first)))
+				err = c.send('p', append(msg, first...))
+			case 11:
+				if scram == nil {
+					return errPostgresProtocol
+				}
+				var final []byte
+				if final, err = scram.final(body[4:]); err == nil {
+					err = c.send('p', final)
+				}
+			case 12:
+				if scram == nil || !scram.verify(body[4:]) {
+					return errors.New("gclog: postgres server signature mismatch")
+				}
+			default:
+				return errors.New("gclog: postgres server requires an unsupported authentication method")
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+//exec 执行简单查询，返回第一个错误
+func (c *postgresConn) exec(query string) error {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if err := c.send('Q', append([]byte(query), 0)); err != nil {
+		return err
+	}
+	return c.finish(nil)
+}
+
+//finish 读取到ReadyForQuery为止，返回err或期间收到的第一个ErrorResponse
+func (c *postgresConn) finish(err error) error {
+	for {
+		typ, body, rerr := c.receive()
+		if rerr != nil {
+			return rerr
+		}
+		switch typ {
+		case 'E':
+			if err == nil {
+				err = parsePostgresError(body)
+			}
+		case 'Z':
+			return err
+		}
+	}
+}
+
+//send 发送一个消息，typ为0时没有类型字节（StartupMessage）
+func (c *postgresConn) send(typ byte, body []byte) error {
+	msg := make([]byte, 0, len(body)+5)
+	if typ != 0 {
+		msg = append(msg, typ)
+	}
+	msg = append(msg, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(msg[len(msg)-4:], uint32(len(body)+4))
+	_, err := c.conn.Write(append(msg, body...))
+	return err
+}
+
+//receive 读取一个消息
+func (c *postgresConn) receive() (byte, []byte, error) {
+	var head [5]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	size := int(binary.BigEndian.Uint32(head[1:]))
+	if size < 4 || size > 1<<24 {
+		return 0, nil, errPostgresProtocol
+	}
+	body := make([]byte, size-4)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return head[0], body, nil
+}
+
+//close 发送Terminate并关闭连接
+func (c *postgresConn) close() {
+	c.conn.SetDeadline(time.Now().Add(time.Second))
+	c.send('X', nil)
+	c.conn.Close()
+}
+
+//parsePostgresError 解析ErrorResponse
+func parsePostgresError(body []byte) error {
+	e := &postgresError{}
+	for _, field := range bytes.Split(body, []byte{0}) {
+		if len(field) == 0 {
+			continue
+		}
+		switch field[0] {
+		case 'C':
+			e.code = string(field[1:])
+		case 'M':
+			e.msg = string(field[1:])
+		}
+	}
+	return e
+}
+
+//scramClient SCRAM-SHA-256认证（RFC 5802、RFC 7677）
+type scramClient struct {
+	password    string
+	nonce       string
+	clientFirst string //client-first-message-bare
+	authMessage string
+	salted      []byte
+}
+
+//newSCRAMClient 创建SCRAM客户端，生成随机nonce
+func newSCRAMClient(password string) *scramClient {
+	raw := make([]byte, 18)
+	rand.Read(raw)
+	nonce := base64.StdEncoding.EncodeToString(raw)
+	//PostgreSQL使用StartupMessage中的用户名，这里的用户名为空
+	return &scramClient{password: password, nonce: nonce, clientFirst: "n=,r=" + nonce}
+}
+
+//first 返回client-first-message
+func (c *scramClient) first() []byte {
+	return []byte("n,," + c.clientFirst)
+}
+
+//final 根据server-first-message返回client-final-message
+func (c *scramClient) final(serverFirst []byte) ([]byte, error) {
+	var nonce, salt string
+	iterations := 0
+	for _, attr := range strings.Split(string(serverFirst), ",") {
+		switch {
+		case strings.HasPrefix(attr, "r="):
+			nonce = attr[2:]
+		case strings.HasPrefix(attr, "s="):
+			salt = attr[2:]
+		case strings.HasPrefix(attr, "i="):
+			iterations, _ = strconv.Atoi(attr[2:])
+		}
+	}
+	saltBytes, err := base64.StdEncoding.DecodeString(salt)
+	if err != nil || iterations <= 0 || !strings.HasPrefix(nonce, c.nonce) {
+		return nil, errPostgresProtocol
+	}
+	c.salted = pbkdf2SHA256([]byte(c.password), saltBytes, iterations)
+	withoutProof := "c=biws,r=" + nonce
+	c.authMessage = c.clientFirst + "," + string(serverFirst) + "," + withoutProof
+	clientKey := hmacSHA256(c.salted, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	proof := hmacSHA256(storedKey[:], c.authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	return []byte(withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+//verify 校验server-final-message中的服务端签名
+func (c *scramClient) verify(serverFinal []byte) bool {
+	if c.salted == nil || !bytes.HasPrefix(serverFinal, []byte("v=")) {
+		return false
+	}
+	signature, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(serverFinal[2:])))
+	if err != nil {
+		return false
+	}
+	serverKey := hmacSHA256(c.salted, "Server Key")
+	return hmac.Equal(signature, hmacSHA256(serverKey, c.authMessage))
+}
+
+//hmacSHA256 返回HMAC-SHA256
+func hmacSHA256(key []byte, msg string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(msg))
+	return mac.Sum(nil)
+}
+
+//pbkdf2SHA256 PBKDF2-HMAC-SHA256，输出一个块（32字节）
+func pbkdf2SHA256(password, salt []byte, iterations int) []byte {
+	mac := hmac.New(sha256.New, password)
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1})
+	u := mac.Sum(nil)
+	result := append([]byte(nil), u...)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+	return result
+}
@@ -0,0 +1,37 @@
+package gclog
+
+import "time"
+
+//UseDevelopmentDefaults 使用开发环境的默认配置
+//终端彩色输出、debug级别、完整的调用者路径、不采样、warning以上附带调用栈、1小时切分日志、日志保存1天
+func UseDevelopmentDefaults() {
+	SetEncoder(&ConsoleEncoder{})
+	SetLogLevel(DebugLevel)
+	SetCallerEnabled(true)
+	SetCallerFormat(CallerFull)
+	SetSampling(0, 0)
+	SetStackTraceLevel(WarningLevel)
+	DisableResourceFields()
+	DisableBuildInfoFields()
+	SetHexDumpLimit(0)
+	SetLogSliceInterval(time.Hour)
+	SetLogStorageTime(24 * time.Hour)
+}
+
+//UseProductionDefaults 使用生产环境的默认配置
+//文本格式、info级别、只输出调用者文件名、每秒同一日志超过100条后采样1%（error以上不采样）、error以上附带调用栈、
+//附带主机名/pid/服务名及构建信息、1天切分日志、日志保存7天
+//服务名默认为进程名，可以之后调用EnableResourceFields修改
+func UseProductionDefaults() {
+	SetEncoder(&TextEncoder{})
+	SetLogLevel(InfoLevel)
+	SetCallerEnabled(true)
+	SetCallerFormat(CallerShort)
+	SetSampling(100, 100)
+	SetStackTraceLevel(ErrorLevel)
+	EnableResourceFields("")
+	EnableBuildInfoFields()
+	SetHexDumpLimit(1024)
+	SetLogSliceInterval(24 * time.Hour)
+	SetLogStorageTime(7 * 24 * time.Hour)
+}
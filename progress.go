@@ -0,0 +1,76 @@
+package gclog
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+//Progress 长时间任务的进度日志，每处理everyN条或每隔every时间输出一次进度
+type Progress struct {
+	name    string        //任务名称
+	total   int64         //总数，<=0表示未知
+	everyN  int64         //每处理多少条输出一次，<=0表示不按条数输出
+	every   time.Duration //每隔多久输出一次，<=0表示不按时间输出
+	lock    sync.Mutex    //计数锁
+	done    int64         //已处理数
+	start   time.Time     //开始时间
+	lastLog time.Time     //上次输出时间
+	lastN   int64         //上次输出时的已处理数
+}
+
+//NewProgress 创建进度日志
+//exp:
+//	p := gclog.NewProgress("import users", 100000, 10000, time.Minute)
+//	for ... { p.Add(1) }
+//	p.Finish()
+//输出: import users processed 10000/100000 (10%) eta 3m0s
+func NewProgress(name string, total int64, everyN int64, every time.Duration) *Progress {
+	now := time.Now()
+	return &Progress{name: name, total: total, everyN: everyN, every: every, start: now, lastLog: now}
+}
+
+//Add 增加已处理数，达到输出条件时输出进度
+func (p *Progress) Add(n int64) {
+	p.lock.Lock()
+	p.done += n
+	now := time.Now()
+	if (p.everyN > 0 && p.done-p.lastN >= p.everyN) || (p.every > 0 && now.Sub(p.lastLog) >= p.every) {
+		p.lastN = p.done
+		p.lastLog = now
+		msg := p.format(now)
+		p.lock.Unlock()
+		if levelEnabled(InfoLevel) {
+			writeLog(InfoLevel, msg)
+		}
+		return
+	}
+	p.lock.Unlock()
+}
+
+//Finish 输出最终的处理数和总耗时
+func (p *Progress) Finish() {
+	p.lock.Lock()
+	msg := fmt.Sprintf("%s finished, processed %d in %s", p.name, p.done, time.Since(p.start).Round(time.Millisecond))
+	p.lock.Unlock()
+	if levelEnabled(InfoLevel) {
+		writeLog(InfoLevel, msg)
+	}
+}
+
+//format 生成进度文本，需要在持有锁时调用
+func (p *Progress) format(now time.Time) string {
+	if p.total <= 0 {
+		return fmt.Sprintf("%s processed %d", p.name, p.done)
+	}
+	percent := float64(p.done) * 100 / float64(p.total)
+	eta := "unknown"
+	if p.done > 0 && p.done < p.total {
+		elapsed := now.Sub(p.start)
+		remain := time.Duration(float64(elapsed) / float64(p.done) * float64(p.total-p.done))
+		eta = remain.Round(time.Second).String()
+	} else if p.done >= p.total {
+		eta = "0s"
+	}
+	return fmt.Sprintf("%s processed %d/%d (%.0f%%) eta %s", p.name, p.done, p.total, percent, eta)
+}
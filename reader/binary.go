@@ -0,0 +1,199 @@
+package reader
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/bailiyang/gclog"
+)
+
+var errBadBinary = errors.New("reader: invalid binary entry")
+
+//ParseBinary 解析gclog.BinaryEncoder输出的一条日志，record为包括首字节与末尾换行的完整内容
+//整数字段为int64（超出范围时为uint64），浮点数为float64，时间为time.Time
+func ParseBinary(record []byte) (*gclog.Entry, error) {
+	if len(record) < 2 || record[0] != gclog.BinaryMarker || record[len(record)-1] != '\n' {
+		return nil, errBadBinary
+	}
+	size, k := binary.Uvarint(record[1:])
+	if k <= 0 || uint64(len(record)-2-k) != size {
+		return nil, errBadBinary
+	}
+	d := &cborDecoder{data: record[1+k : len(record)-1]}
+	major, n, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	if major != 5 {
+		return nil, errBadBinary
+	}
+	e := &gclog.Entry{}
+	hasLevel := false
+	for ; n > 0; n-- {
+		key, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		k, ok := key.(int64)
+		if !ok {
+			return nil, errBadBinary
+		}
+		switch k {
+		case gclog.BinaryKeyTime:
+			ns, _ := value.(int64)
+			e.Time = time.Unix(0, ns)
+		case gclog.BinaryKeyLevel:
+			level, _ := value.(int64)
+			e.Level, hasLevel = int(level), true
+		case gclog.BinaryKeyMsg:
+			e.Message, _ = value.(string)
+		case gclog.BinaryKeyFile:
+			e.File, _ = value.(string)
+		case gclog.BinaryKeyLine:
+			line, _ := value.(int64)
+			e.Line = int(line)
+		case gclog.BinaryKeyFields:
+			fields, _ := value.([]gclog.Field)
+			e.Fields = fields
+		case gclog.BinaryKeyStack:
+			e.Stack, _ = value.(string)
+		case gclog.BinaryKeySeq:
+			switch seq := value.(type) {
+			case int64:
+				e.Seq = uint64(seq)
+			case uint64:
+				e.Seq = seq
+			}
+		case gclog.BinaryKeyID:
+			e.ID, _ = value.(string)
+		case gclog.BinaryKeyLogger:
+			e.Logger, _ = value.(string)
+		}
+	}
+	if !hasLevel || len(d.data) != 0 {
+		return nil, errBadBinary
+	}
+	return e, nil
+}
+
+//cborDecoder 解码BinaryEncoder使用的CBOR子集
+type cborDecoder struct {
+	data []byte
+}
+
+//head 解码数据项的头部，返回主类型与值或长度，浮点数返回原始的位
+func (d *cborDecoder) head() (byte, uint64, error) {
+	if len(d.data) == 0 {
+		return 0, 0, errBadBinary
+	}
+	major, info := d.data[0]>>5, d.data[0]&0x1f
+	d.data = d.data[1:]
+	var size int
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, 0, errBadBinary
+	}
+	if len(d.data) < size {
+		return 0, 0, errBadBinary
+	}
+	var n uint64
+	for _, c := range d.data[:size] {
+		n = n<<8 | uint64(c)
+	}
+	d.data = d.data[size:]
+	return major, n, nil
+}
+
+//value 解码一个数据项，字段map解码为[]gclog.Field以保持顺序
+func (d *cborDecoder) value() (interface{}, error) {
+	if len(d.data) == 0 {
+		return nil, errBadBinary
+	}
+	info := d.data[0] & 0x1f
+	major, n, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case 0:
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case 1:
+		if n > math.MaxInt64 {
+			return nil, errBadBinary
+		}
+		return -1 - int64(n), nil
+	case 2, 3:
+		if uint64(len(d.data)) < n {
+			return nil, errBadBinary
+		}
+		b := d.data[:n]
+		d.data = d.data[n:]
+		if major == 2 {
+			return append([]byte(nil), b...), nil
+		}
+		return string(b), nil
+	case 5:
+		var fields []gclog.Field
+		for ; n > 0; n-- {
+			key, err := d.value()
+			if err != nil {
+				return nil, err
+			}
+			s, ok := key.(string)
+			if !ok {
+				return nil, errBadBinary
+			}
+			value, err := d.value()
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, gclog.F(s, value))
+		}
+		return fields, nil
+	case 6:
+		//tag 0：RFC3339时间字符串
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		s, ok := value.(string)
+		if n != 0 || !ok {
+			return nil, errBadBinary
+		}
+		return time.Parse(time.RFC3339Nano, s)
+	case 7:
+		switch info {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22:
+			return nil, nil
+		case 26:
+			return float64(math.Float32frombits(uint32(n))), nil
+		case 27:
+			return math.Float64frombits(n), nil
+		}
+	}
+	return nil, fmt.Errorf("reader: unsupported binary item 0x%x", major<<5|info)
+}
@@ -0,0 +1,68 @@
+package reader
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"time"
+
+	"github.com/bailiyang/gclog"
+)
+
+//Index 日志文件的时间索引（见gclog.SetTimeIndex），记录某个偏移之前的日志都在某个时间之前写入
+type Index struct {
+	times   []int64
+	offsets []int64
+}
+
+//ReadIndex 读取日志文件对应的时间索引文件，末尾不完整的记录忽略
+func ReadIndex(logName string) (*Index, error) {
+	data, err := os.ReadFile(logName + gclog.TimeIndexSuffix)
+	if err != nil {
+		return nil, err
+	}
+	x := &Index{}
+	for len(data) >= gclog.TimeIndexRecordSize {
+		x.times = append(x.times, int64(binary.BigEndian.Uint64(data[:8])))
+		x.offsets = append(x.offsets, int64(binary.BigEndian.Uint64(data[8:])))
+		data = data[gclog.TimeIndexRecordSize:]
+	}
+	return x, nil
+}
+
+//Offset 返回读取t及之后的日志时可以跳过的最大偏移，之前的日志都早于t
+//多进程写入时记录不一定有序，因此检查全部记录
+func (x *Index) Offset(t time.Time) int64 {
+	n := t.UnixNano()
+	var offset int64
+	for i, recorded := range x.times {
+		if recorded < n && x.offsets[i] > offset {
+			offset = x.offsets[i]
+		}
+	}
+	return offset
+}
+
+//SeekTime 根据时间索引将日志文件定位到t之前最近的位置，之后用NewReader读取并筛选
+//没有索引文件或索引与文件不符（偏移超过文件大小）时定位到开头，返回定位到的偏移
+//exp:
+//
+//	f, _ := os.Open("test.log")
+//	reader.SeekTime(f, since)
+//	r := reader.NewReader(f)
+func SeekTime(f *os.File, t time.Time) (int64, error) {
+	var offset int64
+	if x, err := ReadIndex(f.Name()); err == nil {
+		offset = x.Offset(t)
+	}
+	if offset > 0 {
+		info, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		if offset > info.Size() {
+			offset = 0
+		}
+	}
+	return f.Seek(offset, io.SeekStart)
+}
@@ -0,0 +1,105 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bailiyang/gclog"
+)
+
+var errNotObject = errors.New("reader: json line is not an object")
+
+//ParseJSON 解析JSONEncoder输出的一行日志，字段按原来的顺序保存，数字字段为json.Number
+func ParseJSON(line []byte) (*gclog.Entry, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return nil, errNotObject
+	}
+	e := &gclog.Entry{}
+	hasLevel := false
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		switch key {
+		case "time":
+			if e.Time, err = parseJSONTime(value); err != nil {
+				return nil, err
+			}
+			continue
+		case "level":
+			name, _ := value.(string)
+			if e.Level, hasLevel = gclog.ParseLevel(name); !hasLevel {
+				return nil, fmt.Errorf("reader: unknown level %q", name)
+			}
+			continue
+		case "caller":
+			if s, ok := value.(string); ok {
+				setCaller(e, s)
+				continue
+			}
+		case "msg":
+			if s, ok := value.(string); ok {
+				e.Message = s
+				continue
+			}
+		case gclog.SeqField:
+			if n, ok := value.(json.Number); ok {
+				if seq, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+					e.Seq = seq
+					continue
+				}
+			}
+		case gclog.IDField:
+			if s, ok := value.(string); ok {
+				e.ID = s
+				continue
+			}
+		case gclog.LoggerField:
+			if s, ok := value.(string); ok {
+				e.Logger = s
+				continue
+			}
+		case "stack":
+			if s, ok := value.(string); ok {
+				e.Stack = s
+				continue
+			}
+		}
+		e.Fields = append(e.Fields, gclog.F(key, value))
+	}
+	if !hasLevel {
+		return nil, errors.New("reader: json line has no level")
+	}
+	return e, nil
+}
+
+//parseJSONTime 解析json中的时间，RFC3339字符串或unix毫秒时间戳
+func parseJSONTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case string:
+		return time.Parse(time.RFC3339Nano, v)
+	case json.Number:
+		ms, err := v.Int64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(0, ms*int64(time.Millisecond)), nil
+	}
+	return time.Time{}, fmt.Errorf("reader: invalid time %v", value)
+}
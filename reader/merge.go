@@ -0,0 +1,128 @@
+package reader
+
+import (
+	"container/heap"
+	"io"
+
+	"github.com/bailiyang/gclog"
+)
+
+//Merger 将多个按时间有序的日志流（切分出的多个文件、多个实例的文件）合并为一个按时间排序的流
+//时间相同时按序号（见gclog.EnableSequence）排序，仍相同时按参数顺序
+type Merger struct {
+	sources mergeHeap
+	raw     []byte
+	started bool
+	err     error
+}
+
+//mergeSource 一个待合并的日志流与它的下一条日志
+type mergeSource struct {
+	r     *Reader
+	index int //参数中的位置
+	entry *gclog.Entry
+	raw   []byte
+}
+
+//NewMerger 创建合并多个Reader的Merger
+//exp:
+//
+//	m := reader.NewMerger(reader.NewReader(f1), reader.NewReader(f2))
+//	for e, err := m.Next(); err == nil; e, err = m.Next() {
+//		os.Stdout.Write(m.Raw())
+//	}
+func NewMerger(readers ...*Reader) *Merger {
+	m := &Merger{}
+	for i, r := range readers {
+		m.sources = append(m.sources, &mergeSource{r: r, index: i})
+	}
+	return m
+}
+
+//Raw 返回上一条日志的原始内容，下一次调用Next后失效
+func (m *Merger) Raw() []byte {
+	return m.raw
+}
+
+//Next 返回时间最早的下一条日志，所有流都读完时返回io.EOF，任一流读取出错时返回该错误
+func (m *Merger) Next() (*gclog.Entry, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if !m.started {
+		m.started = true
+		sources := m.sources[:0]
+		for _, s := range m.sources {
+			ok, err := s.advance()
+			if err != nil {
+				m.err = err
+				return nil, err
+			}
+			if ok {
+				sources = append(sources, s)
+			}
+		}
+		m.sources = sources
+		heap.Init(&m.sources)
+	}
+	if len(m.sources) == 0 {
+		m.err = io.EOF
+		return nil, m.err
+	}
+	s := m.sources[0]
+	e := s.entry
+	m.raw = append(m.raw[:0], s.raw...)
+	ok, err := s.advance()
+	if err != nil {
+		m.err = err
+		return nil, err
+	}
+	if ok {
+		heap.Fix(&m.sources, 0)
+	} else {
+		heap.Pop(&m.sources)
+	}
+	return e, nil
+}
+
+//advance 读取流的下一条日志，流读完时返回false
+func (s *mergeSource) advance() (bool, error) {
+	e, err := s.r.Next()
+	if err == io.EOF {
+		s.entry = nil
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	s.entry = e
+	s.raw = append(s.raw[:0], s.r.Raw()...)
+	return true, nil
+}
+
+//mergeHeap 按日志时间排序的小顶堆
+type mergeHeap []*mergeSource
+
+func (h mergeHeap) Len() int { return len(h) }
+
+func (h mergeHeap) Less(i, j int) bool {
+	a, b := h[i].entry, h[j].entry
+	if !a.Time.Equal(b.Time) {
+		return a.Time.Before(b.Time)
+	}
+	if a.Seq != b.Seq {
+		return a.Seq < b.Seq
+	}
+	return h[i].index < h[j].index
+}
+
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(*mergeSource)) }
+
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	s := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return s
+}
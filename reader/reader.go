@@ -0,0 +1,284 @@
+//Package reader 解析gclog输出的日志文件（TextEncoder、JSONEncoder与BinaryEncoder格式），还原为gclog.Entry，用于日志分析与命令行工具
+//exp:
+//
+//	r := reader.NewReader(f)
+//	for {
+//		e, err := r.Next()
+//		if err == io.EOF {
+//			break
+//		}
+//		if e.Level >= gclog.ErrorLevel {
+//			fmt.Println(e.Time, e.Message)
+//		}
+//	}
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bailiyang/gclog"
+)
+
+//maxLineSize 单行日志的最大长度
+const maxLineSize = 16 << 20
+
+//Reader 按条读取日志，文本格式中消息与调用栈的续行归入同一条日志，每条日志自动识别格式
+type Reader struct {
+	br       *bufio.Reader
+	line     []byte         //readLine的缓冲区
+	header   []headerPart   //文本格式的日志头模板
+	layout   string         //文本格式的时间格式
+	location *time.Location //文本格式时间所在的时区
+	pending  []byte         //已读取、属于下一条日志的首行
+	raw      []byte         //上一条日志的原始内容
+	skipped  int            //第一条日志之前无法解析的行数
+	err      error
+}
+
+//NewReader 创建Reader，默认按gclog的默认日志头模板与时间格式解析，时间按本地时区解析
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		br:       bufio.NewReaderSize(r, 64<<10),
+		header:   mustParseHeader(defaultHeader),
+		layout:   defaultLayout,
+		location: time.Local,
+	}
+}
+
+//SetHeaderTemplate 设置文本格式的日志头模板，与写日志时gclog.SetHeaderTemplate的设置相同，为空时使用默认模板
+func (r *Reader) SetHeaderTemplate(tpl string) error {
+	if tpl == "" {
+		tpl = defaultHeader
+	}
+	h, err := parseHeader(tpl)
+	if err != nil {
+		return err
+	}
+	r.header = h
+	return nil
+}
+
+//SetTimeLayout 设置文本格式的时间格式，与写日志时gclog.SetTimeLayout的设置相同，为空时使用默认格式
+//秒之后的小数部分不需要写在格式中，解析时自动识别
+func (r *Reader) SetTimeLayout(layout string) {
+	if layout == "" {
+		layout = defaultLayout
+	}
+	r.layout = layout
+}
+
+//SetLocation 设置文本格式中不带时区的时间所在的时区，默认为本地时区
+func (r *Reader) SetLocation(loc *time.Location) {
+	r.location = loc
+}
+
+//Raw 返回上一条日志的原始内容（包括续行，以换行结尾；二进制格式为完整的一条记录），下一次调用Next后失效
+func (r *Reader) Raw() []byte {
+	return r.raw
+}
+
+//Skipped 返回第一条日志之前无法解析而跳过的行数，如文件从日志中间截断时的残余行
+func (r *Reader) Skipped() int {
+	return r.skipped
+}
+
+//Next 读取下一条日志，没有更多日志时返回io.EOF
+func (r *Reader) Next() (*gclog.Entry, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	var e *gclog.Entry
+	line := r.pending
+	r.pending = nil
+	//找到一条日志的首行
+	for e == nil {
+		if line == nil {
+			if r.peekBinary() {
+				return r.nextBinary()
+			}
+			var err error
+			if line, err = r.readLine(); err != nil {
+				return nil, r.setErr(err)
+			}
+		}
+		e = r.parseFirstLine(line)
+		if e == nil {
+			r.skipped++
+			line = nil
+		}
+	}
+	r.raw = append(append(r.raw[:0], line...), '\n')
+	if line[0] == '{' {
+		return e, nil
+	}
+
+	//文本格式，之后不能解析为日志首行的都是续行
+	var msg strings.Builder
+	var stack strings.Builder
+	inStack := false
+	msg.WriteString(e.Message)
+	for !r.peekBinary() {
+		next, err := r.readLine()
+		if err != nil {
+			if err != io.EOF {
+				r.err = err
+			}
+			break
+		}
+		if r.parseFirstLine(next) != nil {
+			r.pending = append([]byte(nil), next...)
+			break
+		}
+		r.raw = append(append(r.raw, next...), '\n')
+		if inStack {
+			stack.Write(next)
+			stack.WriteByte('\n')
+		} else if string(next) == "stack:" {
+			inStack = true
+		} else {
+			msg.WriteByte('\n')
+			msg.Write(next)
+		}
+	}
+	e.Message = msg.String()
+	e.Stack = stack.String()
+	parseTextFields(e)
+	return e, nil
+}
+
+//setErr 读取结束时记录错误，正常结束为io.EOF
+func (r *Reader) setErr(err error) error {
+	r.err = err
+	return r.err
+}
+
+//readLine 读取一行，去掉行尾的换行，返回的内容在下一次调用前有效，超过maxLineSize时返回bufio.ErrTooLong
+func (r *Reader) readLine() ([]byte, error) {
+	r.line = r.line[:0]
+	for {
+		chunk, err := r.br.ReadSlice('\n')
+		if len(r.line)+len(chunk) > maxLineSize {
+			return nil, bufio.ErrTooLong
+		}
+		r.line = append(r.line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && (err != io.EOF || len(r.line) == 0) {
+			return nil, err
+		}
+		break
+	}
+	line := bytes.TrimSuffix(r.line, []byte{'\n'})
+	return bytes.TrimSuffix(line, []byte{'\r'}), nil
+}
+
+//peekBinary 判断下一条日志是否为二进制格式
+func (r *Reader) peekBinary() bool {
+	b, err := r.br.Peek(1)
+	return err == nil && b[0] == gclog.BinaryMarker
+}
+
+//nextBinary 读取一条二进制格式的日志，内容无法解析时跳过该条
+func (r *Reader) nextBinary() (*gclog.Entry, error) {
+	for {
+		r.raw = r.raw[:0]
+		marker, err := r.br.ReadByte()
+		if err != nil {
+			return nil, r.setErr(err)
+		}
+		r.raw = append(r.raw, marker)
+		size, err := binary.ReadUvarint(byteRecorder{r})
+		if err == nil && size > maxLineSize {
+			err = bufio.ErrTooLong
+		}
+		if err != nil {
+			return nil, r.setErr(unexpectedEOF(err))
+		}
+		start := len(r.raw)
+		r.raw = append(r.raw, make([]byte, size+1)...)
+		if _, err := io.ReadFull(r.br, r.raw[start:]); err != nil {
+			return nil, r.setErr(unexpectedEOF(err))
+		}
+		e, err := ParseBinary(r.raw)
+		if err == nil {
+			return e, nil
+		}
+		r.skipped++
+		if !r.peekBinary() {
+			//之后是文本格式的日志
+			return r.Next()
+		}
+	}
+}
+
+//byteRecorder 读取字节的同时记录到Raw
+type byteRecorder struct {
+	r *Reader
+}
+
+//ReadByte 实现io.ByteReader
+func (b byteRecorder) ReadByte() (byte, error) {
+	c, err := b.r.br.ReadByte()
+	if err == nil {
+		b.r.raw = append(b.r.raw, c)
+	}
+	return c, err
+}
+
+//unexpectedEOF 二进制日志中途结束时返回io.ErrUnexpectedEOF
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+//parseFirstLine 将一行解析为日志的首行，不是日志首行时返回nil
+//文本格式只解析日志头，消息中的字段在合并续行后解析
+func (r *Reader) parseFirstLine(line []byte) *gclog.Entry {
+	line = bytes.TrimRight(line, "\r")
+	if len(line) == 0 {
+		return nil
+	}
+	if line[0] == '{' {
+		e, err := ParseJSON(line)
+		if err != nil {
+			return nil
+		}
+		return e
+	}
+	e := &gclog.Entry{}
+	rest, ok := r.parseHeader(string(line), e)
+	if !ok {
+		return nil
+	}
+	e.Message = rest
+	return e
+}
+
+//ParseLine 解析单行日志，自动识别json与默认模板的文本格式，文本格式的消息中不能有换行
+func ParseLine(line []byte) (*gclog.Entry, error) {
+	r := NewReader(bytes.NewReader(line))
+	return r.Next()
+}
+
+//setCaller 将"file:line"形式的调用者位置写入Entry，"-"表示没有调用者位置
+func setCaller(e *gclog.Entry, caller string) {
+	if caller == "" || caller == "-" {
+		return
+	}
+	if i := strings.LastIndexByte(caller, ':'); i > 0 {
+		if n, err := strconv.Atoi(caller[i+1:]); err == nil {
+			e.File, e.Line = caller[:i], n
+			return
+		}
+	}
+	e.File = caller
+}
@@ -0,0 +1,236 @@
+package reader
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bailiyang/gclog"
+)
+
+const (
+	//defaultHeader gclog默认的日志头模板
+	defaultHeader = "{{level}} {{time}} {{caller}}: {{level}}"
+	//defaultLayout TextEncoder默认的时间格式
+	defaultLayout = "2006/01/02 15:04:05"
+)
+
+//headerPart 日志头模板中的一段，placeholder为空时表示字面文本
+type headerPart struct {
+	literal     string
+	placeholder string
+}
+
+//parseHeader 解析日志头模板，支持的占位符与gclog.SetHeaderTemplate相同
+func parseHeader(tpl string) ([]headerPart, error) {
+	var parts []headerPart
+	for len(tpl) > 0 {
+		start := strings.Index(tpl, "{{")
+		if start < 0 {
+			parts = append(parts, headerPart{literal: tpl})
+			break
+		}
+		if start > 0 {
+			parts = append(parts, headerPart{literal: tpl[:start]})
+		}
+		end := strings.Index(tpl[start:], "}}")
+		if end < 0 {
+			return nil, fmt.Errorf("header template: unclosed placeholder at %q", tpl[start:])
+		}
+		name := strings.TrimSpace(tpl[start+2 : start+end])
+		switch name {
+		case "time", "level", "levelname", "caller", "pid", "seq", "id", "logger":
+		default:
+			return nil, fmt.Errorf("header template: unknown placeholder {{%s}}", name)
+		}
+		parts = append(parts, headerPart{placeholder: name})
+		tpl = tpl[start+end+2:]
+	}
+	return parts, nil
+}
+
+//mustParseHeader 解析内置模板，失败时panic
+func mustParseHeader(tpl string) []headerPart {
+	h, err := parseHeader(tpl)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+//parseHeader 按模板解析日志头，返回日志头之后的内容
+//占位符的值不含空格（时间按格式中的空格数取多段），值之后没有空格分隔的字面文本从值的末尾去掉
+func (r *Reader) parseHeader(line string, e *gclog.Entry) (string, bool) {
+	skip := 0 //下一段字面文本中已随上一个值匹配的长度
+	for i, p := range r.header {
+		if p.placeholder == "" {
+			//级别补齐的空格不做严格匹配
+			lit := strings.TrimLeft(p.literal[skip:], " ")
+			line = strings.TrimLeft(line, " ")
+			if !strings.HasPrefix(line, lit) {
+				return "", false
+			}
+			line = line[len(lit):]
+			skip = 0
+			continue
+		}
+		tokens := 1
+		if p.placeholder == "time" && r.layout != gclog.TimeEpochMillis {
+			tokens += strings.Count(r.layout, " ")
+		}
+		value, rest := cutTokens(line, tokens)
+		if i+1 < len(r.header) {
+			attached := r.header[i+1].literal
+			if j := strings.IndexByte(attached, ' '); j >= 0 {
+				attached = attached[:j]
+			}
+			if !strings.HasSuffix(value, attached) {
+				return "", false
+			}
+			value = value[:len(value)-len(attached)]
+			skip = len(attached)
+		}
+		if value == "" || !r.setHeaderValue(e, p.placeholder, value) {
+			return "", false
+		}
+		line = rest
+	}
+	return strings.TrimPrefix(line, " "), true
+}
+
+//cutTokens 取line开头以空格分隔的n段，返回这n段与之后的内容
+func cutTokens(line string, n int) (string, string) {
+	end := 0
+	for ; n > 0; n-- {
+		j := strings.IndexByte(line[end:], ' ')
+		if j < 0 {
+			return line, ""
+		}
+		end += j
+		if n > 1 {
+			end++
+		}
+	}
+	return line[:end], line[end:]
+}
+
+//setHeaderValue 将日志头中占位符的值写入Entry，值不合法时返回false
+func (r *Reader) setHeaderValue(e *gclog.Entry, placeholder, value string) bool {
+	switch placeholder {
+	case "time":
+		t, ok := r.parseTime(value)
+		e.Time = t
+		return ok
+	case "level":
+		if len(value) < 3 || value[0] != '[' || value[len(value)-1] != ']' {
+			return false
+		}
+		level, ok := gclog.ParseLevel(value[1 : len(value)-1])
+		e.Level = level
+		return ok
+	case "levelname":
+		level, ok := gclog.ParseLevel(value)
+		e.Level = level
+		return ok
+	case "caller":
+		setCaller(e, value)
+	case "seq":
+		n, err := strconv.ParseUint(value, 10, 64)
+		e.Seq = n
+		return err == nil
+	case "id":
+		e.ID = value
+	case "logger":
+		e.Logger = value
+	}
+	return true
+}
+
+//parseTime 按时间格式解析时间，支持unix毫秒时间戳
+func (r *Reader) parseTime(value string) (time.Time, bool) {
+	if r.layout == gclog.TimeEpochMillis {
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Unix(0, ms*int64(time.Millisecond)), true
+	}
+	t, err := time.ParseInLocation(r.layout, value, r.location)
+	return t, err == nil
+}
+
+//parseTextFields 从消息末尾解析key=value形式的字段，seq、id、logger写入Entry对应的成员
+//消息本身可能含有空格与=，取能完整解析为字段序列的最长后缀
+func parseTextFields(e *gclog.Entry) {
+	msg := e.Message
+	for i := 0; i < len(msg); i++ {
+		if msg[i] != ' ' {
+			continue
+		}
+		fields, ok := parseFieldList(msg[i+1:])
+		if !ok {
+			continue
+		}
+		e.Message = msg[:i]
+		for _, f := range fields {
+			switch f.Key {
+			case gclog.SeqField:
+				if n, err := strconv.ParseUint(f.Value.(string), 10, 64); err == nil {
+					e.Seq = n
+					continue
+				}
+			case gclog.IDField:
+				e.ID = f.Value.(string)
+				continue
+			case gclog.LoggerField:
+				e.Logger = f.Value.(string)
+				continue
+			}
+			e.Fields = append(e.Fields, f)
+		}
+		return
+	}
+}
+
+//parseFieldList 将s完整解析为以空格分隔的key=value序列，值可以是go的带引号字符串
+func parseFieldList(s string) ([]gclog.Field, bool) {
+	var fields []gclog.Field
+	for len(s) > 0 {
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 || strings.ContainsAny(s[:eq], " \t\r\n\"") {
+			return nil, false
+		}
+		key := s[:eq]
+		s = s[eq+1:]
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			quoted, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, false
+			}
+			if value, err = strconv.Unquote(quoted); err != nil {
+				return nil, false
+			}
+			s = s[len(quoted):]
+		} else {
+			end := strings.IndexAny(s, " \t\r\n\"=")
+			if end < 0 {
+				end = len(s)
+			}
+			if end == 0 {
+				return nil, false
+			}
+			value, s = s[:end], s[end:]
+		}
+		fields = append(fields, gclog.F(key, value))
+		if len(s) == 0 {
+			break
+		}
+		if s[0] != ' ' || len(s) == 1 {
+			return nil, false
+		}
+		s = s[1:]
+	}
+	return fields, len(fields) > 0
+}
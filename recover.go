@@ -0,0 +1,57 @@
+package gclog
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+//panicRethrow Recover记录panic后是否继续向上抛出
+var panicRethrow bool
+
+//SetPanicRethrow 设置Recover记录panic后是否重新panic，默认false，即记录后恢复执行
+func SetPanicRethrow(rethrow bool) {
+	panicRethrow = rethrow
+}
+
+//Recover 捕获panic并以error级别记录panic的值和调用栈，必须直接用在defer中
+//exp: defer gclog.Recover()
+func Recover() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	msg := fmt.Sprintf("panic: %v", r)
+	//Recover的调用者是runtime的panic处理，调用者位置取panic发生处
+	pcs := callers(2)
+	//writeLog会附加调用栈时不重复输出
+	if !needStackTrace(ErrorLevel) {
+		msg += "\nstack:\n" + formatFrames(pcs)
+	}
+	writeLogAt(ErrorLevel, msg, pcs)
+	if panicRethrow {
+		panic(r)
+	}
+}
+
+//Go 启动一个带panic捕获的goroutine，保证后台goroutine的崩溃信息一定会记录到日志中
+func Go(f func()) {
+	go func() {
+		defer Recover()
+		f()
+	}()
+}
+
+//panicFrame 返回pcs中第一个不属于runtime的帧的文件与行号，即panic发生处，没有时返回空
+func panicFrame(pcs []uintptr) (string, int) {
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" && !strings.HasPrefix(frame.Function, "runtime.") {
+			return frame.File, frame.Line
+		}
+		if !more {
+			return "", 0
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package gclog
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+//RedactedValue 字段脱敏后的默认值
+const RedactedValue = "[REDACTED]"
+
+//Redactable 实现该接口的字段值在输出时使用Redacted的返回值，用于密码、令牌等自定义类型
+type Redactable interface {
+	Redacted() interface{}
+}
+
+//Redactor 字段脱敏函数，返回输出的值
+type Redactor func(value interface{}) interface{}
+
+//redactConfig 字段脱敏配置，修改时整体替换
+type redactConfig struct {
+	names map[string]Redactor       //按字段名（小写）脱敏
+	types map[reflect.Type]Redactor //按值的类型脱敏
+}
+
+var (
+	redactLock = new(sync.Mutex) //修改配置时加锁
+	redactConf atomic.Value      //当前的字段脱敏配置，redactConfig，写日志时无锁读取
+)
+
+//RedactFields 将指定名称的字段（不区分大小写）输出为[REDACTED]
+//exp: gclog.RedactFields("password", "token", "secret")
+func RedactFields(names ...string) {
+	for _, name := range names {
+		SetFieldRedactor(name, func(interface{}) interface{} { return RedactedValue })
+	}
+}
+
+//SetFieldRedactor 设置指定名称字段（不区分大小写）的脱敏函数，f为nil时取消
+func SetFieldRedactor(name string, f Redactor) {
+	updateRedactConfig(func(c *redactConfig) {
+		if f == nil {
+			delete(c.names, strings.ToLower(name))
+		} else {
+			c.names[strings.ToLower(name)] = f
+		}
+	})
+}
+
+//SetTypeRedactor 设置与sample类型相同的字段值的脱敏函数，f为nil时取消
+//exp: gclog.SetTypeRedactor(Card{}, func(v interface{}) interface{} { return v.(Card).Last4() })
+func SetTypeRedactor(sample interface{}, f Redactor) {
+	t := reflect.TypeOf(sample)
+	updateRedactConfig(func(c *redactConfig) {
+		if f == nil {
+			delete(c.types, t)
+		} else {
+			c.types[t] = f
+		}
+	})
+}
+
+//updateRedactConfig 复制当前配置，修改后替换
+func updateRedactConfig(update func(c *redactConfig)) {
+	redactLock.Lock()
+	defer redactLock.Unlock()
+	old := loadRedactConfig()
+	c := redactConfig{names: make(map[string]Redactor, len(old.names)), types: make(map[reflect.Type]Redactor, len(old.types))}
+	for k, v := range old.names {
+		c.names[k] = v
+	}
+	for k, v := range old.types {
+		c.types[k] = v
+	}
+	update(&c)
+	redactConf.Store(c)
+}
+
+//loadRedactConfig 取当前的字段脱敏配置
+func loadRedactConfig() redactConfig {
+	c, _ := redactConf.Load().(redactConfig)
+	return c
+}
+
+//redactFields 对字段脱敏，有字段被修改时返回新的切片，不修改调用方的切片
+func redactFields(fields []Field) []Field {
+	if len(fields) == 0 {
+		return fields
+	}
+	conf := loadRedactConfig()
+	var out []Field
+	for i, f := range fields {
+		v, ok := redactValue(conf, f)
+		if !ok {
+			continue
+		}
+		if out == nil {
+			out = make([]Field, len(fields))
+			copy(out, fields)
+		}
+		out[i].Value = v
+	}
+	if out == nil {
+		return fields
+	}
+	return out
+}
+
+//redactValue 按字段名、Redactable接口、类型的顺序查找脱敏方式，不需要脱敏时返回false
+func redactValue(conf redactConfig, f Field) (interface{}, bool) {
+	if len(conf.names) > 0 {
+		if r, ok := conf.names[strings.ToLower(f.Key)]; ok {
+			return r(f.Value), true
+		}
+	}
+	if r, ok := f.Value.(Redactable); ok {
+		return r.Redacted(), true
+	}
+	if len(conf.types) > 0 && f.Value != nil {
+		if r, ok := conf.types[reflect.TypeOf(f.Value)]; ok {
+			return r(f.Value), true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,343 @@
+package gclog
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//redisMaxBatch 一次流水线发送的最多命令数
+const redisMaxBatch = 256
+
+var errRedisProtocol = errors.New("gclog: unexpected redis reply")
+
+//redisError redis返回的错误回复，连接仍然可用
+type redisError string
+
+func (e redisError) Error() string {
+	return "gclog: redis " + string(e)
+}
+
+//RedisOptions Redis Streams sink的设置
+type RedisOptions struct {
+	TLS      *TLSOptions //不为nil时使用TLS连接
+	Username string      //ACL用户名，为空时只用Password认证
+	Password string
+	DB       int //数据库编号
+	//MaxLen stream保留的大约条数，XADD时以MAXLEN ~裁剪，<=0时默认10000
+	MaxLen       int64
+	QueueSize    int           //发送队列长度，队列满时丢弃日志，<=0时默认4096
+	DialTimeout  time.Duration //连接超时，<=0时默认5s
+	WriteTimeout time.Duration //读写超时，<=0时默认5s
+}
+
+//RedisSink 将日志XADD到Redis stream的sink，不需要额外的基础设施即可保留并查询最近的日志
+//每条日志为一个stream条目，成员为time（RFC3339）、level、msg、caller、logger、id、stack及各字段
+//日志在Write时编码为命令放入队列，由后台goroutine以流水线批量发送，断线后自动重连
+//exp: redis-cli XREVRANGE logs:app + - COUNT 10
+type RedisSink struct {
+	addr      string
+	key       string
+	opts      RedisOptions
+	tlsConfig *tls.Config
+	queue     chan *buffer
+	done      chan struct{}
+	closeOnce sync.Once
+	conn      net.Conn      //只在发送goroutine中使用
+	r         *bufio.Reader //conn的读缓冲，只在发送goroutine中使用
+	redial    redial        //重连间隔，只在发送goroutine中使用
+	dropped   uint64        //丢弃的日志数，原子读写
+	sent      uint64        //发送成功的日志数，原子读写
+}
+
+//NewRedisSink 创建写入stream key的Redis sink，addr为host:port
+//exp: s, err := gclog.NewRedisSink("127.0.0.1:6379", "logs:app", gclog.RedisOptions{MaxLen: 100000})
+func NewRedisSink(addr, key string, opts RedisOptions) (*RedisSink, error) {
+	if key == "" {
+		return nil, errors.New("gclog: empty redis stream key")
+	}
+	if opts.MaxLen <= 0 {
+		opts.MaxLen = 10000
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 4096
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 5 * time.Second
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = 5 * time.Second
+	}
+	s := &RedisSink{
+		addr:  addr,
+		key:   key,
+		opts:  opts,
+		queue: make(chan *buffer, opts.QueueSize),
+		done:  make(chan struct{}),
+	}
+	if opts.TLS != nil {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		if s.tlsConfig, err = opts.TLS.config(host); err != nil {
+			return nil, err
+		}
+	}
+	go s.run()
+	return s, nil
+}
+
+//SendToRedis 创建Redis sink并注册，同一地址与key重复调用时替换之前的sink
+//exp: gclog.SendToRedis("127.0.0.1:6379", "logs:app", gclog.RedisOptions{})
+func SendToRedis(addr, key string, opts RedisOptions) (*RedisSink, error) {
+	s, err := NewRedisSink(addr, key, opts)
+	if err != nil {
+		return nil, err
+	}
+	if old := addSink("redis:"+addr+"/"+key, s); old != nil {
+		closeSink(old)
+	}
+	return s, nil
+}
+
+//Write 实现Sink，将日志编码为XADD命令放入发送队列，队列满时丢弃并返回错误
+func (s *RedisSink) Write(e *Entry, line []byte) error {
+	buf := getBuffer()
+	buf.b = s.appendXAdd(buf.b, e)
+	select {
+	case s.queue <- buf:
+		return nil
+	default:
+		putBuffer(buf)
+		atomic.AddUint64(&s.dropped, 1)
+		return errSinkFull
+	}
+}
+
+//Close 实现Sink，发送队列中剩余的日志后关闭连接
+func (s *RedisSink) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.queue)
+	})
+	<-s.done
+	return nil
+}
+
+//Dropped 返回因队列满、发送失败或redis返回错误丢弃的日志数
+func (s *RedisSink) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+//Sent 返回写入成功的日志数
+func (s *RedisSink) Sent() uint64 {
+	return atomic.LoadUint64(&s.sent)
+}
+
+//appendXAdd 追加RESP编码的XADD命令
+func (s *RedisSink) appendXAdd(dst []byte, e *Entry) []byte {
+	args := []string{"XADD", s.key, "MAXLEN", "~", strconv.FormatInt(s.opts.MaxLen, 10), "*",
+		"time", e.Time.Format(time.RFC3339Nano), "level", LevelName(e.Level), "msg", e.Message}
+	if e.File != "" {
+		args = append(args, "caller", formatCaller(e.File)+":"+strconv.Itoa(e.Line))
+	}
+	if e.Logger != "" {
+		args = append(args, LoggerField, e.Logger)
+	}
+	if e.ID != "" {
+		args = append(args, IDField, e.ID)
+	}
+	if e.Seq != 0 {
+		args = append(args, SeqField, strconv.FormatUint(e.Seq, 10))
+	}
+	for _, f := range e.Fields {
+		args = append(args, f.Key, formatCEFValue(f.Value))
+	}
+	if e.Stack != "" {
+		args = append(args, "stack", e.Stack)
+	}
+	return appendRESPCommand(dst, args...)
+}
+
+//appendRESPCommand 追加RESP编码的命令
+func appendRESPCommand(dst []byte, args ...string) []byte {
+	dst = append(dst, '*')
+	dst = strconv.AppendInt(dst, int64(len(args)), 10)
+	dst = append(dst, "\r\n"...)
+	for _, arg := range args {
+		dst = append(dst, '$')
+		dst = strconv.AppendInt(dst, int64(len(arg)), 10)
+		dst = append(dst, "\r\n"...)
+		dst = append(dst, arg...)
+		dst = append(dst, "\r\n"...)
+	}
+	return dst
+}
+
+//run 发送goroutine，阻塞等待第一条日志后合并队列中已有的日志，以流水线发送
+func (s *RedisSink) run() {
+	defer close(s.done)
+	var out []byte
+	for {
+		buf, ok := <-s.queue
+		if !ok {
+			break
+		}
+		n := 1
+		out = append(out[:0], buf.b...)
+		putBuffer(buf)
+	drain:
+		for n < redisMaxBatch {
+			select {
+			case buf, ok := <-s.queue:
+				if !ok {
+					break drain
+				}
+				out = append(out, buf.b...)
+				putBuffer(buf)
+				n++
+			default:
+				break drain
+			}
+		}
+		sent := s.send(out, n)
+		atomic.AddUint64(&s.sent, uint64(sent))
+		atomic.AddUint64(&s.dropped, uint64(n-sent))
+	}
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+//send 发送n条命令并读取回复，返回成功的条数，连接断开时重连后重试一次
+func (s *RedisSink) send(out []byte, n int) int {
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil && !s.dial() {
+			return 0
+		}
+		s.conn.SetDeadline(time.Now().Add(s.opts.WriteTimeout))
+		if _, err := s.conn.Write(out); err != nil {
+			s.drop()
+			continue
+		}
+		ok := 0
+		var err error
+		for i := 0; i < n; i++ {
+			if err = readRESP(s.r); err == nil {
+				ok++
+			} else if _, isReply := err.(redisError); !isReply {
+				break
+			}
+		}
+		if err != nil {
+			if _, isReply := err.(redisError); !isReply {
+				//回复没有读完，连接状态未知，已经写入的命令可能已执行，不重试
+				s.drop()
+			}
+		}
+		return ok
+	}
+	return 0
+}
+
+//drop 关闭出错的连接，下次发送时重连
+func (s *RedisSink) drop() {
+	s.conn.Close()
+	s.conn, s.r = nil, nil
+}
+
+//dial 建立连接，需要时认证并选择数据库，连接失败后在重连间隔内直接返回失败
+func (s *RedisSink) dial() bool {
+	if !s.redial.allow() {
+		return false
+	}
+	dialer := &net.Dialer{Timeout: s.opts.DialTimeout}
+	var conn net.Conn
+	var err error
+	if s.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.addr, s.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", s.addr)
+	}
+	if err != nil {
+		s.redial.failed()
+		return false
+	}
+	r := bufio.NewReader(conn)
+	var init []byte
+	n := 0
+	if s.opts.Password != "" {
+		if s.opts.Username != "" {
+			init = appendRESPCommand(init, "AUTH", s.opts.Username, s.opts.Password)
+		} else {
+			init = appendRESPCommand(init, "AUTH", s.opts.Password)
+		}
+		n++
+	}
+	if s.opts.DB != 0 {
+		init = appendRESPCommand(init, "SELECT", strconv.Itoa(s.opts.DB))
+		n++
+	}
+	if n > 0 {
+		conn.SetDeadline(time.Now().Add(s.opts.DialTimeout))
+		_, err = conn.Write(init)
+		for i := 0; i < n && err == nil; i++ {
+			err = readRESP(r)
+		}
+		if err != nil {
+			conn.Close()
+			s.redial.failed()
+			Warning("redis sink %s init failed, because %s", s.addr, err.Error())
+			return false
+		}
+	}
+	s.conn, s.r = conn, r
+	s.redial.reset()
+	return true
+}
+
+//readRESP 读取并丢弃一个回复，错误回复返回redisError
+func readRESP(r *bufio.Reader) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) == 0 {
+		return errRedisProtocol
+	}
+	switch line[0] {
+	case '+', ':':
+		return nil
+	case '-':
+		return redisError(line[1:])
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return errRedisProtocol
+		}
+		if size < 0 {
+			return nil
+		}
+		_, err = r.Discard(size + 2)
+		return err
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return errRedisProtocol
+		}
+		for i := 0; i < count; i++ {
+			if err := readRESP(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return errRedisProtocol
+}
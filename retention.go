@@ -0,0 +1,356 @@
+package gclog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//ArchivedManifestSuffix 记录已归档对象的清单文件后缀，每行为“上传时间(unix秒) 对象名”，exp: app.log.archived
+const ArchivedManifestSuffix = ".archived"
+
+//保留策略执行的操作
+const (
+	RetentionCompress     = "compress"      //将切分出的文件gzip压缩为.gz
+	RetentionArchive      = "archive"       //上传到归档目标并删除本地文件
+	RetentionDelete       = "delete"        //没有设置归档器时删除本地文件
+	RetentionDeleteRemote = "delete-remote" //删除归档目标中的对象
+)
+
+var (
+	retentionLock    = new(sync.Mutex) //修改策略、读写报告时加锁
+	retentionPolicy  *RetentionPolicy  //当前的保留策略，未设置时为nil，retentionLock保护
+	retentionLast    RetentionReport   //上一次执行的报告，retentionLock保护
+	retentionRunLock = new(sync.Mutex) //执行时加锁，RunRetention与切分循环不会同时执行
+	retentionRunning int32             //切分循环是否已启动执行，原子读写
+)
+
+//RetentionPolicy 分级保留策略，切分出的文件先保留原始文件，之后压缩，再上传到归档目标并删除本地文件，最后删除归档的对象
+//设置后代替SetLogStorageTime的按时间删除（主日志文件与RouteLevels的文件），切分时不再立即上传，由归档阶段上传
+//随日志切分循环的每次检查（默认30秒）执行一次，时间以文件的修改时间计算，超出SetLogRetentionLimits限制的最旧文件提前归档或删除
+type RetentionPolicy struct {
+	//KeepRaw 切分出的文件保持原始形式的时长，之后压缩，必须大于0
+	KeepRaw time.Duration
+	//KeepCompressed 压缩后保留在本地的时长，之后上传到SetArchiver设置的归档目标并删除本地文件，没有设置归档器时直接删除
+	//<=0时不压缩，KeepRaw之后直接归档或删除
+	KeepCompressed time.Duration
+	//KeepArchived 归档的对象保留的时长（从上传时算起），之后从归档目标删除，归档目标需要实现ArchiveDeleter
+	//<=0时不删除，可以交给对象存储的生命周期规则
+	KeepArchived time.Duration
+	//DryRun 为true时只生成报告与日志，不执行任何操作
+	DryRun bool
+}
+
+//RetentionAction 保留策略执行的一个操作
+type RetentionAction struct {
+	Action string //RetentionCompress等
+	Path   string //本地文件，RetentionDeleteRemote时为对象名
+	Size   int64  //文件大小，RetentionDeleteRemote时为0
+	Err    string //执行失败的原因，成功或DryRun时为空
+}
+
+//RetentionReport 一次执行的报告
+type RetentionReport struct {
+	Time    time.Time
+	DryRun  bool
+	Actions []RetentionAction
+}
+
+//ArchiveDeleter 可以删除对象的归档目标，S3Target等均实现了该接口
+type ArchiveDeleter interface {
+	//Delete 删除对象，对象不存在时返回nil
+	Delete(key string) error
+}
+
+//SetRetentionPolicy 设置分级保留策略，p为nil时恢复SetLogStorageTime的按时间删除
+//exp: 原始文件保留3天，压缩文件再保留30天，归档后保留1年
+//
+//	gclog.SetArchiver(s3, gclog.ArchiveOptions{Prefix: "app/{hostname}/"})
+//	gclog.SetRetentionPolicy(&gclog.RetentionPolicy{KeepRaw: 3 * 24 * time.Hour, KeepCompressed: 30 * 24 * time.Hour, KeepArchived: 365 * 24 * time.Hour})
+func SetRetentionPolicy(p *RetentionPolicy) error {
+	if p != nil {
+		if p.KeepRaw <= 0 {
+			return errors.New("gclog: retention policy requires KeepRaw > 0")
+		}
+		copied := *p
+		p = &copied
+	}
+	retentionLock.Lock()
+	retentionPolicy = p
+	retentionLock.Unlock()
+	return nil
+}
+
+//LastRetentionReport 返回上一次执行保留策略的报告
+func LastRetentionReport() RetentionReport {
+	retentionLock.Lock()
+	defer retentionLock.Unlock()
+	return retentionLast
+}
+
+//RunRetention 立即执行一次保留策略并返回报告，没有设置策略时返回空报告，配合DryRun可以预览将要执行的操作
+func RunRetention() RetentionReport {
+	retentionLock.Lock()
+	p := retentionPolicy
+	retentionLock.Unlock()
+	if p == nil {
+		return RetentionReport{Time: time.Now()}
+	}
+	retentionRunLock.Lock()
+	report := runRetention(p)
+	retentionRunLock.Unlock()
+	auditDeletions()
+	retentionLock.Lock()
+	retentionLast = report
+	retentionLock.Unlock()
+	return report
+}
+
+//retentionActive 判断是否设置了保留策略
+func retentionActive() bool {
+	retentionLock.Lock()
+	defer retentionLock.Unlock()
+	return retentionPolicy != nil
+}
+
+//applyRetention 由日志切分循环调用，上传可能较慢，在单独的goroutine中执行，上一次未结束时跳过
+func applyRetention() {
+	if !retentionActive() || !atomic.CompareAndSwapInt32(&retentionRunning, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&retentionRunning, 0)
+		RunRetention()
+	}()
+}
+
+//logFileNames 返回主日志文件与RouteLevels的文件名
+func logFileNames() []string {
+	var files []string
+	fileLock.RLock()
+	if writeToFile {
+		files = append(files, fileName)
+	}
+	fileLock.RUnlock()
+	for _, s := range loadSinks() {
+		if f, ok := s.sink.(*FileSink); ok {
+			files = append(files, f.FileName())
+		}
+	}
+	return files
+}
+
+//runRetention 按策略处理各日志文件切分出的文件
+func runRetention(p *RetentionPolicy) RetentionReport {
+	report := RetentionReport{Time: time.Now(), DryRun: p.DryRun}
+	archiverLock.Lock()
+	a := currentArchiver
+	archiverLock.Unlock()
+	for _, filename := range logFileNames() {
+		dir, name, suffix := getFileInfo(filename)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			Warning("retention read dir %s failed, because %s", dir, err.Error())
+			continue
+		}
+		//未处理与压缩后的文件，用于检查SetLogRetentionLimits的限制
+		var kept []rotatedFile
+		for _, v := range entries {
+			raw := isRotatedFile(v.Name(), name, suffix)
+			compressed := isRotatedFile(v.Name(), name, suffix+".gz")
+			if !raw && !compressed {
+				continue
+			}
+			info, err := v.Info()
+			if err != nil {
+				continue
+			}
+			path := dir + "/" + v.Name()
+			age := report.Time.Sub(info.ModTime())
+			switch {
+			case raw && age >= p.KeepRaw && p.KeepCompressed > 0:
+				report.add(p, RetentionCompress, path, info.Size(), func() error { return compressRotated(path, info) })
+				if gz, err := os.Stat(path + ".gz"); err == nil {
+					kept = append(kept, rotatedFile{path: path + ".gz", info: gz})
+				} else {
+					kept = append(kept, rotatedFile{path: path, info: info})
+				}
+			case raw && age >= p.KeepRaw, compressed && age >= p.KeepRaw+p.KeepCompressed:
+				report.expire(p, a, filename, rotatedFile{path: path, info: info})
+			default:
+				kept = append(kept, rotatedFile{path: path, info: info})
+			}
+		}
+		//超出个数或总大小限制的最旧文件提前归档或删除
+		sort.Slice(kept, func(i, j int) bool { return kept[i].info.ModTime().Before(kept[j].info.ModTime()) })
+		maxFiles, maxTotalSize := retentionLimits()
+		for _, f := range kept[:trimCount(kept, maxFiles, maxTotalSize)] {
+			report.expire(p, a, filename, f)
+		}
+		if p.KeepArchived > 0 && a != nil {
+			report.deleteRemote(p, a, filename)
+		}
+	}
+	return report
+}
+
+//expire 本地保留时间结束，设置了归档器时上传并记录到清单后删除，否则直接删除
+func (r *RetentionReport) expire(p *RetentionPolicy, a *Archiver, filename string, f rotatedFile) {
+	if a == nil {
+		r.add(p, RetentionDelete, f.path, f.info.Size(), func() error {
+			if err := removeRotated(f.path); err != nil {
+				return err
+			}
+			recordDeletion(f, DeleteRetention)
+			return nil
+		})
+		return
+	}
+	r.add(p, RetentionArchive, f.path, f.info.Size(), func() error {
+		key, err := a.upload(f.path)
+		if err != nil {
+			return err
+		}
+		if err := appendArchived(filename, key); err != nil {
+			return err
+		}
+		if err := removeRotated(f.path); err != nil {
+			return err
+		}
+		recordDeletion(f, DeleteArchived)
+		return nil
+	})
+}
+
+//add 执行一个操作并记录，DryRun时只记录
+func (r *RetentionReport) add(p *RetentionPolicy, action, path string, size int64, do func() error) {
+	act := RetentionAction{Action: action, Path: path, Size: size}
+	if p.DryRun {
+		Notice("retention %s %s, %d bytes (dry run)", action, path, size)
+	} else if err := do(); err != nil {
+		act.Err = err.Error()
+		Warning("retention %s %s failed, because %s", action, path, act.Err)
+	} else {
+		Notice("retention %s %s, %d bytes", action, path, size)
+	}
+	r.Actions = append(r.Actions, act)
+}
+
+//deleteRemote 删除清单中上传超过KeepArchived的对象，删除成功的从清单中去掉
+func (r *RetentionReport) deleteRemote(p *RetentionPolicy, a *Archiver, filename string) {
+	records, err := readArchived(filename)
+	if err != nil || len(records) == 0 {
+		return
+	}
+	deleter, ok := a.target.(ArchiveDeleter)
+	if !ok {
+		return
+	}
+	kept := records[:0]
+	for _, rec := range records {
+		if r.Time.Sub(rec.time) < p.KeepArchived {
+			kept = append(kept, rec)
+			continue
+		}
+		var errDelete error
+		r.add(p, RetentionDeleteRemote, rec.key, 0, func() error {
+			errDelete = deleter.Delete(rec.key)
+			return errDelete
+		})
+		if p.DryRun || errDelete != nil {
+			kept = append(kept, rec)
+		}
+	}
+	if !p.DryRun {
+		if err := writeArchived(filename, kept); err != nil {
+			Warning("retention update %s failed, because %s", filename+ArchivedManifestSuffix, err.Error())
+		}
+	}
+}
+
+//compressRotated 将切分出的文件压缩为path.gz，保留原修改时间，之后删除原文件
+func compressRotated(path string, info os.FileInfo) error {
+	tmp, err := gzipFile(path)
+	if err != nil {
+		return err
+	}
+	os.Chtimes(tmp, info.ModTime(), info.ModTime())
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return removeRotated(path)
+}
+
+//removeRotated 删除切分出的文件及其时间索引
+func removeRotated(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	removeTimeIndex(path)
+	return nil
+}
+
+//archivedRecord 清单中的一个对象
+type archivedRecord struct {
+	time time.Time
+	key  string
+}
+
+//appendArchived 在filename的清单中追加一个已上传的对象
+func appendArchived(filename, key string) error {
+	file, err := createFile(filename+ArchivedManifestSuffix, os.O_APPEND|os.O_WRONLY)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(file, "%d %s\n", time.Now().Unix(), key)
+	if errClose := file.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
+
+//readArchived 读取filename的清单，不存在时返回空
+func readArchived(filename string) ([]archivedRecord, error) {
+	file, err := os.Open(filename + ArchivedManifestSuffix)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var records []archivedRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		sec, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		records = append(records, archivedRecord{time: time.Unix(sec, 0), key: fields[1]})
+	}
+	return records, scanner.Err()
+}
+
+//writeArchived 以临时文件加改名的方式重写filename的清单
+func writeArchived(filename string, records []archivedRecord) error {
+	name := filename + ArchivedManifestSuffix
+	var b strings.Builder
+	for _, rec := range records {
+		fmt.Fprintf(&b, "%d %s\n", rec.time.Unix(), rec.key)
+	}
+	if err := os.WriteFile(name+".tmp", []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(name+".tmp", name)
+}
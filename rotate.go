@@ -0,0 +1,66 @@
+package gclog
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+var (
+	rotateRequest    = make(chan chan struct{}, 16) //Rotate的请求，日志切分循环切分后关闭其中的通道
+	rotateSignalLock = new(sync.Mutex)              //修改切分信号时加锁
+	rotateSignalStop chan struct{}                  //关闭后停止监听切分信号
+)
+
+//Rotate 立即切分主日志文件与RouteLevels的文件，切分完成后返回，审计日志文件不受影响
+//收集诊断信息之前切分，可以得到只包含问题期间日志的文件；没有写入文件时返回错误
+//exp: gclog.Rotate()
+func Rotate() error {
+	fileLock.RLock()
+	toFile := writeToFile
+	fileLock.RUnlock()
+	if !toFile {
+		return errors.New("gclog: not writing to a log file")
+	}
+	done := make(chan struct{})
+	rotateRequest <- done
+	<-done
+	return nil
+}
+
+//SetRotateSignal 收到sig时调用Rotate立即切分，重复调用时替换之前的信号，sig为nil时停止监听
+//SIGUSR1、SIGUSR2、SIGQUIT已用于调整日志级别与输出诊断信息，通常使用SIGHUP，与logrotate等工具的约定一致
+//exp: gclog.SetRotateSignal(syscall.SIGHUP)
+func SetRotateSignal(sig os.Signal) {
+	rotateSignalLock.Lock()
+	defer rotateSignalLock.Unlock()
+	if rotateSignalStop != nil {
+		close(rotateSignalStop)
+		rotateSignalStop = nil
+	}
+	if sig == nil {
+		return
+	}
+	rotateSignalStop = make(chan struct{})
+	//在返回前开始监听，之后收到的信号不会漏掉
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sig)
+	go rotateSignalListen(c, rotateSignalStop)
+}
+
+//rotateSignalListen 监听切分信号
+func rotateSignalListen(c chan os.Signal, stop chan struct{}) {
+	defer signal.Stop(c)
+	for {
+		select {
+		case s := <-c:
+			Warning("recvice signal %s, rotate log files", s)
+			if err := Rotate(); err != nil {
+				Warning("rotate log files failed, because %s", err.Error())
+			}
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package gclog
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+//rotationEventQueueSize 切分事件通道的长度，消费不及时时新事件被丢弃，不阻塞切分
+const rotationEventQueueSize = 64
+
+var (
+	rotationEventOnce sync.Once
+	rotationEventCh   chan RotationEvent //调用RotationEvents之前为nil，不发送事件
+	rotationEventLock = new(sync.RWMutex)
+)
+
+//RotationEvent 一次完成的切分，切换到新文件之后发送，主日志文件、RouteLevels与审计日志的文件切分时都会产生
+type RotationEvent struct {
+	OldPath  string        //日志文件名，切分后继续写入同名的新文件，exp: ./app.log
+	NewPath  string        //改名后的文件，exp: ./app_2018_04_08_16.log
+	Size     int64         //改名后的文件大小
+	Time     time.Time     //切分完成的时间
+	Duration time.Duration //切分（改名、打开新文件并切换输出）的耗时
+}
+
+//RotationEvents 返回切分事件的通道，切分完成后可以立即开始下游处理，不需要轮询日志目录
+//所有调用返回同一个通道，第一次调用之后才产生事件，消费不及时、队列满时新事件被丢弃
+//exp:
+//
+//	go func() {
+//		for ev := range gclog.RotationEvents() {
+//			process(ev.NewPath)
+//		}
+//	}()
+func RotationEvents() <-chan RotationEvent {
+	rotationEventOnce.Do(func() {
+		rotationEventLock.Lock()
+		rotationEventCh = make(chan RotationEvent, rotationEventQueueSize)
+		rotationEventLock.Unlock()
+	})
+	return rotationEventCh
+}
+
+//notifyRotation 发送切分事件，没有调用RotationEvents时忽略
+func notifyRotation(oldPath, newPath string, start time.Time) {
+	rotationEventLock.RLock()
+	ch := rotationEventCh
+	rotationEventLock.RUnlock()
+	if ch == nil {
+		return
+	}
+	ev := RotationEvent{OldPath: oldPath, NewPath: newPath, Time: time.Now()}
+	ev.Duration = ev.Time.Sub(start)
+	if info, err := os.Stat(newPath); err == nil {
+		ev.Size = info.Size()
+	}
+	select {
+	case ch <- ev:
+	default:
+	}
+}
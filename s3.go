@@ -0,0 +1,194 @@
+package gclog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+//S3Options S3兼容对象存储的设置
+type S3Options struct {
+	//Endpoint 服务地址，exp: https://s3.us-east-1.amazonaws.com、http://127.0.0.1:9000（MinIO）、https://oss-cn-hangzhou.aliyuncs.com（OSS）
+	Endpoint string
+	Region   string //签名使用的区域，为空时为us-east-1
+	Bucket   string
+	//AccessKeyID、SecretAccessKey 访问密钥，SessionToken为临时凭证（STS）时的token
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	//PathStyle 为true时使用Endpoint/Bucket/key形式的地址（MinIO等），否则使用Bucket.Endpoint/key形式
+	PathStyle    bool
+	StorageClass string        //存储类型，exp: STANDARD_IA，为空时使用bucket的默认值
+	TLS          *TLSOptions   //https地址的证书设置，为nil时使用系统证书
+	Timeout      time.Duration //一次上传的超时，<=0时默认10min
+}
+
+//S3Target 上传到S3兼容对象存储（AWS S3、MinIO、阿里云OSS等）的归档目标，使用AWS Signature V4签名的PUT上传
+//单个文件最大5GB
+type S3Target struct {
+	opts   S3Options
+	base   *url.URL
+	client *http.Client
+}
+
+//NewS3Target 创建S3归档目标
+//exp: s3, err := gclog.NewS3Target(gclog.S3Options{Endpoint: "https://s3.us-east-1.amazonaws.com", Bucket: "app-logs", AccessKeyID: id, SecretAccessKey: secret})
+func NewS3Target(opts S3Options) (*S3Target, error) {
+	base, err := url.Parse(opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" || base.Host == "" {
+		return nil, fmt.Errorf("gclog: invalid s3 endpoint %q", opts.Endpoint)
+	}
+	if opts.Bucket == "" {
+		return nil, errors.New("gclog: empty s3 bucket")
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Minute
+	}
+	if !opts.PathStyle {
+		base.Host = opts.Bucket + "." + base.Host
+	}
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if opts.TLS != nil {
+		if transport.TLSClientConfig, err = opts.TLS.config(base.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+	return &S3Target{
+		opts:   opts,
+		base:   base,
+		client: &http.Client{Transport: transport, Timeout: opts.Timeout},
+	}, nil
+}
+
+//Upload 实现ArchiveTarget，以PUT上传对象
+func (t *S3Target) Upload(key string, file *os.File, size int64) error {
+	//签名需要内容的SHA256，先读一遍文件
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	req, err := t.request("PUT", key, io.NopCloser(file), hex.EncodeToString(h.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	return t.do(req, key)
+}
+
+//Delete 实现ArchiveDeleter，对象不存在时S3同样返回204
+func (t *S3Target) Delete(key string) error {
+	//空内容的SHA256
+	req, err := t.request("DELETE", key, nil, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+	if err != nil {
+		return err
+	}
+	return t.do(req, key)
+}
+
+//request 创建key的签名请求
+func (t *S3Target) request(method, key string, body io.Reader, payloadHash string) (*http.Request, error) {
+	path := "/" + s3Escape(key)
+	if t.opts.PathStyle {
+		path = "/" + s3Escape(t.opts.Bucket) + path
+	}
+	u := *t.base
+	u.Path = ""
+	u.RawPath = ""
+	req, err := http.NewRequest(method, u.String()+path, body)
+	if err != nil {
+		return nil, err
+	}
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           time.Now().UTC().Format("20060102T150405Z"),
+	}
+	if t.opts.SessionToken != "" {
+		headers["x-amz-security-token"] = t.opts.SessionToken
+	}
+	if t.opts.StorageClass != "" && method == "PUT" {
+		headers["x-amz-storage-class"] = t.opts.StorageClass
+	}
+	for k, v := range headers {
+		if k != "host" {
+			req.Header.Set(k, v)
+		}
+	}
+	req.Header.Set("Authorization", t.sign(method, path, headers, payloadHash))
+	return req, nil
+}
+
+//do 发送请求，非2xx时返回错误
+func (t *S3Target) do(req *http.Request, key string) error {
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("gclog: s3 %s %s returned %d: %s", strings.ToLower(req.Method), key, resp.StatusCode, strings.TrimSpace(string(msg)))
+}
+
+//sign 返回AWS Signature V4的Authorization头，headers为参与签名的头（小写）
+func (t *S3Target) sign(method, path string, headers map[string]string, payloadHash string) string {
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonical strings.Builder
+	canonical.WriteString(method + "\n" + path + "\n\n")
+	for _, k := range names {
+		canonical.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+	}
+	signed := strings.Join(names, ";")
+	canonical.WriteString("\n" + signed + "\n" + payloadHash)
+	amzDate := headers["x-amz-date"]
+	scope := amzDate[:8] + "/" + t.opts.Region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonical.String()))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+	key := hmacSHA256([]byte("AWS4"+t.opts.SecretAccessKey), amzDate[:8])
+	key = hmacSHA256(key, t.opts.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return "AWS4-HMAC-SHA256 Credential=" + t.opts.AccessKeyID + "/" + scope + ", SignedHeaders=" + signed +
+		", Signature=" + hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+//s3Escape 按SigV4的规则编码对象名，只保留非保留字符与“/”
+func s3Escape(s string) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isAlnum(c) || c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0xf])
+		}
+	}
+	return b.String()
+}
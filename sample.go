@@ -0,0 +1,92 @@
+package gclog
+
+import "sync/atomic"
+
+//sampleSlots 采样计数的槽数，级别与消息按哈希分配到槽，不同消息偶尔共用一个槽
+const sampleSlots = 4096
+
+//sampleCounter 一个槽在当前这一秒内的计数，原子读写
+type sampleCounter struct {
+	second int64  //计数所属的unix秒
+	count  uint64 //该秒内的条数
+}
+
+//sampler 采样设置，每秒内同一级别、同一消息的前first条全部输出，之后每thereafter条输出一条
+type sampler struct {
+	first      uint64
+	thereafter uint64
+	counters   [sampleSlots]sampleCounter
+}
+
+var (
+	logSampler atomic.Value //当前的采样设置，*sampler，为nil时不采样
+	sampledOut uint64       //被采样丢弃的日志条数，原子读写
+)
+
+//SetSampling 设置日志采样，每秒内同一级别、同一消息的前first条全部输出，之后每thereafter条输出一条，thereafter<=0时之后全部丢弃
+//只对error以下级别采样，error及以上级别总是输出；first<=0时关闭采样（默认关闭）
+//被丢弃的日志条数可以通过Status().Sampled查看
+//exp: gclog.SetSampling(100, 100) 每秒同一条日志超过100条后只输出1%
+func SetSampling(first, thereafter int) {
+	if first <= 0 {
+		logSampler.Store((*sampler)(nil))
+		return
+	}
+	if thereafter < 0 {
+		thereafter = 0
+	}
+	logSampler.Store(&sampler{first: uint64(first), thereafter: uint64(thereafter)})
+}
+
+//loadSampler 取当前的采样设置，未设置时返回nil
+func loadSampler() *sampler {
+	s, _ := logSampler.Load().(*sampler)
+	return s
+}
+
+//sampled 判断日志是否因采样被丢弃
+func sampled(e *Entry) bool {
+	s := loadSampler()
+	if s == nil || e.Level >= ErrorLevel {
+		return false
+	}
+	c := &s.counters[sampleHash(e.Level, e.Message)%sampleSlots]
+	n := c.inc(e.Time.Unix())
+	if n <= s.first || (s.thereafter > 0 && (n-s.first)%s.thereafter == 0) {
+		return false
+	}
+	atomic.AddUint64(&sampledOut, 1)
+	return true
+}
+
+//inc 计数加一并返回该秒内的条数，进入新的一秒时重新计数
+func (c *sampleCounter) inc(second int64) uint64 {
+	old := atomic.LoadInt64(&c.second)
+	if old == second {
+		return atomic.AddUint64(&c.count, 1)
+	}
+	//只有一个goroutine能切换到新的一秒，其余的继续计数
+	if !atomic.CompareAndSwapInt64(&c.second, old, second) {
+		return atomic.AddUint64(&c.count, 1)
+	}
+	atomic.StoreUint64(&c.count, 1)
+	return 1
+}
+
+//sampleHash 级别与消息的FNV-1a哈希
+func sampleHash(level int, msg string) uint32 {
+	h := uint32(2166136261) ^ uint32(level)
+	for i := 0; i < len(msg); i++ {
+		h ^= uint32(msg[i])
+		h *= 16777619
+	}
+	return h
+}
+
+//samplingConfig 返回当前的采样设置，未开启时为0
+func samplingConfig() (first, thereafter int) {
+	if s := loadSampler(); s != nil {
+		return int(s.first), int(s.thereafter)
+	}
+	return 0, 0
+}
@@ -0,0 +1,123 @@
+package gclog
+
+import (
+	"fmt"
+	"time"
+)
+
+//sliceMode 切分方式
+type sliceMode int
+
+const (
+	sliceByInterval sliceMode = iota //按固定间隔切分
+	sliceWeekly                      //每周切分
+	sliceMonthly                     //每月切分
+)
+
+var (
+	logSliceMode    sliceMode    //主日志文件的切分方式
+	logSliceWeekday time.Weekday //每周切分时的切分日
+	logSliceISOWeek bool         //每周切分时是否以ISO年与周命名
+)
+
+//sliceSchedule 一个日志文件的切分规则
+type sliceSchedule struct {
+	mode     sliceMode
+	interval time.Duration //按固定间隔切分时的间隔
+	weekday  time.Weekday  //每周切分时的切分日
+	isoWeek  bool          //每周切分时以ISO年与周命名
+}
+
+//SetLogSliceWeekly 每周weekday的0点切分日志，适合日志量很小、按天切分文件过多的服务，RouteLevels的文件默认相同
+//切分出的文件以所含的一周的开始日期命名，exp: test_2018_04_08.log，调用SetLogSliceInterval后恢复按间隔切分
+//exp: gclog.SetLogSliceWeekly(time.Monday)
+func SetLogSliceWeekly(weekday time.Weekday) {
+	logSliceMode, logSliceWeekday, logSliceISOWeek = sliceWeekly, weekday, false
+	//近似值，用于状态显示与检查间隔
+	logSliceInterval = 7 * 24 * time.Hour
+}
+
+//SetLogSliceISOWeek 每周一0点切分日志，切分出的文件以所含的ISO年与周命名，exp: test_2018-W15.log，对账等以ISO周为处理单位的场景使用
+//ISO周从周一开始，年初、年末的几天属于相邻年份的周，exp: 2021年1月1日属于2020-W53，RouteLevels的文件默认相同
+func SetLogSliceISOWeek() {
+	logSliceMode, logSliceWeekday, logSliceISOWeek = sliceWeekly, time.Monday, true
+	logSliceInterval = 7 * 24 * time.Hour
+}
+
+//SetLogSliceMonthly 每月1日0点切分日志，RouteLevels的文件默认相同
+//切分出的文件以所含的月份命名，exp: test_2018_04.log，调用SetLogSliceInterval后恢复按间隔切分
+func SetLogSliceMonthly() {
+	logSliceMode = sliceMonthly
+	//近似值，用于状态显示与检查间隔
+	logSliceInterval = 30 * 24 * time.Hour
+}
+
+//mainSchedule 返回主日志文件的切分规则
+func mainSchedule() sliceSchedule {
+	return sliceSchedule{mode: logSliceMode, interval: logSliceInterval, weekday: logSliceWeekday, isoWeek: logSliceISOWeek}
+}
+
+//sliceTime 返回记录为上次切分时间的时刻，减去切分延迟后按切分间隔取整（间隔不小于1小时时取整点），延迟较大时切分时间不会逐次后移
+//每周、每月切分时为当前周期的开始时刻
+func (s sliceSchedule) sliceTime() time.Time {
+	if s.mode != sliceByInterval {
+		return s.start(now().Add(-rotationDelay()))
+	}
+	return time.Now().Add(-rotationDelay()).Round(sliceUnit(s.interval))
+}
+
+//due 判断上次切分时间为flashTime的文件是否需要切分
+func (s sliceSchedule) due(flashTime time.Time) bool {
+	switch s.mode {
+	case sliceWeekly:
+		return !now().Add(-rotationDelay()).Before(s.start(flashTime).AddDate(0, 0, 7))
+	case sliceMonthly:
+		return !now().Add(-rotationDelay()).Before(s.start(flashTime).AddDate(0, 1, 0))
+	}
+	return time.Now().After(flashTime.Add(s.interval + rotationDelay()))
+}
+
+//start 返回t所在的一周或一月的开始时刻
+func (s sliceSchedule) start(t time.Time) time.Time {
+	if s.mode == sliceMonthly {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	}
+	back := (int(t.Weekday()) - int(s.weekday) + 7) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-back, 0, 0, 0, 0, t.Location())
+}
+
+//rotatedTime 返回切分出的文件名中的时间部分，flashTime为上次切分时间
+//按间隔切分时为当前时间，小于1小时的间隔加上分钟，小于1分钟的间隔再加上秒；每周、每月切分时为文件所含周期的开始日期或ISO周
+func (s sliceSchedule) rotatedTime(flashTime time.Time) string {
+	switch s.mode {
+	case sliceWeekly:
+		t := s.start(flashTime)
+		if s.isoWeek {
+			//exp:"2018-W15"
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}
+		return fmt.Sprintf("%02d_%02d_%02d", t.Year(), t.Month(), t.Day())
+	case sliceMonthly:
+		t := s.start(flashTime)
+		return fmt.Sprintf("%02d_%02d", t.Year(), t.Month())
+	}
+	timeNow := now()
+	//exp:"2018_04_08_16"
+	name := fmt.Sprintf("%02d_%02d_%02d_%02d", timeNow.Year(), timeNow.Month(), timeNow.Day(), timeNow.Hour())
+	//exp:"2018_04_08_16_30"
+	if unit := sliceUnit(s.interval); unit < time.Minute {
+		name += fmt.Sprintf("_%02d_%02d", timeNow.Minute(), timeNow.Second())
+	} else if unit < time.Hour {
+		name += fmt.Sprintf("_%02d", timeNow.Minute())
+	}
+	return name
+}
+
+//sliceUnit 返回切分时间取整的单位，小于1小时的间隔按间隔本身对齐，exp: 间隔为10min时在整10分切分
+func sliceUnit(interval time.Duration) time.Duration {
+	if interval <= 0 || interval >= time.Hour {
+		return time.Hour
+	}
+	return interval
+}
@@ -0,0 +1,59 @@
+package gclog
+
+import "regexp"
+
+//内置的密钥检测规则，匹配的内容替换为[REDACTED:规则名]
+var (
+	//SecretAWSAccessKey AWS访问密钥id
+	SecretAWSAccessKey = MaskRule{
+		Name:    "aws_access_key",
+		Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA)[0-9A-Z]{16}\b`),
+		Replace: "[REDACTED:aws_access_key]",
+	}
+	//SecretAWSSecretKey 以aws_secret_access_key=形式出现的AWS私有访问密钥
+	SecretAWSSecretKey = MaskRule{
+		Name:    "aws_secret_key",
+		Pattern: regexp.MustCompile(`(?i)(aws_?secret_?access_?key["']?\s*[=:]\s*["']?)[A-Za-z0-9/+=]{40}`),
+		Replace: "${1}[REDACTED:aws_secret_key]",
+	}
+	//SecretJWT JSON Web Token
+	SecretJWT = MaskRule{
+		Name:    "jwt",
+		Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+		Replace: "[REDACTED:jwt]",
+	}
+	//SecretBearer HTTP Authorization头中的Bearer令牌
+	SecretBearer = MaskRule{
+		Name:    "bearer",
+		Pattern: regexp.MustCompile(`(?i)\b(bearer\s+)[A-Za-z0-9._~+/-]+=*`),
+		Replace: "${1}[REDACTED:bearer]",
+	}
+	//SecretPrivateKey PEM格式的私钥块
+	SecretPrivateKey = MaskRule{
+		Name:    "private_key",
+		Pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+		Replace: "[REDACTED:private_key]",
+	}
+	//SecretGitHubToken GitHub个人访问令牌等
+	SecretGitHubToken = MaskRule{
+		Name:    "github_token",
+		Pattern: regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),
+		Replace: "[REDACTED:github_token]",
+	}
+	//SecretSlackToken Slack令牌
+	SecretSlackToken = MaskRule{
+		Name:    "slack_token",
+		Pattern: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`),
+		Replace: "[REDACTED:slack_token]",
+	}
+	//SecretRules 内置的全部密钥检测规则
+	SecretRules = []MaskRule{
+		SecretPrivateKey, SecretJWT, SecretBearer, SecretAWSAccessKey, SecretAWSSecretKey, SecretGitHubToken, SecretSlackToken,
+	}
+)
+
+//EnableSecretScrubbing 开启内置的密钥检测，日志消息中的AWS密钥、JWT、Bearer令牌、私钥块等在写入前被替换
+//防止凭据误打到长期保存的日志中，规则与AddMaskRules添加的脱敏规则一起执行
+func EnableSecretScrubbing() {
+	AddMaskRules(SecretRules...)
+}
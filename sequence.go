@@ -0,0 +1,29 @@
+package gclog
+
+import "sync/atomic"
+
+//SeqField 序号输出的字段名
+const SeqField = "seq"
+
+var (
+	sequenceEnable int32  //是否开启序号，原子读写
+	sequence       uint64 //当前序号，原子读写
+)
+
+//EnableSequence 开启或关闭日志序号，开启后每条日志带一个进程内单调递增的序号（从1开始）
+//用于异步/批量投递后检测乱序或丢失，以及对同一进程的日志进行全排序
+func EnableSequence(enable bool) {
+	if enable {
+		atomic.StoreInt32(&sequenceEnable, 1)
+	} else {
+		atomic.StoreInt32(&sequenceEnable, 0)
+	}
+}
+
+//nextSeq 取下一个序号，未开启时返回0
+func nextSeq() uint64 {
+	if atomic.LoadInt32(&sequenceEnable) == 0 {
+		return 0
+	}
+	return atomic.AddUint64(&sequence, 1)
+}
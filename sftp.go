@@ -0,0 +1,126 @@
+package gclog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+)
+
+//SFTPOptions SFTP归档目标的设置
+type SFTPOptions struct {
+	Addr string //归档主机，host或host:port，默认端口22
+	User string
+	//KeyFile 私钥文件，只使用密钥认证，不会提示输入密码
+	KeyFile string
+	//KnownHostsFile 已知主机文件，主机密钥不在其中或不一致时拒绝连接，为空时使用~/.ssh/known_hosts
+	KnownHostsFile string
+	Dir            string        //远程目录，对象名中的目录会在其下创建
+	Binary         string        //sftp命令，为空时为sftp
+	Timeout        time.Duration //一次上传的超时，<=0时默认10min
+}
+
+//SFTPTarget 通过SFTP复制到本地归档主机的归档目标，用于日志必须留在内网而不能上传云存储的场景
+//标准库没有SSH实现，使用系统的OpenSSH sftp命令以批处理模式上传，先上传为临时文件再改名，归档主机上不会出现不完整的文件
+type SFTPTarget struct {
+	opts SFTPOptions
+	host string
+	port string
+}
+
+//NewSFTPTarget 创建SFTP归档目标
+//exp: t, err := gclog.NewSFTPTarget(gclog.SFTPOptions{Addr: "archive.internal", User: "logs", KeyFile: "/etc/app/archive_ed25519", Dir: "/data/logs"})
+func NewSFTPTarget(opts SFTPOptions) (*SFTPTarget, error) {
+	if opts.Addr == "" || opts.User == "" || opts.KeyFile == "" {
+		return nil, errors.New("gclog: sftp target requires addr, user and key file")
+	}
+	host, port, err := net.SplitHostPort(opts.Addr)
+	if err != nil {
+		host, port = opts.Addr, "22"
+	}
+	if _, err := os.Stat(opts.KeyFile); err != nil {
+		return nil, err
+	}
+	if opts.Binary == "" {
+		opts.Binary = "sftp"
+	}
+	if _, err := exec.LookPath(opts.Binary); err != nil {
+		return nil, err
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Minute
+	}
+	return &SFTPTarget{opts: opts, host: host, port: port}, nil
+}
+
+//Upload 实现ArchiveTarget，需要时创建远程目录，上传为临时文件后改名
+func (t *SFTPTarget) Upload(key string, file *os.File, size int64) error {
+	remote := path.Join(t.opts.Dir, key)
+	tmp := path.Join(path.Dir(remote), "."+path.Base(remote)+".part")
+	var batch strings.Builder
+	//“-”开头的命令失败时继续执行，目录已存在时mkdir会失败
+	dir := ""
+	for _, part := range strings.Split(path.Dir(remote), "/") {
+		if part == "" {
+			dir = "/"
+			continue
+		}
+		if part == "." {
+			continue
+		}
+		dir = path.Join(dir, part)
+		batch.WriteString("-mkdir " + sftpQuote(dir) + "\n")
+	}
+	batch.WriteString("put " + sftpQuote(file.Name()) + " " + sftpQuote(tmp) + "\n")
+	batch.WriteString("-rm " + sftpQuote(remote) + "\n")
+	batch.WriteString("rename " + sftpQuote(tmp) + " " + sftpQuote(remote) + "\n")
+	if out, err := t.run(batch.String()); err != nil {
+		return fmt.Errorf("gclog: sftp upload %s failed, because %s: %s", key, err.Error(), out)
+	}
+	return nil
+}
+
+//Delete 实现ArchiveDeleter，文件不存在时同样返回nil
+func (t *SFTPTarget) Delete(key string) error {
+	if out, err := t.run("-rm " + sftpQuote(path.Join(t.opts.Dir, key)) + "\n"); err != nil {
+		return fmt.Errorf("gclog: sftp delete %s failed, because %s: %s", key, err.Error(), out)
+	}
+	return nil
+}
+
+//run 以批处理模式执行batch，失败时返回输出的最后1024字节
+func (t *SFTPTarget) run(batch string) (string, error) {
+	args := []string{"-b", "-", "-P", t.port, "-i", t.opts.KeyFile,
+		"-o", "BatchMode=yes",
+		"-o", "IdentitiesOnly=yes",
+		"-o", "StrictHostKeyChecking=yes",
+		"-o", "ConnectTimeout=30",
+	}
+	if t.opts.KnownHostsFile != "" {
+		args = append(args, "-o", "UserKnownHostsFile="+t.opts.KnownHostsFile)
+	}
+	args = append(args, t.opts.User+"@"+t.host)
+	ctx, cancel := context.WithTimeout(context.Background(), t.opts.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, t.opts.Binary, args...)
+	cmd.Stdin = strings.NewReader(batch)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if len(msg) > 1024 {
+			msg = msg[len(msg)-1024:]
+		}
+		return msg, err
+	}
+	return "", nil
+}
+
+//sftpQuote 为sftp批处理命令的参数加引号，转义引号与反斜杠
+func sftpQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
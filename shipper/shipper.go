@@ -0,0 +1,405 @@
+//Package shipper 跟踪gclog写入的日志文件（处理切分），将新增的日志转发到任意gclog.Sink（如NetSink），并记录转发进度
+//已有的按文件写日志的服务不需要修改即可接入远程sink，进程重启后从记录的进度继续转发
+//exp:
+//
+//	sink, _ := gclog.NewNetSink("tcp", "log.example.com:6514", gclog.NetOptions{})
+//	s, err := shipper.New("/var/log/app.log", sink, shipper.Options{Checkpoint: "/var/lib/app.log.ckpt"})
+//	...
+//	s.Close()
+//	sink.Close()
+package shipper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/bailiyang/gclog"
+	"github.com/bailiyang/gclog/reader"
+)
+
+//readChunk 每次从文件读取的最大长度
+const readChunk = 1 << 20
+
+//Options 转发的设置
+type Options struct {
+	//Checkpoint 记录转发进度的文件，为空时不记录，每次从文件开头转发
+	Checkpoint string
+	//PollInterval 检查文件新内容的间隔，<=0时默认1s
+	PollInterval time.Duration
+	//HeaderTemplate、TimeLayout 文本格式的日志头模板与时间格式，与写日志时的设置相同，为空时使用默认值
+	HeaderTemplate string
+	TimeLayout     string
+}
+
+//Shipper 跟踪一个日志文件并转发新增的日志，支持文本与json格式，不支持BinaryEncoder的二进制格式
+//日志按原始内容（文本或json行）交给sink，sink写入成功后才推进进度，写入失败（如NetSink队列满）时在下次检查时重试
+//进度只保证交给了sink，sink内部发送失败丢弃的日志不会重发
+type Shipper struct {
+	filename string
+	sink     gclog.Sink
+	opts     Options
+	file     *os.File //正在读取的文件，文件被切分后读完才切换到新文件
+	offset   int64    //buf开头在文件中的偏移
+	buf      []byte   //已读取、尚未转发的内容
+	saved    int64    //已记录到进度文件的偏移
+	lastErr  string   //上一次的错误，只在状态变化时输出警告
+	shipped  uint64   //转发的日志数，原子读写
+	done     chan struct{}
+	stopped  chan struct{}
+	once     sync.Once
+}
+
+//New 创建Shipper并在后台开始转发，有进度文件时从记录的位置继续（文件已被切分时先读完切分出的文件）
+func New(filename string, sink gclog.Sink, opts Options) (*Shipper, error) {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	//提前检查日志头模板
+	if err := reader.NewReader(nil).SetHeaderTemplate(opts.HeaderTemplate); err != nil {
+		return nil, err
+	}
+	s := &Shipper{
+		filename: filename,
+		sink:     sink,
+		opts:     opts,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	if err := s.resume(); err != nil {
+		return nil, err
+	}
+	go s.run()
+	return s, nil
+}
+
+//Close 停止转发，记录进度，不关闭sink
+func (s *Shipper) Close() error {
+	s.once.Do(func() {
+		close(s.done)
+	})
+	<-s.stopped
+	return nil
+}
+
+//Shipped 返回已转发的日志数
+func (s *Shipper) Shipped() uint64 {
+	return atomic.LoadUint64(&s.shipped)
+}
+
+//run 转发goroutine，每隔PollInterval读取新内容
+func (s *Shipper) run() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(s.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		s.poll()
+		select {
+		case <-s.done:
+			s.saveCheckpoint()
+			if s.file != nil {
+				s.file.Close()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//poll 读取并转发新内容，读到文件末尾时检查文件是否已被切分或截断
+func (s *Shipper) poll() {
+	if s.file == nil && !s.open(s.filename, 0) {
+		return
+	}
+	n, err := s.read()
+	if err != nil {
+		s.warn("read %s failed, because %s", s.file.Name(), err.Error())
+		return
+	}
+	if !s.ship(n == 0) {
+		return
+	}
+	if n == 0 {
+		s.checkRotation()
+	}
+	s.saveCheckpoint()
+}
+
+//read 读取文件新增的内容，返回读取的字节数
+func (s *Shipper) read() (int, error) {
+	total := 0
+	for {
+		if cap(s.buf)-len(s.buf) < readChunk {
+			buf := make([]byte, len(s.buf), len(s.buf)+readChunk)
+			copy(buf, s.buf)
+			s.buf = buf
+		}
+		n, err := s.file.Read(s.buf[len(s.buf):cap(s.buf)])
+		s.buf = s.buf[:len(s.buf)+n]
+		total += n
+		if err == io.EOF || (err == nil && n == 0) {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+		//单次检查最多读取readChunk，避免大文件积压时占用过多内存
+		if total >= readChunk {
+			return total, nil
+		}
+	}
+}
+
+//ship 转发buf中完整的日志，idle为true（没有新内容）时最后一条日志也转发，否则留到下次，等待可能的续行
+//sink写入失败时返回false，之后的内容留到下次重试
+func (s *Shipper) ship(idle bool) bool {
+	end := bytes.LastIndexByte(s.buf, '\n') + 1
+	consumed, err := s.process(s.buf[:end], idle)
+	if consumed > 0 {
+		s.offset += int64(consumed)
+		s.buf = s.buf[:copy(s.buf, s.buf[consumed:])]
+	}
+	if err != nil {
+		s.warn("ship entries of %s failed, because %s", s.file.Name(), err.Error())
+		return false
+	}
+	s.clearWarn()
+	return true
+}
+
+//process 解析data中的日志并写入sink，返回已处理的字节数
+//final为false时data的最后一条日志不处理，文本格式的续行可能还没有写入
+func (s *Shipper) process(data []byte, final bool) (int, error) {
+	//每行的起始位置，用于将日志对应到字节偏移
+	starts := []int{0}
+	for i, c := range data {
+		if c == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	lines := len(starts) - 1
+	r := reader.NewReader(bytes.NewReader(data))
+	r.SetHeaderTemplate(s.opts.HeaderTemplate)
+	r.SetTimeLayout(s.opts.TimeLayout)
+	line := 0 //已处理的日志占用的行数，不含之前跳过的行
+	for {
+		e, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return starts[r.Skipped()+line], err
+		}
+		start := r.Skipped() + line
+		n := bytes.Count(r.Raw(), []byte{'\n'})
+		if start+n >= lines && !final {
+			return starts[start], nil
+		}
+		if err := s.sink.Write(e, r.Raw()); err != nil {
+			return starts[start], err
+		}
+		atomic.AddUint64(&s.shipped, 1)
+		line += n
+	}
+	return len(data), nil
+}
+
+//checkRotation 文件已被切分时，转发最后不完整的一行后切换到新文件，文件被截断时从头读取
+func (s *Shipper) checkRotation() {
+	current, err := os.Stat(s.filename)
+	if err != nil {
+		//切分后新文件还没有创建
+		return
+	}
+	opened, err := s.file.Stat()
+	if err != nil {
+		return
+	}
+	if os.SameFile(opened, current) {
+		if opened.Size() < s.offset+int64(len(s.buf)) {
+			s.warn("%s is truncated, ship from the beginning", s.filename)
+			s.file.Seek(0, io.SeekStart)
+			s.offset, s.buf = 0, s.buf[:0]
+		}
+		return
+	}
+	//写日志的进程在改名后才切换到新文件，切换前可能还有写入，再读取一次
+	if _, err := s.read(); err != nil {
+		s.warn("read %s failed, because %s", s.file.Name(), err.Error())
+		return
+	}
+	if !s.ship(true) {
+		return
+	}
+	if len(s.buf) > 0 {
+		s.buf = append(s.buf, '\n')
+		if !s.ship(true) {
+			return
+		}
+	}
+	s.file.Close()
+	s.file = nil
+	s.open(s.nextFile(opened), 0)
+}
+
+//open 打开文件并定位到offset，失败时在下次检查时重试
+func (s *Shipper) open(name string, offset int64) bool {
+	file, err := os.Open(name)
+	if err != nil {
+		s.warn("open %s failed, because %s", name, err.Error())
+		return false
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		s.warn("seek %s failed, because %s", name, err.Error())
+		return false
+	}
+	s.file, s.offset, s.buf = file, offset, s.buf[:0]
+	s.saved = -1
+	return true
+}
+
+//checkpoint 转发进度，用设备号与inode标识文件，切分改名后仍能找到
+type checkpoint struct {
+	dev    uint64
+	ino    uint64
+	offset int64
+}
+
+//resume 从进度文件恢复，记录的文件已被切分时打开切分出的文件，已被删除时从当前文件开头转发
+func (s *Shipper) resume() error {
+	if s.opts.Checkpoint == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.opts.Checkpoint)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var c checkpoint
+	if _, err := fmt.Sscanf(string(data), "%d %d %d", &c.dev, &c.ino, &c.offset); err != nil {
+		return fmt.Errorf("shipper: invalid checkpoint %s: %s", s.opts.Checkpoint, err)
+	}
+	if name := findFile(s.filename, c); name != "" {
+		s.open(name, c.offset)
+	}
+	return nil
+}
+
+//rotatedFile 切分出的文件
+type rotatedFile struct {
+	name string
+	info os.FileInfo
+}
+
+//rotatedFiles 返回filename切分出的文件，按修改时间排序，exp: app_2018_04_08_16.log
+func rotatedFiles(filename string) []rotatedFile {
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "_"
+	infos, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var files []rotatedFile
+	for _, v := range infos {
+		if !strings.HasPrefix(v.Name(), prefix) || !strings.HasSuffix(v.Name(), ext) {
+			continue
+		}
+		if info, err := v.Info(); err == nil {
+			files = append(files, rotatedFile{name: filepath.Join(dir, v.Name()), info: info})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].info.ModTime().Before(files[j].info.ModTime()) })
+	return files
+}
+
+//findFile 查找进度记录的文件，当前文件或切分出的文件，找不到时返回空
+func findFile(filename string, c checkpoint) string {
+	if info, err := os.Stat(filename); err == nil && sameID(info, c) {
+		return filename
+	}
+	for _, f := range rotatedFiles(filename) {
+		if sameID(f.info, c) {
+			return f.name
+		}
+	}
+	return ""
+}
+
+//sameID 判断文件是否为进度记录的文件，且没有被截断
+func sameID(info os.FileInfo, c checkpoint) bool {
+	dev, ino := fileID(info)
+	return dev == c.dev && ino == c.ino && info.Size() >= c.offset
+}
+
+//nextFile 读完一个切分出的文件后，返回之后切分出的下一个文件，没有时返回当前文件
+//停止转发期间可能切分了多次，按修改时间依次读取
+func (s *Shipper) nextFile(finished os.FileInfo) string {
+	for _, f := range rotatedFiles(s.filename) {
+		if !os.SameFile(f.info, finished) && f.info.ModTime().After(finished.ModTime()) {
+			return f.name
+		}
+	}
+	return s.filename
+}
+
+//fileID 返回文件的设备号与inode
+func fileID(info os.FileInfo) (uint64, uint64) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0
+	}
+	return uint64(st.Dev), uint64(st.Ino)
+}
+
+//saveCheckpoint 进度变化时写入进度文件，先写临时文件再改名，避免写入中途退出损坏进度
+func (s *Shipper) saveCheckpoint() {
+	if s.opts.Checkpoint == "" || s.file == nil || s.offset == s.saved {
+		return
+	}
+	info, err := s.file.Stat()
+	if err != nil {
+		return
+	}
+	dev, ino := fileID(info)
+	tmp := s.opts.Checkpoint + ".tmp"
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d %d\n", dev, ino, s.offset)), 0644); err != nil {
+		s.warn("save checkpoint %s failed, because %s", s.opts.Checkpoint, err.Error())
+		return
+	}
+	if err := os.Rename(tmp, s.opts.Checkpoint); err != nil {
+		s.warn("save checkpoint %s failed, because %s", s.opts.Checkpoint, err.Error())
+		return
+	}
+	s.saved = s.offset
+}
+
+//warn 输出警告，与上一次相同的错误不重复输出
+func (s *Shipper) warn(format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	if msg == s.lastErr {
+		return
+	}
+	s.lastErr = msg
+	gclog.Warning("shipper: %s", msg)
+}
+
+//clearWarn 恢复正常后，下一次出错时重新输出警告
+func (s *Shipper) clearWarn() {
+	s.lastErr = ""
+}
@@ -0,0 +1,109 @@
+package gclog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+//SignatureField 日志签名的字段名，值为HMAC-SHA256(key, 去掉该字段后的日志内容)
+const SignatureField = "sig"
+
+var (
+	hmacKey         atomic.Value //签名密钥，[]byte，为空时不签名
+	errBadSignature = errors.New("gclog: bad signature")
+)
+
+//SetHMACKey 设置日志签名密钥，之后每条日志附加sig字段，key为空时关闭签名
+//日志交给第三方后，持有密钥的一方可以用VerifySignature、VerifySignedLog证明日志未被修改
+//签名支持TextEncoder与JSONEncoder，开启后每条日志需要编码两次
+func SetHMACKey(key []byte) {
+	hmacKey.Store(append([]byte(nil), key...))
+}
+
+//loadHMACKey 取签名密钥，未开启时返回nil
+func loadHMACKey() []byte {
+	key, _ := hmacKey.Load().([]byte)
+	if len(key) == 0 {
+		return nil
+	}
+	return key
+}
+
+//signEntry 对编码结果计算签名，附加sig字段后重新编码，需要在持有fileLock时调用
+func signEntry(buf []byte, e *Entry, key []byte) []byte {
+	sig := lineSignature(key, bytes.TrimSuffix(buf, []byte("\n")))
+	//使用完整切片表达式，避免覆盖调用方字段切片的底层数组
+	e.Fields = append(e.Fields[:len(e.Fields):len(e.Fields)], F(SignatureField, sig))
+	return encoder.Encode(buf[:0], e)
+}
+
+//lineSignature 计算HMAC-SHA256
+func lineSignature(key, line []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(line)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+//VerifySignature 校验一条日志的签名，entry为编码后的一条日志，可以包含调用栈等多行内容
+func VerifySignature(key, entry []byte) bool {
+	entry = bytes.TrimSuffix(entry, []byte("\n"))
+	sig, ok := lineFieldValue(entry, SignatureField)
+	if !ok {
+		return false
+	}
+	expect := lineSignature(key, removeLineField(entry, SignatureField))
+	return hmac.Equal([]byte(sig), []byte(expect))
+}
+
+//VerifySignedLog 校验日志文件中每条日志的签名，返回校验通过的条数
+//不带sig字段的行（如调用栈）视为上一条日志的一部分
+//exp:
+//
+//	f, _ := os.Open("app.log")
+//	n, err := gclog.VerifySignedLog(key, f)
+func VerifySignedLog(key []byte, r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var entry []byte
+	n, line, start := 0, 0, 0
+	verify := func() error {
+		if entry == nil {
+			return nil
+		}
+		if !VerifySignature(key, entry) {
+			return fmt.Errorf("%s: entry %d at line %d", errBadSignature, n+1, start)
+		}
+		n++
+		return nil
+	}
+	for scanner.Scan() {
+		line++
+		text := scanner.Bytes()
+		if _, ok := lineFieldValue(text, SignatureField); ok {
+			if err := verify(); err != nil {
+				return n, err
+			}
+			entry = append(entry[:0], text...)
+			start = line
+			continue
+		}
+		if entry == nil {
+			if len(text) == 0 {
+				continue
+			}
+			return n, fmt.Errorf("%s: line %d is not signed", errBadSignature, line)
+		}
+		entry = append(append(entry, '\n'), text...)
+	}
+	if err := scanner.Err(); err != nil {
+		return n, err
+	}
+	return n, verify()
+}
@@ -0,0 +1,134 @@
+package gclog
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+//Sink 日志输出目标，除主输出（InitLogFile的文件或标准错误）之外，每条日志会同时投递给所有已注册的sink
+type Sink interface {
+	//Write 写入一条日志，line为当前编码器编码后的内容（以换行结尾）
+	//Write返回后不能再引用e与line，需要异步处理时自行复制
+	Write(e *Entry, line []byte) error
+	//Close 关闭sink，释放文件、连接等资源
+	Close() error
+}
+
+//namedSink 已注册的sink
+type namedSink struct {
+	name string
+	sink Sink
+}
+
+var (
+	sinkLock    = new(sync.Mutex) //注册、移除sink时加锁
+	sinkList    atomic.Value      //当前的sink列表，[]namedSink，注册时整体替换，写日志时无锁读取
+	sinkErrors  uint64            //sink写入失败的次数，原子读写
+	outputLevel int32             //主输出的最低级别，低于该级别的日志只投递给sink，原子读写
+)
+
+//SetOutputLevel 设置主输出（InitLogFile的文件或标准错误）的最低级别，低于该级别的日志只写入sink
+//配合RouteLevels可以把低级别日志单独放到其他文件，默认为VerbLevel，即只受日志级别限制
+//exp: gclog.SetOutputLevel(gclog.InfoLevel)
+func SetOutputLevel(level int) {
+	if level < VerbLevel || level > FatalLevel {
+		return
+	}
+	atomic.StoreInt32(&outputLevel, int32(level))
+}
+
+//outputEnabled 判断对应级别的日志是否写入主输出
+func outputEnabled(level int) bool {
+	return int(atomic.LoadInt32(&outputLevel)) <= level
+}
+
+//AddSink 注册sink，运行中也可以调用，之后的日志同时投递给该sink
+//已存在同名sink时替换，被替换的sink在正在投递的日志写完后关闭
+//exp: 临时把日志发到调试机 gclog.AddSink("debug", s) ，排查完成后 gclog.RemoveSink("debug")
+func AddSink(name string, s Sink) {
+	if s == nil {
+		return
+	}
+	if old := addSink(name, s); old != nil {
+		closeSink(old)
+	}
+}
+
+//RemoveSink 移除并关闭sink，运行中也可以调用，正在投递给该sink的日志写完后才关闭，不存在时返回false
+func RemoveSink(name string) bool {
+	s := removeSink(name)
+	if s == nil {
+		return false
+	}
+	closeSink(s)
+	return true
+}
+
+//closeSink 等待正在投递的日志写完后关闭已移除的sink
+//投递时持有fileLock的读锁，取得一次写锁即可保证之后不会再有goroutine使用该sink
+func closeSink(s Sink) error {
+	fileLock.Lock()
+	fileLock.Unlock()
+	return s.Close()
+}
+
+//loadSinks 取当前的sink列表，返回的切片不能修改
+func loadSinks() []namedSink {
+	sinks, _ := sinkList.Load().([]namedSink)
+	return sinks
+}
+
+//addSink 注册sink，已存在同名sink时替换，并返回被替换的sink
+func addSink(name string, s Sink) Sink {
+	sinkLock.Lock()
+	defer sinkLock.Unlock()
+	old := loadSinks()
+	sinks := make([]namedSink, 0, len(old)+1)
+	var replaced Sink
+	for _, v := range old {
+		if v.name == name {
+			replaced = v.sink
+			continue
+		}
+		sinks = append(sinks, v)
+	}
+	sinkList.Store(append(sinks, namedSink{name: name, sink: s}))
+	return replaced
+}
+
+//removeSink 移除sink，返回被移除的sink，不存在时返回nil
+func removeSink(name string) Sink {
+	sinkLock.Lock()
+	defer sinkLock.Unlock()
+	old := loadSinks()
+	sinks := make([]namedSink, 0, len(old))
+	var removed Sink
+	for _, v := range old {
+		if v.name == name {
+			removed = v.sink
+			continue
+		}
+		sinks = append(sinks, v)
+	}
+	sinkList.Store(sinks)
+	return removed
+}
+
+//writeSinks 将日志投递给所有sink，写入失败只计数，不影响其他sink与主输出
+func writeSinks(e *Entry, line []byte) {
+	for _, s := range loadSinks() {
+		if err := s.sink.Write(e, line); err != nil {
+			atomic.AddUint64(&sinkErrors, 1)
+		}
+	}
+}
+
+//sinkNames 返回已注册的sink名称
+func sinkNames() []string {
+	sinks := loadSinks()
+	names := make([]string, 0, len(sinks))
+	for _, s := range sinks {
+		names = append(names, s.name)
+	}
+	return names
+}
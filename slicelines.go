@@ -0,0 +1,52 @@
+package gclog
+
+import (
+	"bytes"
+	"sync/atomic"
+)
+
+var (
+	logSliceLines int64                    //主日志文件切分的行数，0为不按行数切分，原子读写
+	logLines      int64                    //本进程写入当前主日志文件的行数，原子读写
+	sliceWake     = make(chan struct{}, 1) //达到切分行数时唤醒日志切分循环
+)
+
+//SetLogSliceLines 设置主日志文件切分的行数，本进程写入lines行后立即切分，与SetLogSliceInterval的时间切分同时生效，先到者切分
+//适合按固定大小批次处理日志的下游，行数只统计本进程的写入，RouteLevels的文件默认相同，lines<=0时关闭
+//exp: gclog.SetLogSliceLines(1000000)
+func SetLogSliceLines(lines int64) {
+	if lines < 0 {
+		lines = 0
+	}
+	atomic.StoreInt64(&logSliceLines, lines)
+}
+
+//countLogLines 统计写入主日志文件的行数，调用时持有fileLock的读锁
+func countLogLines(p []byte) {
+	if !writeToFile {
+		return
+	}
+	if limit := atomic.LoadInt64(&logSliceLines); limit > 0 {
+		countLines(&logLines, p, limit)
+	}
+}
+
+//logLinesDue 判断主日志文件是否达到了切分行数
+func logLinesDue() bool {
+	limit := atomic.LoadInt64(&logSliceLines)
+	return limit > 0 && atomic.LoadInt64(&logLines) >= limit
+}
+
+//countLines 将p中的行数累加到counter，达到limit时唤醒日志切分循环
+func countLines(counter *int64, p []byte, limit int64) {
+	n := int64(bytes.Count(p, []byte{'\n'}))
+	if n == 0 {
+		return
+	}
+	if total := atomic.AddInt64(counter, n); total >= limit && total-n < limit {
+		select {
+		case sliceWake <- struct{}{}:
+		default:
+		}
+	}
+}
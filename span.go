@@ -0,0 +1,48 @@
+package gclog
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+//Span 一次操作的起止记录，开始和结束日志共享同一个span id
+type Span struct {
+	ID    string    //span id
+	Name  string    //操作名称
+	Start time.Time //开始时间
+}
+
+//Begin 开始一个操作并输出开始日志，结束时调用span.End
+//exp:
+//	span := gclog.Begin("sync-job")
+//	err := doSync()
+//	span.End(err)
+func Begin(name string) *Span {
+	s := &Span{ID: newSpanID(), Name: name, Start: time.Now()}
+	if levelEnabled(InfoLevel) {
+		writeLog(InfoLevel, "span begin name="+s.Name+" span_id="+s.ID)
+	}
+	return s
+}
+
+//End 结束操作并输出结束日志，包括耗时与结果，err不为nil时以error级别输出
+func (s *Span) End(err error) {
+	elapsed := time.Since(s.Start)
+	if err != nil {
+		if levelEnabled(ErrorLevel) {
+			writeLog(ErrorLevel, "span end name="+s.Name+" span_id="+s.ID+" duration="+elapsed.String()+" outcome=failed error="+err.Error())
+		}
+		return
+	}
+	if levelEnabled(InfoLevel) {
+		writeLog(InfoLevel, "span end name="+s.Name+" span_id="+s.ID+" duration="+elapsed.String()+" outcome=ok")
+	}
+}
+
+//newSpanID 生成16位十六进制随机id
+func newSpanID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
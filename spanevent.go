@@ -0,0 +1,46 @@
+package gclog
+
+import (
+	"context"
+	"sync"
+)
+
+var (
+	spanMirrorLock  = new(sync.RWMutex)
+	spanMirror      func(ctx context.Context, level int, msg string, fields []Field) //span事件同步方法
+	spanMirrorLevel = WarningLevel                                                   //同步到span事件的最低级别
+)
+
+//SetSpanEventMirror 设置将ctx相关日志同步为当前span事件的方法，不低于minLevel的日志会调用mirror
+//用于在trace中直接看到span期间记录的错误，mirror为nil时关闭
+//exp: 对接OpenTelemetry
+//	gclog.SetSpanEventMirror(gclog.WarningLevel, func(ctx context.Context, level int, msg string, fields []gclog.Field) {
+//		span := trace.SpanFromContext(ctx)
+//		if !span.IsRecording() {
+//			return
+//		}
+//		attrs := []attribute.KeyValue{attribute.String("log.severity", gclog.LevelName(level))}
+//		for _, f := range fields {
+//			attrs = append(attrs, attribute.String(f.Key, fmt.Sprint(f.Value)))
+//		}
+//		span.AddEvent(msg, trace.WithAttributes(attrs...))
+//	})
+func SetSpanEventMirror(minLevel int, mirror func(ctx context.Context, level int, msg string, fields []Field)) {
+	spanMirrorLock.Lock()
+	defer spanMirrorLock.Unlock()
+	spanMirrorLevel = minLevel
+	spanMirror = mirror
+}
+
+//mirrorSpanEvent 将日志同步为span事件
+func mirrorSpanEvent(ctx context.Context, level int, msg string, fields []Field) {
+	if ctx == nil {
+		return
+	}
+	spanMirrorLock.RLock()
+	mirror, minLevel := spanMirror, spanMirrorLevel
+	spanMirrorLock.RUnlock()
+	if mirror != nil && level >= minLevel {
+		mirror(ctx, level, msg, fields)
+	}
+}
@@ -0,0 +1,269 @@
+package gclog
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//sqliteMaxBatch 一个事务写入的最多日志数
+const sqliteMaxBatch = 256
+
+//sqliteTimeLayout 时间列的格式，UTC，可以直接与SQLite的datetime()比较
+const sqliteTimeLayout = "2006-01-02 15:04:05.000000"
+
+//SQLiteOptions SQLite sink的设置
+type SQLiteOptions struct {
+	Table string //表名，为空时为logs
+	//MaxAge 日志保留时长，超出的日志定期删除，<=0时不按时间删除
+	MaxAge time.Duration
+	//MaxRows 最多保留的日志条数，超出时删除最早的日志，<=0时不按条数删除
+	MaxRows int64
+	//PruneInterval 删除过期日志的间隔，<=0时默认1分钟
+	PruneInterval time.Duration
+	QueueSize     int //写入队列长度，队列满时丢弃日志，<=0时默认4096
+}
+
+//SQLiteSink 将日志写入本地SQLite数据库的sink，小工具不需要部署日志系统即可用SQL查询结构化日志
+//标准库没有SQLite驱动，数据库由调用方用自己选择的驱动（mattn/go-sqlite3、modernc.org/sqlite等）打开
+//表不存在时自动创建，列为id、time、level、logger、caller、msg、fields（JSON对象）、stack、entry_id、seq，按time与level、time建索引
+//日志由后台goroutine按批次在一个事务中写入，并按MaxAge、MaxRows定期删除旧日志
+//exp:
+//
+//	SELECT time, msg, json_extract(fields, '$.user') FROM logs WHERE level >= 5 AND time > datetime('now', '-1 hour')
+type SQLiteSink struct {
+	db        *sql.DB
+	table     string
+	opts      SQLiteOptions
+	insert    string
+	queue     chan *sqliteRow
+	done      chan struct{}
+	closeOnce sync.Once
+	lastPrune time.Time //只在写入goroutine中使用
+	dropped   uint64    //丢弃的日志数，原子读写
+	written   uint64    //写入成功的日志数，原子读写
+}
+
+//sqliteRow 一条待写入的日志
+type sqliteRow struct {
+	time    string
+	level   int
+	logger  string
+	caller  string
+	msg     string
+	fields  string
+	stack   string
+	entryID string
+	seq     uint64
+}
+
+//NewSQLiteSink 创建写入db的SQLite sink，需要时建表与索引
+//exp:
+//
+//	db, err := sql.Open("sqlite3", "app-logs.db")
+//	s, err := gclog.NewSQLiteSink(db, gclog.SQLiteOptions{MaxAge: 7 * 24 * time.Hour})
+func NewSQLiteSink(db *sql.DB, opts SQLiteOptions) (*SQLiteSink, error) {
+	if opts.Table == "" {
+		opts.Table = "logs"
+	}
+	for _, c := range []byte(opts.Table) {
+		if !isAlnum(c) && c != '_' {
+			return nil, fmt.Errorf("gclog: invalid sqlite table name %q", opts.Table)
+		}
+	}
+	if opts.PruneInterval <= 0 {
+		opts.PruneInterval = time.Minute
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 4096
+	}
+	t := opts.Table
+	schema := []string{
+		"CREATE TABLE IF NOT EXISTS " + t + " (id INTEGER PRIMARY KEY AUTOINCREMENT, time TEXT NOT NULL, level INTEGER NOT NULL, " +
+			"logger TEXT, caller TEXT, msg TEXT, fields TEXT, stack TEXT, entry_id TEXT, seq INTEGER)",
+		"CREATE INDEX IF NOT EXISTS " + t + "_time ON " + t + " (time)",
+		"CREATE INDEX IF NOT EXISTS " + t + "_level_time ON " + t + " (level, time)",
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, err
+		}
+	}
+	s := &SQLiteSink{
+		db:     db,
+		table:  t,
+		opts:   opts,
+		insert: "INSERT INTO " + t + " (time, level, logger, caller, msg, fields, stack, entry_id, seq) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		queue:  make(chan *sqliteRow, opts.QueueSize),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+//SendToSQLite 创建SQLite sink并注册，同一表名重复调用时替换之前的sink
+//exp: gclog.SendToSQLite(db, gclog.SQLiteOptions{MaxRows: 1000000})
+func SendToSQLite(db *sql.DB, opts SQLiteOptions) (*SQLiteSink, error) {
+	s, err := NewSQLiteSink(db, opts)
+	if err != nil {
+		return nil, err
+	}
+	if old := addSink("sqlite:"+s.table, s); old != nil {
+		closeSink(old)
+	}
+	return s, nil
+}
+
+//Write 实现Sink，复制日志的各列后放入写入队列，队列满时丢弃并返回错误
+func (s *SQLiteSink) Write(e *Entry, line []byte) error {
+	row := &sqliteRow{
+		time:    e.Time.UTC().Format(sqliteTimeLayout),
+		level:   e.Level,
+		logger:  e.Logger,
+		msg:     e.Message,
+		stack:   e.Stack,
+		entryID: e.ID,
+		seq:     e.Seq,
+	}
+	if e.File != "" {
+		row.caller = fmt.Sprintf("%s:%d", formatCaller(e.File), e.Line)
+	}
+	if len(e.Fields) > 0 {
+		buf := getBuffer()
+		buf.b = append(buf.b, '{')
+		for i, f := range e.Fields {
+			if i > 0 {
+				buf.b = append(buf.b, ',')
+			}
+			buf.b = appendJSONString(buf.b, f.Key)
+			buf.b = append(buf.b, ':')
+			buf.b = appendJSONValue(buf.b, f.Value)
+		}
+		buf.b = append(buf.b, '}')
+		row.fields = string(buf.b)
+		putBuffer(buf)
+	}
+	select {
+	case s.queue <- row:
+		return nil
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+		return errSinkFull
+	}
+}
+
+//Close 实现Sink，写入队列中剩余的日志后返回，不关闭db
+func (s *SQLiteSink) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.queue)
+	})
+	<-s.done
+	return nil
+}
+
+//Dropped 返回因队列满或写入失败丢弃的日志数
+func (s *SQLiteSink) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+//Written 返回写入成功的日志数
+func (s *SQLiteSink) Written() uint64 {
+	return atomic.LoadUint64(&s.written)
+}
+
+//run 写入goroutine，阻塞等待第一条日志后合并队列中已有的日志在一个事务中写入
+func (s *SQLiteSink) run() {
+	defer close(s.done)
+	batch := make([]*sqliteRow, 0, sqliteMaxBatch)
+	var lastErr string
+	for {
+		row, ok := <-s.queue
+		if !ok {
+			break
+		}
+		batch = append(batch[:0], row)
+	drain:
+		for len(batch) < sqliteMaxBatch {
+			select {
+			case row, ok := <-s.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, row)
+			default:
+				break drain
+			}
+		}
+		err := s.insertBatch(batch)
+		if err == nil {
+			atomic.AddUint64(&s.written, uint64(len(batch)))
+			err = s.prune()
+		} else {
+			atomic.AddUint64(&s.dropped, uint64(len(batch)))
+		}
+		//相同的错误只提示一次，避免数据库不可写时刷屏
+		if err != nil && err.Error() != lastErr {
+			lastErr = err.Error()
+			Warning("sqlite sink %s failed, because %s", s.table, lastErr)
+		} else if err == nil {
+			lastErr = ""
+		}
+	}
+}
+
+//insertBatch 在一个事务中写入一批日志
+func (s *SQLiteSink) insertBatch(batch []*sqliteRow) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(s.insert)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, r := range batch {
+		if _, err := stmt.Exec(r.time, r.level, r.logger, r.caller, r.msg, nullString(r.fields), nullString(r.stack), nullString(r.entryID), r.seq); err != nil {
+			stmt.Close()
+			tx.Rollback()
+			return err
+		}
+	}
+	stmt.Close()
+	return tx.Commit()
+}
+
+//prune 距上次删除超过PruneInterval时按MaxAge、MaxRows删除旧日志
+func (s *SQLiteSink) prune() error {
+	if s.opts.MaxAge <= 0 && s.opts.MaxRows <= 0 {
+		return nil
+	}
+	now := time.Now()
+	if now.Sub(s.lastPrune) < s.opts.PruneInterval {
+		return nil
+	}
+	s.lastPrune = now
+	if s.opts.MaxAge > 0 {
+		cutoff := now.Add(-s.opts.MaxAge).UTC().Format(sqliteTimeLayout)
+		if _, err := s.db.Exec("DELETE FROM "+s.table+" WHERE time < ?", cutoff); err != nil {
+			return err
+		}
+	}
+	if s.opts.MaxRows > 0 {
+		//id自增，保留最新的MaxRows条
+		if _, err := s.db.Exec("DELETE FROM "+s.table+" WHERE id <= (SELECT MAX(id) FROM "+s.table+") - ?", s.opts.MaxRows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//nullString 空字符串写为NULL
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
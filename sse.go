@@ -0,0 +1,83 @@
+package gclog
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//sseKeepAlive 没有日志时发送注释行的间隔，防止代理断开空闲连接
+const sseKeepAlive = 15 * time.Second
+
+//EventStreamHandler 返回以Server-Sent Events推送新日志的http.Handler，每条日志为一个data为json的事件
+//参数与TailHandler相同：level、logger、q（子串）、regex、recent（默认0）
+//exp:
+//
+//	http.Handle("/debug/logs", gclog.EventStreamHandler())
+//	curl -N 'http://host/debug/logs?level=WARNING&q=timeout'
+func EventStreamHandler() http.Handler {
+	h := getTailHub()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseTailFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		recent, _ := strconv.Atoi(r.URL.Query().Get("recent"))
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		sub, history := h.subscribe(filter, 256, recent)
+		defer h.unsubscribe(sub)
+		var buf []byte
+		for i := range history {
+			buf = appendSSEEvent(buf[:0], &history[i])
+			if _, err := w.Write(buf); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		ticker := time.NewTicker(sseKeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case e, ok := <-sub.ch:
+				if !ok {
+					return
+				}
+				buf = appendSSEEvent(buf[:0], &e)
+				if _, err := w.Write(buf); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-ticker.C:
+				if _, err := w.Write([]byte(": keepalive\n\n")); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+}
+
+//appendSSEEvent 追加一个SSE事件，开启序号时以序号作为事件id
+func appendSSEEvent(buf []byte, e *Entry) []byte {
+	if e.Seq != 0 {
+		buf = append(buf, "id: "...)
+		buf = strconv.AppendUint(buf, e.Seq, 10)
+		buf = append(buf, '\n')
+	}
+	buf = append(buf, "data: "...)
+	buf = appendEntryJSON(buf, e)
+	return append(buf, "\n\n"...)
+}
@@ -0,0 +1,27 @@
+package gclog
+
+var (
+	stackTraceEnable bool //是否在日志后附加调用栈
+	stackTraceLevel  int  //附加调用栈的最低日志级别
+)
+
+//SetStackTraceLevel 设置附加调用栈的最低级别，不低于该级别的日志都会在末尾附加调用栈
+//exp: SetStackTraceLevel(ErrorLevel) 使所有error日志都带上调用栈
+func SetStackTraceLevel(level int) {
+	levelLock.Lock()
+	defer levelLock.Unlock()
+	stackTraceLevel = level
+	stackTraceEnable = true
+}
+
+//DisableStackTrace 关闭日志附加调用栈，默认关闭
+func DisableStackTrace() {
+	levelLock.Lock()
+	defer levelLock.Unlock()
+	stackTraceEnable = false
+}
+
+//needStackTrace 判断对应级别的日志是否需要附加调用栈
+func needStackTrace(level int) bool {
+	return stackTraceEnable && level >= stackTraceLevel
+}
@@ -0,0 +1,65 @@
+package gclog
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+//LoggerStatus 日志库当前的运行状态
+type LoggerStatus struct {
+	Level         int               //当前日志级别
+	WriteToFile   bool              //是否输出到文件
+	FileName      string            //日志文件名
+	SliceInterval time.Duration     //日志切分的时间间隔
+	StorageTime   time.Duration     //日志保存的时间
+	LastSliceTime time.Time         //上次文件流刷新的时间
+	Async         bool              //是否为异步写入
+	AsyncQueued   int               //异步队列中等待写入的日志数
+	AsyncWrites   uint64            //异步模式下的批量写入次数
+	Sinks         []string          //已注册的sink名称
+	SinkErrors    uint64            //sink写入失败的次数
+	HookErrors    uint64            //钩子返回错误的次数
+	Suppressed    map[string]uint64 //每条屏蔽规则丢弃的日志条数，key为正则
+	Sampled       uint64            //被采样丢弃的日志条数
+	DiskLow       bool              //是否因磁盘空间不足只输出error日志
+}
+
+//Status 返回日志库当前的运行状态
+func Status() LoggerStatus {
+	level := GetLogLevel()
+
+	asyncLock.RLock()
+	async, queued := asyncQueue != nil, 0
+	if async {
+		queued = asyncQueue.length()
+	}
+	asyncLock.RUnlock()
+
+	fileLock.RLock()
+	defer fileLock.RUnlock()
+	return LoggerStatus{
+		Level:         level,
+		WriteToFile:   writeToFile,
+		FileName:      fileName,
+		SliceInterval: logSliceInterval,
+		StorageTime:   logStorageTime,
+		LastSliceTime: logFileFlashTime,
+		Async:         async,
+		AsyncQueued:   queued,
+		AsyncWrites:   atomic.LoadUint64(&asyncWritten),
+		Sinks:         sinkNames(),
+		SinkErrors:    atomic.LoadUint64(&sinkErrors),
+		HookErrors:    atomic.LoadUint64(&hookErrors),
+		Suppressed:    suppressCounts(),
+		Sampled:       atomic.LoadUint64(&sampledOut),
+		DiskLow:       atomic.LoadInt32(&diskLow) == 1,
+	}
+}
+
+//String 输出可读的状态文本
+func (s LoggerStatus) String() string {
+	return fmt.Sprintf("level=%s write_to_file=%t file=%q slice_interval=%s storage_time=%s last_slice=%s async=%t async_queued=%d async_writes=%d sinks=%v sink_errors=%d hook_errors=%d suppressed=%v sampled=%d disk_low=%t",
+		LevelName(s.Level), s.WriteToFile, s.FileName, s.SliceInterval, s.StorageTime, s.LastSliceTime.Format(time.RFC3339),
+		s.Async, s.AsyncQueued, s.AsyncWrites, s.Sinks, s.SinkErrors, s.HookErrors, s.Suppressed, s.Sampled, s.DiskLow)
+}
@@ -0,0 +1,64 @@
+package gclog
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+//WriterSink 将编码后的日志写入任意io.Writer的sink，写入时加锁，writer不需要并发安全
+type WriterSink struct {
+	lock *sync.Mutex
+	w    io.Writer
+}
+
+//NewWriterSink 创建写入w的sink，w实现io.Closer时Close会关闭w
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{lock: new(sync.Mutex), w: w}
+}
+
+//Write 实现Sink
+func (s *WriterSink) Write(e *Entry, line []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, err := s.w.Write(line)
+	return err
+}
+
+//Close 实现Sink
+func (s *WriterSink) Close() error {
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+//stdoutSinkName SetAlsoLogToStdout注册的sink名称
+const stdoutSinkName = "stdout"
+
+//stdoutSink 写入文件的同时输出到标准输出，未写入文件时日志已经输出到控制台，不重复输出
+type stdoutSink struct{}
+
+//Write 实现Sink，在持有fileLock读锁时调用
+func (stdoutSink) Write(e *Entry, line []byte) error {
+	if !writeToFile {
+		return nil
+	}
+	_, err := os.Stdout.Write(line)
+	return err
+}
+
+//Close 实现Sink，标准输出不关闭
+func (stdoutSink) Close() error {
+	return nil
+}
+
+//SetAlsoLogToStdout 写入日志文件的同时是否输出到标准输出，默认不输出
+//容器中写文件时开启，kubectl logs等依赖标准输出的工具也能看到日志
+func SetAlsoLogToStdout(enable bool) {
+	if enable {
+		AddSink(stdoutSinkName, stdoutSink{})
+	} else {
+		RemoveSink(stdoutSinkName)
+	}
+}
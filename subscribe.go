@@ -0,0 +1,26 @@
+package gclog
+
+import "sync"
+
+//subscribeQueueSize 订阅队列长度，消费不及时时新日志被丢弃，不阻塞写日志
+const subscribeQueueSize = 1024
+
+//Subscribe 订阅minLevel及以上级别的日志，返回的Entry是复制后的副本，可以长期持有
+//消费不及时、队列满时新日志被丢弃；调用cancel取消订阅，之后通道被关闭，cancel可以重复调用
+//exp:
+//
+//	ch, cancel := gclog.Subscribe(gclog.ErrorLevel)
+//	defer cancel()
+//	for e := range ch {
+//		alert(e.Message)
+//	}
+func Subscribe(minLevel int) (<-chan Entry, func()) {
+	h := getTailHub()
+	sub, _ := h.subscribe(tailFilter{minLevel: minLevel}, subscribeQueueSize, 0)
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			h.unsubscribe(sub)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package gclog
+
+import (
+	"regexp"
+	"sync"
+	"sync/atomic"
+)
+
+//suppressRule 屏蔽规则，count为匹配后被丢弃的日志条数，原子读写
+type suppressRule struct {
+	re    *regexp.Regexp
+	count *uint64
+}
+
+var (
+	suppressLock  = new(sync.Mutex) //修改屏蔽规则时加锁
+	suppressRules atomic.Value      //当前的屏蔽规则，[]suppressRule，修改时整体替换，写日志时无锁读取
+)
+
+//SuppressMessages 添加屏蔽规则，消息匹配任一正则的日志被丢弃（fatal日志除外），用于屏蔽第三方库已知的无用日志
+//每条规则被丢弃的日志条数可以通过Status().Suppressed查看，重复添加相同的正则时保留原有计数
+//任一正则无法编译时返回错误，不添加任何规则
+//exp: gclog.SuppressMessages(`^redis: connection pool timeout`, `TLS handshake error from .*: EOF`)
+func SuppressMessages(patterns ...string) error {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return err
+		}
+		compiled = append(compiled, re)
+	}
+	suppressLock.Lock()
+	defer suppressLock.Unlock()
+	old := loadSuppressRules()
+	rules := append(make([]suppressRule, 0, len(old)+len(compiled)), old...)
+next:
+	for _, re := range compiled {
+		for _, r := range rules {
+			if r.re.String() == re.String() {
+				continue next
+			}
+		}
+		rules = append(rules, suppressRule{re: re, count: new(uint64)})
+	}
+	suppressRules.Store(rules)
+	return nil
+}
+
+//ClearSuppressions 移除所有屏蔽规则，计数一并清除
+func ClearSuppressions() {
+	suppressLock.Lock()
+	defer suppressLock.Unlock()
+	suppressRules.Store([]suppressRule(nil))
+}
+
+//loadSuppressRules 取当前的屏蔽规则，返回的切片不能修改
+func loadSuppressRules() []suppressRule {
+	rules, _ := suppressRules.Load().([]suppressRule)
+	return rules
+}
+
+//suppressed 判断消息是否需要屏蔽，匹配时对应规则计数加一
+func suppressed(msg string) bool {
+	for _, r := range loadSuppressRules() {
+		if r.re.MatchString(msg) {
+			atomic.AddUint64(r.count, 1)
+			return true
+		}
+	}
+	return false
+}
+
+//suppressCounts 返回每条屏蔽规则丢弃的日志条数，没有规则时返回nil
+func suppressCounts() map[string]uint64 {
+	rules := loadSuppressRules()
+	if len(rules) == 0 {
+		return nil
+	}
+	counts := make(map[string]uint64, len(rules))
+	for _, r := range rules {
+		counts[r.re.String()] = atomic.LoadUint64(r.count)
+	}
+	return counts
+}
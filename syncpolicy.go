@@ -0,0 +1,30 @@
+package gclog
+
+import "sync/atomic"
+
+//syncLevel 达到该级别的日志写入后立即落盘，大于FatalLevel时不落盘，原子读写
+var syncLevel = int32(FatalLevel + 1)
+
+//SetSyncLevel 设置写入后立即fsync落盘的最低级别，对主日志文件与RouteLevels的文件生效，level超出范围时关闭，默认关闭
+//低级别日志仍由操作系统缓冲，兼顾吞吐与错误日志的可靠性；异步模式下会等待队列中的日志写入后再落盘
+//exp: gclog.SetSyncLevel(gclog.ErrorLevel)
+func SetSyncLevel(level int) {
+	if level < VerbLevel || level > FatalLevel {
+		level = FatalLevel + 1
+	}
+	atomic.StoreInt32(&syncLevel, int32(level))
+}
+
+//needSync 判断对应级别的日志写入后是否需要落盘
+func needSync(level int) bool {
+	return level >= int(atomic.LoadInt32(&syncLevel))
+}
+
+//syncLogFile 将主日志文件落盘，没有写入文件时忽略
+func syncLogFile() {
+	fileLock.RLock()
+	if writeToFile {
+		logFile.Sync()
+	}
+	fileLock.RUnlock()
+}
@@ -0,0 +1,236 @@
+package gclog
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//SyslogFormat syslog消息格式
+type SyslogFormat int
+
+const (
+	//SyslogRFC5424 RFC5424格式，字段写入STRUCTURED-DATA（默认）
+	//exp: <11>1 2018-04-08T16:00:00.123456+08:00 host app 1234 - [fields@32473 user="42"] login failed
+	SyslogRFC5424 SyslogFormat = iota
+	//SyslogRFC3164 BSD syslog格式，字段以key=value追加在消息之后
+	//exp: <11>Apr  8 16:00:00 host app[1234]: login failed user=42
+	SyslogRFC3164
+)
+
+//SyslogFraming TCP连接上的消息分帧方式，UDP时每个数据报一条消息，不分帧
+type SyslogFraming int
+
+const (
+	//SyslogOctetCounting RFC6587 octet-counting，消息前加“长度 ”，消息可以包含换行（默认）
+	SyslogOctetCounting SyslogFraming = iota
+	//SyslogNewline 以换行分隔，消息中的换行替换为空格
+	SyslogNewline
+)
+
+//syslogSDID 字段所在的STRUCTURED-DATA ID
+const syslogSDID = "fields@32473"
+
+//syslogMaxDatagram UDP消息的最大长度，超出时截断
+const syslogMaxDatagram = 65000
+
+//SyslogFacilityUser、SyslogFacilityLocal0 常用的facility，local1~local7依次加1
+const (
+	SyslogFacilityUser   = 1
+	SyslogFacilityLocal0 = 16
+)
+
+//syslogSeverity 日志级别对应的syslog severity
+var syslogSeverity = []int{
+	VerbLevel:    7,
+	DebugLevel:   7,
+	InfoLevel:    6,
+	NoticeLevel:  5,
+	WarningLevel: 4,
+	ErrorLevel:   3,
+	FatalLevel:   2,
+}
+
+//SyslogOptions 远程syslog的设置
+type SyslogOptions struct {
+	//Net 连接设置（TLS、队列长度、超时），其中的Frame与Compression不使用
+	Net      NetOptions
+	Format   SyslogFormat
+	Framing  SyslogFraming
+	Facility int    //facility，为0时默认SyslogFacilityUser
+	Hostname string //为空时使用os.Hostname
+	AppName  string //为空时使用进程名
+}
+
+//NewSyslogSink 创建发送到远程syslog（rsyslog、syslog-ng等）的sink，network为udp、tcp等，TCP断线后自动重连
+//exp: s, err := gclog.NewSyslogSink("tcp", "syslog.example.com:514", gclog.SyslogOptions{Facility: gclog.SyslogFacilityLocal0})
+func NewSyslogSink(network, addr string, opts SyslogOptions) (*NetSink, error) {
+	if opts.Facility <= 0 {
+		opts.Facility = SyslogFacilityUser
+	}
+	if opts.Hostname == "" {
+		if host, err := os.Hostname(); err == nil {
+			opts.Hostname = host
+		} else {
+			opts.Hostname = "-"
+		}
+	}
+	if opts.AppName == "" {
+		opts.AppName = filepath.Base(os.Args[0])
+	}
+	pid := strconv.Itoa(os.Getpid())
+	datagram := isDatagram(network)
+	netOpts := opts.Net
+	netOpts.Compression = CompressNone
+	netOpts.Frame = func(dst []byte, e *Entry, line []byte) []byte {
+		start := len(dst)
+		if opts.Format == SyslogRFC3164 {
+			dst = appendSyslog3164(dst, &opts, pid, e)
+		} else {
+			dst = appendSyslog5424(dst, &opts, pid, e)
+		}
+		switch {
+		case datagram:
+			if len(dst)-start > syslogMaxDatagram {
+				dst = dst[:start+syslogMaxDatagram]
+			}
+		case opts.Framing == SyslogNewline:
+			for i := start; i < len(dst); i++ {
+				if dst[i] == '\n' || dst[i] == '\r' {
+					dst[i] = ' '
+				}
+			}
+			dst = append(dst, '\n')
+		default:
+			//在消息之前插入长度
+			size := strconv.Itoa(len(dst)-start) + " "
+			dst = append(dst, size...)
+			copy(dst[start+len(size):], dst[start:len(dst)-len(size)])
+			copy(dst[start:], size)
+		}
+		return dst
+	}
+	return NewNetSink(network, addr, netOpts)
+}
+
+//SendToSyslog 创建远程syslog sink并注册，同一地址重复调用时替换之前的sink
+//exp: gclog.SendToSyslog("udp", "10.0.0.1:514", gclog.SyslogOptions{Format: gclog.SyslogRFC3164})
+func SendToSyslog(network, addr string, opts SyslogOptions) (*NetSink, error) {
+	s, err := NewSyslogSink(network, addr, opts)
+	if err != nil {
+		return nil, err
+	}
+	if old := addSink("syslog:"+addr, s); old != nil {
+		closeSink(old)
+	}
+	return s, nil
+}
+
+//syslogPriority 返回PRI
+func syslogPriority(facility, level int) int {
+	severity := 7
+	if level >= VerbLevel && level < len(syslogSeverity) {
+		severity = syslogSeverity[level]
+	}
+	return facility*8 + severity
+}
+
+//appendSyslog5424 追加RFC5424格式的消息
+func appendSyslog5424(dst []byte, opts *SyslogOptions, pid string, e *Entry) []byte {
+	dst = append(dst, '<')
+	dst = strconv.AppendInt(dst, int64(syslogPriority(opts.Facility, e.Level)), 10)
+	dst = append(dst, ">1 "...)
+	dst = e.Time.AppendFormat(dst, "2006-01-02T15:04:05.000000Z07:00")
+	dst = append(dst, ' ')
+	dst = appendSyslogName(dst, opts.Hostname, 255)
+	dst = append(dst, ' ')
+	dst = appendSyslogName(dst, opts.AppName, 48)
+	dst = append(dst, ' ')
+	dst = append(dst, pid...)
+	dst = append(dst, ' ')
+	dst = appendSyslogName(dst, e.Logger, 32)
+	dst = append(dst, ' ')
+	if len(e.Fields) == 0 {
+		dst = append(dst, '-')
+	} else {
+		dst = append(dst, "["+syslogSDID...)
+		for _, f := range e.Fields {
+			dst = append(dst, ' ')
+			dst = appendSyslogParamName(dst, f.Key)
+			dst = append(dst, `="`...)
+			dst = appendSyslogParamValue(dst, formatCEFValue(f.Value))
+			dst = append(dst, '"')
+		}
+		dst = append(dst, ']')
+	}
+	dst = append(dst, ' ')
+	return appendSyslogMsg(dst, e)
+}
+
+//appendSyslog3164 追加RFC3164格式的消息
+func appendSyslog3164(dst []byte, opts *SyslogOptions, pid string, e *Entry) []byte {
+	dst = append(dst, '<')
+	dst = strconv.AppendInt(dst, int64(syslogPriority(opts.Facility, e.Level)), 10)
+	dst = append(dst, '>')
+	dst = e.Time.AppendFormat(dst, time.Stamp)
+	dst = append(dst, ' ')
+	dst = appendSyslogName(dst, opts.Hostname, 255)
+	dst = append(dst, ' ')
+	dst = appendSyslogName(dst, opts.AppName, 32)
+	dst = append(dst, '[')
+	dst = append(dst, pid...)
+	dst = append(dst, "]: "...)
+	dst = appendSyslogMsg(dst, e)
+	return appendFields(dst, e.Fields)
+}
+
+//appendSyslogMsg 追加消息与调用栈
+func appendSyslogMsg(dst []byte, e *Entry) []byte {
+	dst = append(dst, strings.TrimRight(e.Message, "\n")...)
+	if e.Stack != "" {
+		dst = append(dst, "\nstack:\n"...)
+		dst = append(dst, strings.TrimRight(e.Stack, "\n")...)
+	}
+	return dst
+}
+
+//appendSyslogName 追加HOSTNAME、APP-NAME等头部字段，只保留可打印ASCII字符，为空时为“-”
+func appendSyslogName(dst []byte, s string, max int) []byte {
+	start := len(dst)
+	for i := 0; i < len(s) && len(dst)-start < max; i++ {
+		if c := s[i]; c > ' ' && c < 0x7f {
+			dst = append(dst, c)
+		}
+	}
+	if len(dst) == start {
+		dst = append(dst, '-')
+	}
+	return dst
+}
+
+//appendSyslogParamName 追加STRUCTURED-DATA的参数名，去掉不允许的字符，最长32个字符
+func appendSyslogParamName(dst []byte, s string) []byte {
+	start := len(dst)
+	for i := 0; i < len(s) && len(dst)-start < 32; i++ {
+		if c := s[i]; c > ' ' && c < 0x7f && c != '=' && c != ']' && c != '"' {
+			dst = append(dst, c)
+		}
+	}
+	if len(dst) == start {
+		dst = append(dst, '_')
+	}
+	return dst
+}
+
+//appendSyslogParamValue 追加STRUCTURED-DATA的参数值，转义引号、反斜杠与右方括号
+func appendSyslogParamValue(dst []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c == '"' || c == '\\' || c == ']' {
+			dst = append(dst, '\\')
+		}
+		dst = append(dst, s[i])
+	}
+	return dst
+}
@@ -0,0 +1,193 @@
+package gclog
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+//tailSinkName 实时查看使用的sink名称
+const tailSinkName = "tail"
+
+//defaultTailRecent 默认保留的最近日志条数
+const defaultTailRecent = 1000
+
+//tailHub 实时查看日志的中心，作为sink接收日志，保留最近的日志并分发给订阅者
+type tailHub struct {
+	lock   *sync.Mutex
+	recent []Entry //最近的日志，环形保存
+	next   int     //recent中下一个写入位置
+	full   bool    //recent是否已写满一轮
+	subs   map[*tailSubscriber]struct{}
+}
+
+//tailSubscriber 一个订阅者，队列满时丢弃日志，不阻塞写日志
+type tailSubscriber struct {
+	ch      chan Entry
+	filter  tailFilter
+	dropped uint64 //由tailHub.lock保护
+}
+
+var (
+	tailOnce sync.Once
+	hub      *tailHub
+)
+
+//getTailHub 取实时查看中心，第一次调用时注册为sink，不使用实时查看时没有任何开销
+func getTailHub() *tailHub {
+	tailOnce.Do(func() {
+		hub = &tailHub{lock: new(sync.Mutex), recent: make([]Entry, defaultTailRecent), subs: make(map[*tailSubscriber]struct{})}
+		addSink(tailSinkName, hub)
+	})
+	return hub
+}
+
+//copyEntry 复制日志，Entry来自对象池，投递给其他goroutine前需要复制
+func copyEntry(e *Entry) Entry {
+	c := *e
+	if len(e.Fields) > 0 {
+		c.Fields = append([]Field(nil), e.Fields...)
+	}
+	return c
+}
+
+//Write 实现Sink
+func (h *tailHub) Write(e *Entry, line []byte) error {
+	c := copyEntry(e)
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.recent[h.next] = c
+	h.next++
+	if h.next == len(h.recent) {
+		h.next, h.full = 0, true
+	}
+	for s := range h.subs {
+		if !s.filter.match(&c) {
+			continue
+		}
+		select {
+		case s.ch <- c:
+		default:
+			s.dropped++
+		}
+	}
+	return nil
+}
+
+//Close 实现Sink，关闭所有订阅者
+func (h *tailHub) Close() error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for s := range h.subs {
+		close(s.ch)
+		delete(h.subs, s)
+	}
+	return nil
+}
+
+//subscribe 添加订阅者，返回符合条件的最近recent条日志
+func (h *tailHub) subscribe(filter tailFilter, size, recent int) (*tailSubscriber, []Entry) {
+	s := &tailSubscriber{ch: make(chan Entry, size), filter: filter}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	var history []Entry
+	if recent > 0 {
+		history = h.recentLocked(filter, recent)
+	}
+	h.subs[s] = struct{}{}
+	return s, history
+}
+
+//unsubscribe 移除订阅者并关闭其队列
+func (h *tailHub) unsubscribe(s *tailSubscriber) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.ch)
+	}
+}
+
+//setFilter 修改订阅者的过滤条件
+func (h *tailHub) setFilter(s *tailSubscriber, filter tailFilter) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	s.filter = filter
+}
+
+//recentLocked 按时间顺序返回最近n条符合条件的日志，需要在持有lock时调用
+func (h *tailHub) recentLocked(filter tailFilter, n int) []Entry {
+	var all []Entry
+	if h.full {
+		all = append(all, h.recent[h.next:]...)
+	}
+	all = append(all, h.recent[:h.next]...)
+	var out []Entry
+	for i := len(all) - 1; i >= 0 && len(out) < n; i-- {
+		if filter.match(&all[i]) {
+			out = append(out, all[i])
+		}
+	}
+	//倒序收集，翻转为时间顺序
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+//tailFilter 实时查看的过滤条件
+type tailFilter struct {
+	minLevel int            //最低级别
+	logger   string         //logger名称，为空时不限制
+	contains string         //消息包含的子串，为空时不限制
+	re       *regexp.Regexp //消息匹配的正则，为nil时不限制
+}
+
+//match 判断日志是否符合过滤条件
+func (f *tailFilter) match(e *Entry) bool {
+	if e.Level < f.minLevel {
+		return false
+	}
+	if f.logger != "" && e.Logger != f.logger {
+		return false
+	}
+	if f.contains != "" && !strings.Contains(e.Message, f.contains) {
+		return false
+	}
+	if f.re != nil && !f.re.MatchString(e.Message) {
+		return false
+	}
+	return true
+}
+
+//parseTailFilter 从参数中解析过滤条件：level（名称或数字）、logger、q（子串）、regex
+func parseTailFilter(values url.Values) (tailFilter, error) {
+	var f tailFilter
+	if level := values.Get("level"); level != "" {
+		l, ok := ParseLevel(level)
+		if !ok {
+			return f, fmt.Errorf("gclog: unknown level %q", level)
+		}
+		f.minLevel = l
+	}
+	f.logger = values.Get("logger")
+	f.contains = values.Get("q")
+	if expr := values.Get("regex"); expr != "" {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return f, err
+		}
+		f.re = re
+	}
+	return f, nil
+}
+
+//appendEntryJSON 将日志编码为一行json（不含换行）
+func appendEntryJSON(buf []byte, e *Entry) []byte {
+	fileLock.RLock()
+	defer fileLock.RUnlock()
+	buf = (&JSONEncoder{}).Encode(buf, e)
+	return buf[:len(buf)-1]
+}
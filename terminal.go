@@ -0,0 +1,70 @@
+package gclog
+
+import (
+	"os"
+	"sync"
+)
+
+//ColorMode 终端颜色模式
+type ColorMode int
+
+const (
+	//ColorAuto 自动判断，输出到终端时使用颜色，遵守NO_COLOR、FORCE_COLOR环境变量
+	ColorAuto ColorMode = iota
+	//ColorAlways 总是使用颜色
+	ColorAlways
+	//ColorNever 从不使用颜色
+	ColorNever
+)
+
+var (
+	colorEnvOnce  sync.Once
+	colorEnvForce bool //FORCE_COLOR
+	colorEnvNo    bool //NO_COLOR
+	stderrTTYOnce sync.Once
+	stderrIsTTY   bool //标准错误是否为终端
+)
+
+//IsTerminal 判断文件是否为终端
+func IsTerminal(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	return isTerminal(f.Fd())
+}
+
+//loadColorEnv 读取NO_COLOR、FORCE_COLOR环境变量，见https://no-color.org
+func loadColorEnv() {
+	colorEnvNo = os.Getenv("NO_COLOR") != ""
+	force := os.Getenv("FORCE_COLOR")
+	colorEnvForce = force != "" && force != "0" && force != "false"
+}
+
+//useColor 根据颜色模式、环境变量和当前输出目标判断是否输出颜色，需要在持有fileLock时调用
+func useColor(mode ColorMode) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	colorEnvOnce.Do(loadColorEnv)
+	if colorEnvNo {
+		return false
+	}
+	if colorEnvForce {
+		return true
+	}
+	return consoleIsTerminal()
+}
+
+//consoleIsTerminal 当前输出是否为终端，需要在持有fileLock时调用
+func consoleIsTerminal() bool {
+	if writeToFile {
+		return false
+	}
+	stderrTTYOnce.Do(func() {
+		stderrIsTTY = IsTerminal(os.Stderr)
+	})
+	return stderrIsTTY
+}
@@ -0,0 +1,13 @@
+package gclog
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+//isTerminal 通过TCGETS判断fd是否为终端
+func isTerminal(fd uintptr) bool {
+	var termios syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCGETS, uintptr(unsafe.Pointer(&termios)))
+	return errno == 0
+}
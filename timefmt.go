@@ -0,0 +1,129 @@
+package gclog
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	//TimeEpochMillis 时间输出为unix毫秒时间戳
+	TimeEpochMillis = "epoch_millis"
+	//TimeRFC3339Milli 精确到毫秒的RFC3339格式
+	TimeRFC3339Milli = "2006-01-02T15:04:05.000Z07:00"
+)
+
+//TimePrecision 日志时间精度
+type TimePrecision int
+
+const (
+	//TimePrecisionDefault 使用各编码器默认的精度
+	TimePrecisionDefault TimePrecision = iota
+	//TimeSecond 精确到秒
+	TimeSecond
+	//TimeMilli 精确到毫秒
+	TimeMilli
+	//TimeMicro 精确到微秒
+	TimeMicro
+	//TimeNano 精确到纳秒
+	TimeNano
+)
+
+//precisionFraction 各精度对应的时间格式小数部分
+var precisionFraction = []string{
+	TimeSecond: "",
+	TimeMilli:  ".000",
+	TimeMicro:  ".000000",
+	TimeNano:   ".000000000",
+}
+
+var (
+	timeLayout    string          //日志时间格式，为空时使用各编码器的默认格式，由fileLock保护
+	timePrecision TimePrecision   //日志时间精度，由fileLock保护
+	layoutCache   = new(sync.Map) //编码器默认格式应用精度后的格式，写日志时并发读写
+)
+
+//SetTimePrecision 设置各编码器默认时间格式的精度，便于区分同一秒内的日志顺序
+//通过SetTimeLayout设置了自定义格式时，以自定义格式为准
+//exp: gclog.SetTimePrecision(gclog.TimeMicro) 输出 2018/04/08 16:00:00.123456
+func SetTimePrecision(precision TimePrecision) {
+	fileLock.Lock()
+	defer fileLock.Unlock()
+	timePrecision = precision
+	layoutCache = new(sync.Map)
+}
+
+//applyPrecision 将默认格式中秒之后的小数部分替换为当前精度，需要在持有fileLock时调用
+func applyPrecision(layout string) string {
+	if timePrecision <= TimePrecisionDefault || int(timePrecision) >= len(precisionFraction) {
+		return layout
+	}
+	if cached, ok := layoutCache.Load(layout); ok {
+		return cached.(string)
+	}
+	result := layout
+	if i := strings.Index(layout, "05"); i >= 0 {
+		//去掉原有的小数部分
+		j := i + 2
+		if j < len(layout) && (layout[j] == '.' || layout[j] == ',') {
+			k := j + 1
+			for k < len(layout) && (layout[k] == '0' || layout[k] == '9') {
+				k++
+			}
+			if k > j+1 {
+				j = k
+			}
+		}
+		result = layout[:i+2] + precisionFraction[timePrecision] + layout[j:]
+	}
+	layoutCache.Store(layout, result)
+	return result
+}
+
+//timeLocation 日志时间与切分文件名使用的时区，未设置时使用本地时区
+var timeLocation atomic.Value
+
+//SetTimeLocation 设置日志时间与切分日志文件名使用的时区，nil表示本地时区
+//exp: gclog.SetTimeLocation(time.UTC)
+func SetTimeLocation(loc *time.Location) {
+	if loc == nil {
+		loc = time.Local
+	}
+	timeLocation.Store(loc)
+}
+
+//SetUTC 日志时间与切分日志文件名统一使用UTC
+func SetUTC() {
+	SetTimeLocation(time.UTC)
+}
+
+//now 取当前时区下的当前时间
+func now() time.Time {
+	t := time.Now()
+	if loc, ok := timeLocation.Load().(*time.Location); ok {
+		t = t.In(loc)
+	}
+	return t
+}
+
+//SetTimeLayout 设置所有编码器的日志时间格式，layout为go的时间格式或TimeEpochMillis，为空时恢复各编码器的默认格式
+//exp: gclog.SetTimeLayout(gclog.TimeRFC3339Milli)
+func SetTimeLayout(layout string) {
+	fileLock.Lock()
+	defer fileLock.Unlock()
+	timeLayout = layout
+}
+
+//appendTime 按当前时间格式追加时间，未设置时使用defaultLayout，需要在持有fileLock时调用
+func appendTime(buf []byte, t time.Time, defaultLayout string) []byte {
+	layout := timeLayout
+	if layout == "" {
+		layout = applyPrecision(defaultLayout)
+	}
+	if layout == TimeEpochMillis {
+		return strconv.AppendInt(buf, t.UnixNano()/int64(time.Millisecond), 10)
+	}
+	return t.AppendFormat(buf, layout)
+}
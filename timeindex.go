@@ -0,0 +1,90 @@
+package gclog
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+//TimeIndexSuffix 时间索引文件的后缀，索引文件与日志文件同名加该后缀，exp: test.log.idx
+const TimeIndexSuffix = ".idx"
+
+//TimeIndexRecordSize 时间索引中每条记录的长度
+//记录为8字节大端unix纳秒时间 + 8字节大端文件偏移，表示该偏移之前的日志都在该时间之前写入
+const TimeIndexRecordSize = 16
+
+//timeIndexInterval 写入时间索引记录的最小间隔（纳秒），为0时不写索引，原子读写
+var timeIndexInterval int64
+
+//SetTimeIndex 开启时间索引，每隔interval在日志文件旁的索引文件中记录一次时间与文件偏移，interval<=0时关闭，默认关闭
+//reader.SeekTime与gclogcat -since根据索引直接定位到时间范围的开始，不需要从头扫描大文件
+//索引随日志文件切分与删除，只对未加密的主日志文件与RouteLevels的文件生效
+//exp: gclog.SetTimeIndex(10 * time.Second)
+func SetTimeIndex(interval time.Duration) {
+	if interval < 0 {
+		interval = 0
+	}
+	atomic.StoreInt64(&timeIndexInterval, int64(interval))
+}
+
+//indexWriter 写入日志文件，按间隔在索引文件中记录写入前的文件偏移
+type indexWriter struct {
+	file *os.File
+	w    io.Writer
+	last int64 //上一次记录索引的时间，原子读写
+}
+
+//indexedWriter 开启时间索引时为日志文件的写入目标增加索引记录
+func indexedWriter(file *os.File, w io.Writer) io.Writer {
+	if atomic.LoadInt64(&timeIndexInterval) <= 0 {
+		return w
+	}
+	return &indexWriter{file: file, w: w}
+}
+
+//Write 实现io.Writer
+func (w *indexWriter) Write(p []byte) (int, error) {
+	if interval := atomic.LoadInt64(&timeIndexInterval); interval > 0 {
+		last := atomic.LoadInt64(&w.last)
+		t := now().UnixNano()
+		if t-last >= interval && atomic.CompareAndSwapInt64(&w.last, last, t) {
+			w.mark()
+		}
+	}
+	return w.w.Write(p)
+}
+
+//mark 记录当前的文件大小，时间在取大小之后获取，保证偏移之前的日志时间都不晚于记录的时间
+//文件已被切分（改名）时不记录，避免写入新文件的索引
+func (w *indexWriter) mark() {
+	info, err := w.file.Stat()
+	if err != nil {
+		return
+	}
+	t := now().UnixNano()
+	name := w.file.Name()
+	if !isCurrentFile(w.file, name) {
+		return
+	}
+	idx, err := createFile(name+TimeIndexSuffix, os.O_APPEND|os.O_WRONLY)
+	if err != nil {
+		return
+	}
+	var record [TimeIndexRecordSize]byte
+	binary.BigEndian.PutUint64(record[:8], uint64(t))
+	binary.BigEndian.PutUint64(record[8:], uint64(info.Size()))
+	idx.Write(record[:])
+	idx.Close()
+}
+
+//renameTimeIndex 日志文件切分时将索引文件一起改名
+func renameTimeIndex(filename, target string) {
+	os.Rename(filename+TimeIndexSuffix, target+TimeIndexSuffix)
+}
+
+//removeTimeIndex 删除日志文件时一起删除索引文件
+func removeTimeIndex(filename string) {
+	os.Remove(filename + TimeIndexSuffix)
+}
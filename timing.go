@@ -0,0 +1,30 @@
+package gclog
+
+import "time"
+
+//slowThreshold TimeTrack的慢操作阈值，<=0表示不判断
+var slowThreshold time.Duration
+
+//SetSlowThreshold 设置TimeTrack的慢操作阈值，耗时超过阈值时日志升级为warning，<=0表示关闭
+func SetSlowThreshold(threshold time.Duration) {
+	slowThreshold = threshold
+}
+
+//TimeTrack 记录一段操作的耗时，结束时以debug级别输出，超过慢操作阈值时以warning级别输出
+//exp: defer gclog.TimeTrack("load users")()
+func TimeTrack(name string) func() {
+	start := time.Now()
+	return func() {
+		elapsed := time.Since(start)
+		threshold := slowThreshold
+		if threshold > 0 && elapsed > threshold {
+			if levelEnabled(WarningLevel) {
+				writeLog(WarningLevel, name+" slow, took "+elapsed.String()+" (threshold "+threshold.String()+")")
+			}
+			return
+		}
+		if levelEnabled(DebugLevel) {
+			writeLog(DebugLevel, name+" took "+elapsed.String())
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package gclog
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+)
+
+var errCertNotPinned = errors.New("gclog: server certificate is not pinned")
+
+//TLSOptions 网络sink的TLS设置
+type TLSOptions struct {
+	CAFile       string   //PEM格式的CA证书，用于校验服务端证书，为空时使用系统根证书
+	PinnedSHA256 []string //服务端证书公钥（SubjectPublicKeyInfo）的sha256（hex），设置后只接受这些公钥
+	CertFile     string   //PEM格式的客户端证书，与KeyFile一起设置时开启双向认证
+	KeyFile      string   //PEM格式的客户端私钥
+	ServerName   string   //校验的服务端名称，为空时使用连接地址中的主机名
+}
+
+//config 生成tls.Config，host为连接地址中的主机名
+func (o *TLSOptions) config(host string) (*tls.Config, error) {
+	conf := &tls.Config{ServerName: o.ServerName, MinVersion: tls.VersionTLS12}
+	if conf.ServerName == "" {
+		conf.ServerName = host
+	}
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("gclog: no certificate found in " + o.CAFile)
+		}
+		conf.RootCAs = pool
+	}
+	if o.CertFile != "" || o.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	if len(o.PinnedSHA256) > 0 {
+		pins := make(map[string]bool, len(o.PinnedSHA256))
+		for _, p := range o.PinnedSHA256 {
+			pins[strings.ToLower(strings.Replace(p, ":", "", -1))] = true
+		}
+		//在证书链校验通过之后，再校验服务端证书的公钥
+		conf.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errCertNotPinned
+			}
+			leaf, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			sum := sha256.Sum256(leaf.RawSubjectPublicKeyInfo)
+			if !pins[hex.EncodeToString(sum[:])] {
+				return errCertNotPinned
+			}
+			return nil
+		}
+	}
+	return conf, nil
+}
@@ -0,0 +1,75 @@
+package gclog
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+)
+
+//TraceContext W3C trace context中的trace id与span id
+type TraceContext struct {
+	TraceID string //32位十六进制
+	SpanID  string //16位十六进制
+	Flags   string //2位十六进制，01表示sampled
+}
+
+//traceExtractor 从context中取trace信息的扩展方法，用于对接OpenTelemetry等
+var traceExtractor func(ctx context.Context) (traceID string, spanID string, ok bool)
+
+//SetTraceExtractor 设置从context中取trace id与span id的方法，用于对接OpenTelemetry等tracing库
+//exp:
+//	gclog.SetTraceExtractor(func(ctx context.Context) (string, string, bool) {
+//		sc := trace.SpanContextFromContext(ctx)
+//		return sc.TraceID().String(), sc.SpanID().String(), sc.IsValid()
+//	})
+func SetTraceExtractor(extractor func(ctx context.Context) (traceID string, spanID string, ok bool)) {
+	traceExtractor = extractor
+}
+
+//ParseTraceparent 解析W3C traceparent头，格式: version-traceid-spanid-flags
+//exp: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func ParseTraceparent(header string) (TraceContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return TraceContext{}, false
+	}
+	//version 00只允许4段
+	if parts[0] == "00" && len(parts) != 4 {
+		return TraceContext{}, false
+	}
+	for _, p := range parts[:4] {
+		if _, err := hex.DecodeString(p); err != nil || strings.ToLower(p) != p {
+			return TraceContext{}, false
+		}
+	}
+	//全0的trace id和span id无效
+	if parts[1] == strings.Repeat("0", 32) || parts[2] == strings.Repeat("0", 16) {
+		return TraceContext{}, false
+	}
+	return TraceContext{TraceID: parts[1], SpanID: parts[2], Flags: parts[3]}, true
+}
+
+//WithTraceparent 解析traceparent头并保存到context中，头无效时原样返回ctx
+//exp: ctx = gclog.WithTraceparent(r.Context(), r.Header.Get("traceparent"))
+func WithTraceparent(ctx context.Context, header string) context.Context {
+	tc, ok := ParseTraceparent(header)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, traceContextKey, tc)
+}
+
+//TraceFromContext 取context中的trace信息，优先使用SetTraceExtractor设置的方法
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+	if ctx == nil {
+		return TraceContext{}, false
+	}
+	if extractor := traceExtractor; extractor != nil {
+		if traceID, spanID, ok := extractor(ctx); ok {
+			return TraceContext{TraceID: traceID, SpanID: spanID}, true
+		}
+	}
+	tc, ok := ctx.Value(traceContextKey).(TraceContext)
+	return tc, ok
+}
@@ -0,0 +1,100 @@
+package gclog
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+//Transformer 日志变换函数，在编码前修改日志，可以修改字段、改写消息、调整级别
+//修改e.Fields中已有的元素前需要先复制切片，追加字段可以直接append
+type Transformer func(e *Entry)
+
+//transformRule 按logger名称生效的变换函数
+type transformRule struct {
+	prefix string //logger名称前缀，为空时对所有日志生效
+	t      Transformer
+}
+
+var (
+	transformLock = new(sync.Mutex) //注册变换函数时加锁
+	transformList atomic.Value      //全局变换函数，[]transformRule，注册时整体替换，写日志时无锁读取
+)
+
+//AddTransformer 注册全局变换函数，对所有日志按注册顺序生效，在logger自身的变换函数之后调用
+func AddTransformer(t Transformer) {
+	AddLoggerTransformer("", t)
+}
+
+//AddLoggerTransformer 注册只对指定logger及其子logger生效的变换函数，name为空时对所有日志生效
+//变换后日志级别低于当前日志级别时丢弃该日志，可用于降低第三方库日志的级别
+//只有原级别达到日志级别的日志才会经过变换，变换不能让被级别过滤的日志重新输出
+//exp: 将第三方库db的info日志降为debug gclog.AddLoggerTransformer("db", gclog.ChangeLevel(gclog.InfoLevel, gclog.DebugLevel))
+func AddLoggerTransformer(name string, t Transformer) {
+	if t == nil {
+		return
+	}
+	transformLock.Lock()
+	defer transformLock.Unlock()
+	old := loadTransformers()
+	rules := make([]transformRule, 0, len(old)+1)
+	transformList.Store(append(append(rules, old...), transformRule{prefix: name, t: t}))
+}
+
+//ClearTransformers 移除所有全局变换函数，logger自身的变换函数不受影响
+func ClearTransformers() {
+	transformLock.Lock()
+	defer transformLock.Unlock()
+	transformList.Store([]transformRule(nil))
+}
+
+//loadTransformers 取当前的全局变换函数，返回的切片不能修改
+func loadTransformers() []transformRule {
+	rules, _ := transformList.Load().([]transformRule)
+	return rules
+}
+
+//match 判断规则是否对该logger生效
+func (r transformRule) match(logger string) bool {
+	if r.prefix == "" || logger == r.prefix {
+		return true
+	}
+	return strings.HasPrefix(logger, r.prefix) && logger[len(r.prefix)] == '.'
+}
+
+//transformEntry 依次调用logger自身与全局的变换函数
+func transformEntry(e *Entry, local []Transformer) {
+	for _, t := range local {
+		t(e)
+	}
+	for _, r := range loadTransformers() {
+		if r.match(e.Logger) {
+			r.t(e)
+		}
+	}
+}
+
+//ChangeLevel 将from级别的日志改为to级别，from为-1时改变所有级别
+func ChangeLevel(from, to int) Transformer {
+	return func(e *Entry) {
+		if from == -1 || e.Level == from {
+			e.Level = to
+		}
+	}
+}
+
+//RewriteMessage 将消息中匹配re的部分替换为repl，repl中可以使用$1引用分组
+//exp: gclog.AddTransformer(gclog.RewriteMessage(regexp.MustCompile(`user=\w+`), "user=*"))
+func RewriteMessage(re *regexp.Regexp, repl string) Transformer {
+	return func(e *Entry) {
+		e.Message = re.ReplaceAllString(e.Message, repl)
+	}
+}
+
+//WithTransformers 创建附带变换函数的子logger，变换函数在全局变换函数之前调用
+func (l *Logger) WithTransformers(ts ...Transformer) *Logger {
+	c := l.clone()
+	c.transformers = append(append([]Transformer(nil), l.transformers...), ts...)
+	return c
+}
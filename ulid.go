@@ -0,0 +1,90 @@
+package gclog
+
+import (
+	"crypto/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//IDField 日志id输出的字段名
+const IDField = "id"
+
+//crockford ULID使用的Crockford base32字符表
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var (
+	entryIDEnable int32 //是否为每条日志生成ULID，原子读写
+	ulidLock      = new(sync.Mutex)
+	ulidLastMs    uint64   //上次生成的毫秒时间戳
+	ulidLastRand  [10]byte //上次生成的随机部分
+)
+
+//EnableEntryID 开启或关闭日志id，开启后每条日志带一个ULID（按时间可排序的唯一id）
+//便于在工单中精确引用某一条日志，以及在多个输出之间交叉对照
+func EnableEntryID(enable bool) {
+	if enable {
+		atomic.StoreInt32(&entryIDEnable, 1)
+	} else {
+		atomic.StoreInt32(&entryIDEnable, 0)
+	}
+}
+
+//nextEntryID 取下一条日志的id，未开启时返回空字符串
+func nextEntryID(t time.Time) string {
+	if atomic.LoadInt32(&entryIDEnable) == 0 {
+		return ""
+	}
+	return newULID(t)
+}
+
+//NewULID 生成一个ULID，同一毫秒内生成的ULID单调递增
+func NewULID() string {
+	return newULID(time.Now())
+}
+
+//newULID 生成时间t对应的ULID：48位毫秒时间戳+80位随机数，Crockford base32编码为26个字符
+func newULID(t time.Time) string {
+	ms := uint64(t.UnixNano() / int64(time.Millisecond))
+
+	ulidLock.Lock()
+	if ms <= ulidLastMs {
+		//同一毫秒（或时钟回拨）时，在上次随机数的基础上加1，保证单调
+		ms = ulidLastMs
+		for i := len(ulidLastRand) - 1; i >= 0; i-- {
+			ulidLastRand[i]++
+			if ulidLastRand[i] != 0 {
+				break
+			}
+		}
+	} else {
+		rand.Read(ulidLastRand[:])
+		ulidLastMs = ms
+	}
+	var id [16]byte
+	id[0], id[1], id[2] = byte(ms>>40), byte(ms>>32), byte(ms>>24)
+	id[3], id[4], id[5] = byte(ms>>16), byte(ms>>8), byte(ms)
+	copy(id[6:], ulidLastRand[:])
+	ulidLock.Unlock()
+
+	return encodeULID(id)
+}
+
+//encodeULID 将128位数据编码为26个字符的Crockford base32
+func encodeULID(id [16]byte) string {
+	var dst [26]byte
+	//128位按5位一组编码，最高位补2个0
+	var acc uint
+	bits := 2
+	pos := 0
+	for _, b := range id {
+		acc = acc<<8 | uint(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			dst[pos] = crockford[(acc>>uint(bits))&31]
+			pos++
+		}
+	}
+	return string(dst[:])
+}
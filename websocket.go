@@ -0,0 +1,272 @@
+package gclog
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//websocketGUID RFC6455握手使用的固定GUID
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+//WebSocket帧类型
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xA
+)
+
+//wsMaxFrame 接收的最大帧长度，客户端只发送过滤条件，不需要很大
+const wsMaxFrame = 64 * 1024
+
+var errBadFrame = errors.New("gclog: bad websocket frame")
+
+//TailHandler 返回通过WebSocket实时查看日志的http.Handler，每条日志以一条json文本消息发送
+//连接参数（也可以在连接后发送json文本消息修改，exp: {"level":"WARNING","regex":"timeout"}）：
+//
+//	level  最低级别，名称或数字
+//	logger logger名称
+//	q      消息包含的子串
+//	regex  消息匹配的正则
+//	recent 连接后先发送的最近日志条数，默认100
+//
+//exp:
+//
+//	http.Handle("/debug/tail", gclog.TailHandler())
+//	浏览器中 new WebSocket("ws://host/debug/tail?level=INFO").onmessage = e => console.log(e.data)
+func TailHandler() http.Handler {
+	h := getTailHub()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseTailFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		recent := 100
+		if n, err := strconv.Atoi(r.URL.Query().Get("recent")); err == nil && n >= 0 {
+			recent = n
+		}
+		conn, rw, err := websocketUpgrade(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer conn.Close()
+		serveTail(h, conn, rw, filter, recent)
+	})
+}
+
+//ServeTail 在addr上启动只提供实时查看的http服务，路径为/tail，监听失败时返回错误
+//exp: gclog.ServeTail("127.0.0.1:6060")
+func ServeTail(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/tail", TailHandler())
+	go http.Serve(ln, mux)
+	return nil
+}
+
+//websocketUpgrade 完成WebSocket握手，返回接管的连接
+func websocketUpgrade(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.ReadWriter, error) {
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return nil, nil, errors.New("gclog: not a websocket handshake")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, nil, errors.New("gclog: missing Sec-WebSocket-Key")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("gclog: connection can not be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: ")
+	rw.WriteString(base64.StdEncoding.EncodeToString(sum[:]))
+	rw.WriteString("\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, rw, nil
+}
+
+//headerContains 判断以逗号分隔的头部中是否包含token（不区分大小写）
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h[name] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//serveTail 发送最近的日志后持续推送新日志，同时读取客户端消息修改过滤条件
+func serveTail(h *tailHub, conn net.Conn, rw *bufio.ReadWriter, filter tailFilter, recent int) {
+	sub, history := h.subscribe(filter, 256, recent)
+	defer h.unsubscribe(sub)
+
+	//读goroutine：处理过滤条件、ping、close，所有写操作通过control交给写循环，避免并发写连接
+	control := make(chan wsFrame, 4)
+	done := make(chan struct{})
+	defer close(done)
+	go readWebsocket(rw.Reader, control, done)
+
+	var buf []byte
+	send := func(opcode byte, payload []byte) bool {
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		buf = appendWebsocketFrame(buf[:0], opcode, payload)
+		_, err := conn.Write(buf)
+		return err == nil
+	}
+	var line []byte
+	for i := range history {
+		line = appendEntryJSON(line[:0], &history[i])
+		if !send(wsText, line) {
+			return
+		}
+	}
+	for {
+		select {
+		case e, ok := <-sub.ch:
+			if !ok {
+				send(wsClose, nil)
+				return
+			}
+			line = appendEntryJSON(line[:0], &e)
+			if !send(wsText, line) {
+				return
+			}
+		case f, ok := <-control:
+			if !ok {
+				return
+			}
+			switch f.opcode {
+			case wsClose:
+				send(wsClose, f.payload)
+				return
+			case wsPing:
+				if !send(wsPong, f.payload) {
+					return
+				}
+			case wsText:
+				if filter, err := parseTailMessage(f.payload); err == nil {
+					h.setFilter(sub, filter)
+				}
+			}
+		}
+	}
+}
+
+//parseTailMessage 解析客户端发送的过滤条件，exp: {"level":"WARNING","logger":"db","q":"slow","regex":"timeout"}
+func parseTailMessage(payload []byte) (tailFilter, error) {
+	var m map[string]string
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return tailFilter{}, err
+	}
+	values := url.Values{}
+	for k, v := range m {
+		values.Set(k, v)
+	}
+	return parseTailFilter(values)
+}
+
+//wsFrame 一个WebSocket帧
+type wsFrame struct {
+	opcode  byte
+	payload []byte
+}
+
+//readWebsocket 读取客户端帧，连接关闭或出错时关闭control，写循环退出（done关闭）后不再投递
+func readWebsocket(r *bufio.Reader, control chan<- wsFrame, done <-chan struct{}) {
+	defer close(control)
+	for {
+		f, err := readWebsocketFrame(r)
+		if err != nil {
+			return
+		}
+		select {
+		case control <- f:
+		case <-done:
+			return
+		}
+		if f.opcode == wsClose {
+			return
+		}
+	}
+}
+
+//readWebsocketFrame 读取一个客户端帧，客户端帧必须带掩码，不支持分片消息
+func readWebsocketFrame(r *bufio.Reader) (wsFrame, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return wsFrame{}, err
+	}
+	opcode := head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+	size := uint64(head[1] & 0x7F)
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return wsFrame{}, err
+		}
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return wsFrame{}, err
+		}
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+	if !masked || size > wsMaxFrame {
+		return wsFrame{}, errBadFrame
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return wsFrame{}, err
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return wsFrame{}, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return wsFrame{opcode: opcode, payload: payload}, nil
+}
+
+//appendWebsocketFrame 追加一个服务端帧（不带掩码，不分片）
+func appendWebsocketFrame(buf []byte, opcode byte, payload []byte) []byte {
+	buf = append(buf, 0x80|opcode)
+	switch n := len(payload); {
+	case n < 126:
+		buf = append(buf, byte(n))
+	case n <= 0xFFFF:
+		buf = append(buf, 126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, 127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+	return append(buf, payload...)
+}